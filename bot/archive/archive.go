@@ -0,0 +1,181 @@
+// Package archive exports the dataset to a portable zip archive and restores
+// it into an empty Pocketbase. Records keep their Pocketbase ids, so relations
+// survive the round trip and two exports of the same data are identical.
+package archive
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Version is the archive layout this package writes. Archives of a newer
+// version are refused rather than restored partially.
+const Version = 1
+
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatCSV  Format = "csv"
+)
+
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(strings.ToLower(value)); format {
+	case FormatJSON, FormatCSV:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown archive format %q, expected json or csv", value)
+	}
+}
+
+// Manifest describes an archive, it is always stored as manifest.json
+type Manifest struct {
+	Version int    `json:"version"`
+	Format  Format `json:"format"`
+	// Exported is when the archive was made, in RFC 3339
+	Exported string `json:"exported"`
+	Counts   Counts `json:"counts"`
+}
+
+type Counts struct {
+	Colleges       int `json:"colleges"`
+	Branches       int `json:"branches"`
+	Ranks          int `json:"ranks"`
+	CollegeAliases int `json:"college_aliases"`
+	BranchAliases  int `json:"branch_aliases"`
+}
+
+type College struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Alias string `json:"alias"`
+}
+
+type Branch struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Code string `json:"code"`
+	Ciwg bool   `json:"ciwg"`
+}
+
+type Rank struct {
+	ID       string `json:"id"`
+	Year     int    `json:"year"`
+	Round    int    `json:"round"`
+	College  string `json:"college"`
+	Branch   string `json:"branch"`
+	JeeOpen  int    `json:"jee_open"`
+	JeeClose int    `json:"jee_close"`
+}
+
+type CollegeAlias struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	College string `json:"college"`
+}
+
+type BranchAlias struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Code   string `json:"code"`
+	Branch string `json:"branch"`
+}
+
+// Dataset is everything an archive holds
+type Dataset struct {
+	Colleges       []College
+	Branches       []Branch
+	Ranks          []Rank
+	CollegeAliases []CollegeAlias
+	BranchAliases  []BranchAlias
+}
+
+func (d *Dataset) Counts() Counts {
+	return Counts{
+		Colleges:       len(d.Colleges),
+		Branches:       len(d.Branches),
+		Ranks:          len(d.Ranks),
+		CollegeAliases: len(d.CollegeAliases),
+		BranchAliases:  len(d.BranchAliases),
+	}
+}
+
+func (c Counts) String() string {
+	return fmt.Sprintf("%d colleges, %d branches, %d ranks, %d college aliases and %d branch aliases",
+		c.Colleges, c.Branches, c.Ranks, c.CollegeAliases, c.BranchAliases)
+}
+
+// sort orders every collection by id so the same data always exports the same way
+func (d *Dataset) sort() {
+	slices.SortFunc(d.Colleges, func(a, b College) int { return strings.Compare(a.ID, b.ID) })
+	slices.SortFunc(d.Branches, func(a, b Branch) int { return strings.Compare(a.ID, b.ID) })
+	slices.SortFunc(d.Ranks, func(a, b Rank) int { return strings.Compare(a.ID, b.ID) })
+	slices.SortFunc(d.CollegeAliases, func(a, b CollegeAlias) int { return strings.Compare(a.ID, b.ID) })
+	slices.SortFunc(d.BranchAliases, func(a, b BranchAlias) int { return strings.Compare(a.ID, b.ID) })
+}
+
+// Validate checks that ids are set and unique and that every relation points
+// at a record of the archive
+func (d *Dataset) Validate() error {
+	ids := make(map[string]string)
+	claim := func(collection, id string) error {
+		if id == "" {
+			return fmt.Errorf("a record of %s has no id", collection)
+		}
+		if other, ok := ids[id]; ok {
+			return fmt.Errorf("id %s is used by both %s and %s", id, other, collection)
+		}
+		ids[id] = collection
+		return nil
+	}
+
+	colleges := make(map[string]bool, len(d.Colleges))
+	for _, college := range d.Colleges {
+		if err := claim("colleges", college.ID); err != nil {
+			return err
+		}
+		colleges[college.ID] = true
+	}
+	branches := make(map[string]bool, len(d.Branches))
+	for _, branch := range d.Branches {
+		if err := claim("branches", branch.ID); err != nil {
+			return err
+		}
+		branches[branch.ID] = true
+	}
+	for _, rank := range d.Ranks {
+		if err := claim("ranks", rank.ID); err != nil {
+			return err
+		}
+		if !colleges[rank.College] {
+			return fmt.Errorf("rank %s points at college %s which is not in the archive", rank.ID, rank.College)
+		}
+		if !branches[rank.Branch] {
+			return fmt.Errorf("rank %s points at branch %s which is not in the archive", rank.ID, rank.Branch)
+		}
+	}
+	for _, alias := range d.CollegeAliases {
+		if err := claim("college_aliases", alias.ID); err != nil {
+			return err
+		}
+		if !colleges[alias.College] {
+			return fmt.Errorf("college alias %s points at college %s which is not in the archive", alias.ID, alias.College)
+		}
+	}
+	for _, alias := range d.BranchAliases {
+		if err := claim("branch_aliases", alias.ID); err != nil {
+			return err
+		}
+		if !branches[alias.Branch] {
+			return fmt.Errorf("branch alias %s points at branch %s which is not in the archive", alias.ID, alias.Branch)
+		}
+	}
+	return nil
+}
+
+// FileName is what an archive exported at exported is called
+func FileName(exported time.Time) string {
+	return fmt.Sprintf("dasa-export-%s.zip", exported.Format("2006-01-02"))
+}
@@ -0,0 +1,123 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arinji2/dasa-bot/pb"
+)
+
+// newSourceStore holds more ranks than fit in one batch and names that need
+// quoting in CSV
+func newSourceStore(t *testing.T) *pb.MemoryStore {
+	t.Helper()
+	ctx := context.Background()
+	colleges := []pb.CollegeCollection{
+		{ID: "nitc", Name: "National Institute of Technology Calicut", Alias: "NITC, REC Calicut"},
+		{ID: "iiita", Name: `Indian Institute of Information Technology "Allahabad"`, Alias: "IIITA"},
+		{ID: "spa", Name: "School of Planning & Architecture, Delhi\nकनॉट"},
+	}
+	branches := []pb.BranchCollection{
+		{ID: "cse", Name: "Computer Science and Engineering", Code: "CS"},
+		{ID: "cseciwg", Name: "Computer Science and Engineering", Code: "CS", Ciwg: true},
+		{ID: "arch", Name: "Architecture", Code: "AR"},
+	}
+	var ranks []pb.RankCollection
+	for idx := range pb.MaxBatchSize*2 + 7 {
+		ranks = append(ranks, pb.RankCollection{
+			ID:       fmt.Sprintf("rank%03d", idx),
+			Year:     2020 + idx%5,
+			Round:    1 + idx%6,
+			College:  colleges[idx%len(colleges)].ID,
+			Branch:   branches[idx%len(branches)].ID,
+			JeeOpen:  idx * 10,
+			JeeClose: idx*10 + 5,
+		})
+	}
+	store := pb.NewMemoryStore(colleges, branches, ranks)
+	if _, err := store.CreateCollegeAlias(ctx, pb.CollegeAliasCreateRequest{ID: "calias1", Name: "Regional Engineering College, Calicut", College: "nitc"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CreateBranchAlias(ctx, pb.BranchAliasCreateRequest{ID: "balias1", Name: "Comp. Sci.", Code: "CSE", Branch: "cseciwg"}); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []Format{FormatJSON, FormatCSV} {
+		t.Run(string(format), func(t *testing.T) {
+			ctx := context.Background()
+			exported, err := Collect(ctx, newSourceStore(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			exportedAt := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+			if err := Write(&buf, exported, format, exportedAt); err != nil {
+				t.Fatal(err)
+			}
+			manifest, read, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if manifest.Version != Version || manifest.Format != format || manifest.Counts != exported.Counts() {
+				t.Fatalf("got manifest %+v, want version %d, format %s and counts %+v", manifest, Version, format, exported.Counts())
+			}
+			if !reflect.DeepEqual(read, exported) {
+				t.Fatalf("read dataset differs from the exported one:\ngot  %+v\nwant %+v", read, exported)
+			}
+
+			target := pb.NewMemoryStore(nil, nil, nil)
+			restored, err := Restore(ctx, target, read)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if restored != exported.Counts() {
+				t.Fatalf("restored %s, want %s", restored, exported.Counts())
+			}
+			collected, err := Collect(ctx, target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(collected, exported) {
+				t.Fatalf("restored dataset differs from the exported one:\ngot  %+v\nwant %+v", collected, exported)
+			}
+
+			// exporting the restored data gives the same archive
+			var again bytes.Buffer
+			if err := Write(&again, collected, format, exportedAt); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(again.Bytes(), buf.Bytes()) {
+				t.Fatal("exporting the restored dataset made a different archive")
+			}
+		})
+	}
+}
+
+func TestRestoreRefusesStoreWithRecords(t *testing.T) {
+	ctx := context.Background()
+	d, err := Collect(ctx, newSourceStore(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := pb.NewMemoryStore(nil, nil, nil)
+	if _, err := target.CreateBranch(ctx, pb.BranchCreateRequest{Name: "Civil Engineering", Code: "CE"}); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := Restore(ctx, target, d)
+	if err == nil || !strings.Contains(err.Error(), "only restored into an empty instance") {
+		t.Fatalf("got %v, want a refusal", err)
+	}
+	if restored != (Counts{}) {
+		t.Fatalf("restored %s into a store with records", restored)
+	}
+}
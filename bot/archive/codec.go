@@ -0,0 +1,277 @@
+package archive
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const manifestName = "manifest.json"
+
+// table is how one collection is laid out in an archive
+type table struct {
+	name   string
+	header []string
+	// records is the collection for JSON, rows the same records for CSV
+	records func(d *Dataset) any
+	rows    func(d *Dataset) [][]string
+	// load adds one CSV row, keyed by header, to the dataset
+	load func(d *Dataset, row map[string]string) error
+}
+
+var tables = []table{
+	{
+		name:    "colleges",
+		header:  []string{"id", "name", "alias"},
+		records: func(d *Dataset) any { return &d.Colleges },
+		rows: func(d *Dataset) [][]string {
+			rows := make([][]string, len(d.Colleges))
+			for idx, c := range d.Colleges {
+				rows[idx] = []string{c.ID, c.Name, c.Alias}
+			}
+			return rows
+		},
+		load: func(d *Dataset, row map[string]string) error {
+			d.Colleges = append(d.Colleges, College{ID: row["id"], Name: row["name"], Alias: row["alias"]})
+			return nil
+		},
+	},
+	{
+		name:    "branches",
+		header:  []string{"id", "name", "code", "ciwg"},
+		records: func(d *Dataset) any { return &d.Branches },
+		rows: func(d *Dataset) [][]string {
+			rows := make([][]string, len(d.Branches))
+			for idx, b := range d.Branches {
+				rows[idx] = []string{b.ID, b.Name, b.Code, strconv.FormatBool(b.Ciwg)}
+			}
+			return rows
+		},
+		load: func(d *Dataset, row map[string]string) error {
+			ciwg, err := strconv.ParseBool(row["ciwg"])
+			if err != nil {
+				return fmt.Errorf("invalid ciwg %q for branch %s", row["ciwg"], row["id"])
+			}
+			d.Branches = append(d.Branches, Branch{ID: row["id"], Name: row["name"], Code: row["code"], Ciwg: ciwg})
+			return nil
+		},
+	},
+	{
+		name:    "ranks",
+		header:  []string{"id", "year", "round", "college", "branch", "jee_open", "jee_close"},
+		records: func(d *Dataset) any { return &d.Ranks },
+		rows: func(d *Dataset) [][]string {
+			rows := make([][]string, len(d.Ranks))
+			for idx, r := range d.Ranks {
+				rows[idx] = []string{r.ID, strconv.Itoa(r.Year), strconv.Itoa(r.Round), r.College, r.Branch,
+					strconv.Itoa(r.JeeOpen), strconv.Itoa(r.JeeClose)}
+			}
+			return rows
+		},
+		load: func(d *Dataset, row map[string]string) error {
+			rank := Rank{ID: row["id"], College: row["college"], Branch: row["branch"]}
+			numbers := []struct {
+				column string
+				value  *int
+			}{
+				{"year", &rank.Year},
+				{"round", &rank.Round},
+				{"jee_open", &rank.JeeOpen},
+				{"jee_close", &rank.JeeClose},
+			}
+			for _, number := range numbers {
+				value, err := strconv.Atoi(row[number.column])
+				if err != nil {
+					return fmt.Errorf("invalid %s %q for rank %s", number.column, row[number.column], rank.ID)
+				}
+				*number.value = value
+			}
+			d.Ranks = append(d.Ranks, rank)
+			return nil
+		},
+	},
+	{
+		name:    "college_aliases",
+		header:  []string{"id", "name", "college"},
+		records: func(d *Dataset) any { return &d.CollegeAliases },
+		rows: func(d *Dataset) [][]string {
+			rows := make([][]string, len(d.CollegeAliases))
+			for idx, a := range d.CollegeAliases {
+				rows[idx] = []string{a.ID, a.Name, a.College}
+			}
+			return rows
+		},
+		load: func(d *Dataset, row map[string]string) error {
+			d.CollegeAliases = append(d.CollegeAliases, CollegeAlias{ID: row["id"], Name: row["name"], College: row["college"]})
+			return nil
+		},
+	},
+	{
+		name:    "branch_aliases",
+		header:  []string{"id", "name", "code", "branch"},
+		records: func(d *Dataset) any { return &d.BranchAliases },
+		rows: func(d *Dataset) [][]string {
+			rows := make([][]string, len(d.BranchAliases))
+			for idx, a := range d.BranchAliases {
+				rows[idx] = []string{a.ID, a.Name, a.Code, a.Branch}
+			}
+			return rows
+		},
+		load: func(d *Dataset, row map[string]string) error {
+			d.BranchAliases = append(d.BranchAliases, BranchAlias{ID: row["id"], Name: row["name"], Code: row["code"], Branch: row["branch"]})
+			return nil
+		},
+	},
+}
+
+// Write stores d as a zip archive with a manifest and one file per collection
+// in format
+func Write(w io.Writer, d Dataset, format Format, exported time.Time) error {
+	d.sort()
+	zw := zip.NewWriter(w)
+
+	manifest := Manifest{
+		Version:  Version,
+		Format:   format,
+		Exported: exported.UTC().Format(time.RFC3339),
+		Counts:   d.Counts(),
+	}
+	f, err := zw.Create(manifestName)
+	if err != nil {
+		return err
+	}
+	if err := writeJSON(f, manifest); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	for _, t := range tables {
+		f, err := zw.Create(t.name + "." + string(format))
+		if err != nil {
+			return err
+		}
+		switch format {
+		case FormatJSON:
+			err = writeJSON(f, t.records(&d))
+		case FormatCSV:
+			err = writeCSV(f, t.header, t.rows(&d))
+		default:
+			err = fmt.Errorf("unknown archive format %q", format)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", t.name, err)
+		}
+	}
+	return zw.Close()
+}
+
+func writeJSON(w io.Writer, value any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+func writeCSV(w io.Writer, header []string, rows [][]string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// Read opens an archive made by Write and checks it can be restored
+func Read(r io.ReaderAt, size int64) (Manifest, Dataset, error) {
+	var manifest Manifest
+	var d Dataset
+
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return manifest, d, fmt.Errorf("not a zip archive: %w", err)
+	}
+	if err := readJSON(zr, manifestName, &manifest); err != nil {
+		return manifest, d, err
+	}
+	if manifest.Version < 1 || manifest.Version > Version {
+		return manifest, d, fmt.Errorf("archive version %d is not supported, this build reads up to version %d", manifest.Version, Version)
+	}
+
+	for _, t := range tables {
+		name := t.name + "." + string(manifest.Format)
+		switch manifest.Format {
+		case FormatJSON:
+			err = readJSON(zr, name, t.records(&d))
+		case FormatCSV:
+			err = readCSV(zr, name, t, &d)
+		default:
+			err = fmt.Errorf("unknown archive format %q", manifest.Format)
+		}
+		if err != nil {
+			return manifest, d, err
+		}
+	}
+
+	if counts := d.Counts(); counts != manifest.Counts {
+		return manifest, d, fmt.Errorf("archive holds %s but its manifest lists %s", counts, manifest.Counts)
+	}
+	if err := d.Validate(); err != nil {
+		return manifest, d, fmt.Errorf("invalid archive: %w", err)
+	}
+	return manifest, d, nil
+}
+
+func readJSON(zr *zip.Reader, name string, value any) error {
+	f, err := zr.Open(name)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(value); err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return nil
+}
+
+// readCSV adds the rows of a CSV file to d. Columns are matched by the header,
+// which has to have every column of t.
+func readCSV(zr *zip.Reader, name string, t table, d *Dataset) error {
+	f, err := zr.Open(name)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer f.Close()
+
+	cr := csv.NewReader(f)
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read the header of %s: %w", name, err)
+	}
+	for _, column := range t.header {
+		if !slices.Contains(header, column) {
+			return fmt.Errorf("%s has no %s column", name, column)
+		}
+	}
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		row := make(map[string]string, len(header))
+		for idx, column := range header {
+			row[column] = record[idx]
+		}
+		if err := t.load(d, row); err != nil {
+			return fmt.Errorf("%s line %d: %w", name, line, err)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/arinji2/dasa-bot/pb"
+)
+
+// restoredCollections have to be empty before an archive is restored
+var restoredCollections = []string{"colleges", "branches", "ranks", "college_aliases", "branch_aliases"}
+
+// Collect reads every college, branch, rank and alias out of store
+func Collect(ctx context.Context, store pb.Store) (Dataset, error) {
+	var d Dataset
+
+	colleges, err := store.GetAllColleges(ctx)
+	if err != nil {
+		return d, fmt.Errorf("failed to get colleges: %w", err)
+	}
+	for _, college := range colleges {
+		d.Colleges = append(d.Colleges, College{ID: college.ID, Name: college.Name, Alias: college.Alias})
+	}
+
+	branches, err := store.GetAllBranches(ctx)
+	if err != nil {
+		return d, fmt.Errorf("failed to get branches: %w", err)
+	}
+	for _, branch := range branches {
+		d.Branches = append(d.Branches, Branch{ID: branch.ID, Name: branch.Name, Code: branch.Code, Ciwg: branch.Ciwg})
+	}
+
+	ranks, err := store.GetAllRanks(ctx)
+	if err != nil {
+		return d, fmt.Errorf("failed to get ranks: %w", err)
+	}
+	for _, rank := range ranks {
+		d.Ranks = append(d.Ranks, Rank{
+			ID:       rank.ID,
+			Year:     rank.Year,
+			Round:    rank.Round,
+			College:  rank.College,
+			Branch:   rank.Branch,
+			JeeOpen:  rank.JeeOpen,
+			JeeClose: rank.JeeClose,
+		})
+	}
+
+	collegeAliases, err := store.GetAllCollegeAliases(ctx)
+	if err != nil {
+		return d, fmt.Errorf("failed to get college aliases: %w", err)
+	}
+	for _, alias := range collegeAliases {
+		d.CollegeAliases = append(d.CollegeAliases, CollegeAlias{ID: alias.ID, Name: alias.Name, College: alias.College})
+	}
+
+	branchAliases, err := store.GetAllBranchAliases(ctx)
+	if err != nil {
+		return d, fmt.Errorf("failed to get branch aliases: %w", err)
+	}
+	for _, alias := range branchAliases {
+		d.BranchAliases = append(d.BranchAliases, BranchAlias{ID: alias.ID, Name: alias.Name, Code: alias.Code, Branch: alias.Branch})
+	}
+
+	d.sort()
+	return d, nil
+}
+
+// Restore creates every record of d in store under its exported id. store has
+// to be empty, a restore that fails part way leaves what it wrote so far and
+// returns how much that was.
+func Restore(ctx context.Context, store pb.Store, d Dataset) (Counts, error) {
+	var restored Counts
+	if err := d.Validate(); err != nil {
+		return restored, fmt.Errorf("invalid archive: %w", err)
+	}
+	for _, collection := range restoredCollections {
+		ids, err := store.ListRecordIDs(ctx, collection)
+		if err != nil {
+			return restored, fmt.Errorf("failed to list %s: %w", collection, err)
+		}
+		if len(ids) > 0 {
+			return restored, fmt.Errorf("pocketbase already has %d records in %s, archives are only restored into an empty instance", len(ids), collection)
+		}
+	}
+
+	colleges := make([]pb.CollegeCreateRequest, len(d.Colleges))
+	for idx, college := range d.Colleges {
+		colleges[idx] = pb.CollegeCreateRequest{ID: college.ID, Name: college.Name, Alias: college.Alias}
+	}
+	err := inBatches(colleges, func(chunk []pb.CollegeCreateRequest) error {
+		_, err := store.CreateColleges(ctx, chunk)
+		if err == nil {
+			restored.Colleges += len(chunk)
+		}
+		return err
+	})
+	if err != nil {
+		return restored, fmt.Errorf("failed to create colleges: %w", err)
+	}
+
+	branches := make([]pb.BranchCreateRequest, len(d.Branches))
+	for idx, branch := range d.Branches {
+		branches[idx] = pb.BranchCreateRequest{ID: branch.ID, Name: branch.Name, Code: branch.Code, Ciwg: branch.Ciwg}
+	}
+	err = inBatches(branches, func(chunk []pb.BranchCreateRequest) error {
+		_, err := store.CreateBranches(ctx, chunk)
+		if err == nil {
+			restored.Branches += len(chunk)
+		}
+		return err
+	})
+	if err != nil {
+		return restored, fmt.Errorf("failed to create branches: %w", err)
+	}
+
+	ranks := make([]pb.RankCreateRequest, len(d.Ranks))
+	for idx, rank := range d.Ranks {
+		ranks[idx] = pb.RankCreateRequest{
+			ID:       rank.ID,
+			Year:     rank.Year,
+			Round:    rank.Round,
+			JeeOpen:  rank.JeeOpen,
+			JeeClose: rank.JeeClose,
+			College:  rank.College,
+			Branch:   rank.Branch,
+		}
+	}
+	err = inBatches(ranks, func(chunk []pb.RankCreateRequest) error {
+		_, err := store.CreateRanks(ctx, chunk)
+		if err == nil {
+			restored.Ranks += len(chunk)
+		}
+		return err
+	})
+	if err != nil {
+		return restored, fmt.Errorf("failed to create ranks: %w", err)
+	}
+
+	for _, alias := range d.CollegeAliases {
+		_, err := store.CreateCollegeAlias(ctx, pb.CollegeAliasCreateRequest{ID: alias.ID, Name: alias.Name, College: alias.College})
+		if err != nil {
+			return restored, fmt.Errorf("failed to create college alias %s: %w", alias.Name, err)
+		}
+		restored.CollegeAliases++
+	}
+	for _, alias := range d.BranchAliases {
+		_, err := store.CreateBranchAlias(ctx, pb.BranchAliasCreateRequest{ID: alias.ID, Name: alias.Name, Code: alias.Code, Branch: alias.Branch})
+		if err != nil {
+			return restored, fmt.Errorf("failed to create branch alias %s: %w", alias.Name, err)
+		}
+		restored.BranchAliases++
+	}
+	return restored, nil
+}
+
+// inBatches hands items to write in chunks of pb.MaxBatchSize
+func inBatches[T any](items []T, write func(chunk []T) error) error {
+	for start := 0; start < len(items); start += pb.MaxBatchSize {
+		if err := write(items[start:min(start+pb.MaxBatchSize, len(items))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
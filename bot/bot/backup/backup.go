@@ -0,0 +1,293 @@
+// Package backup contains the logic for the Backup command
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/arinji2/dasa-bot/env"
+	"github.com/arinji2/dasa-bot/pb"
+	responses "github.com/arinji2/dasa-bot/responses"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// maxAttachmentSize is the most Discord lets a bot upload to a server without boosts
+	maxAttachmentSize = 10 << 20
+	// maxChoices is Discord's limit for autocomplete choices
+	maxChoices = 25
+)
+
+type BackupCommand struct {
+	PbAdmin pb.Store
+	BotEnv  env.Bot
+	// Retention is applied after every backup made with /backup create
+	Retention pb.Retention
+	// Reload loads every record again once a restored backup is live
+	Reload func(ctx context.Context) error
+}
+
+func (c *BackupCommand) HandleBackupResponse(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	subcommand := i.ApplicationCommandData().Options[0]
+	options := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(subcommand.Options))
+	for _, option := range subcommand.Options {
+		options[option.Name] = option
+	}
+
+	switch subcommand.Name {
+	case "list":
+		c.handleList(ctx, s, i)
+	case "create":
+		c.handleCreate(ctx, s, i)
+	case "delete":
+		c.handleDelete(ctx, s, i, options["key"].StringValue())
+	case "restore":
+		c.handleRestore(ctx, s, i, options["key"].StringValue())
+	case "download":
+		c.handleDownload(ctx, s, i, options["key"].StringValue())
+	}
+}
+
+// HandleBackupAutocomplete suggests the backups for the key option, newest first
+func (c *BackupCommand) HandleBackupAutocomplete(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var searchTerm string
+	for _, option := range i.ApplicationCommandData().Options[0].Options {
+		if option.Focused {
+			searchTerm = strings.ToLower(option.StringValue())
+		}
+	}
+
+	backups, err := c.listBackups(ctx)
+	if err != nil {
+		log.Printf("Error listing backups: %v", err)
+	}
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, backup := range backups {
+		if searchTerm != "" && !strings.Contains(strings.ToLower(backup.Key), searchTerm) {
+			continue
+		}
+		name := fmt.Sprintf("%s · %s · %s", backup.Key, describeSize(backup.Size), describeAge(backup.Modified))
+		if len(name) > 100 {
+			name = backup.Key
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  name,
+			Value: backup.Key,
+		})
+		if len(choices) == maxChoices {
+			break
+		}
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to backup autocomplete: %v", err)
+	}
+}
+
+func (c *BackupCommand) handleList(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	backups, err := c.listBackups(ctx)
+	if err != nil {
+		log.Printf("Error listing backups: %v", err)
+		responses.RespondWithEphemeralError(s, i, fmt.Sprintf("Could not list backups: %v", err))
+		return
+	}
+	if len(backups) == 0 {
+		responses.RespondWithEmbed(s, i, c.BotEnv, "Backups", "There are no backups yet, make one with `/backup create`\n\n"+c.Retention.String(), nil)
+		return
+	}
+
+	expired := make(map[string]bool)
+	for _, backup := range c.Retention.Expired(backups, time.Now()) {
+		expired[backup.Key] = true
+	}
+	var total int64
+	lines := make([]string, len(backups))
+	for idx, backup := range backups {
+		total += backup.Size
+		lines[idx] = fmt.Sprintf("`%s` %s, %s", backup.Key, describeSize(backup.Size), describeTime(backup.Modified))
+		if expired[backup.Key] {
+			lines[idx] += ", deleted by the next backup"
+		}
+	}
+	description := fmt.Sprintf("%d backups taking up %s, newest first. %s.\n\n", len(backups), describeSize(total), c.Retention.String()) +
+		strings.Join(lines, "\n")
+	responses.RespondWithEmbed(s, i, c.BotEnv, "Backups", truncate(description, 4096), nil)
+}
+
+func (c *BackupCommand) handleCreate(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	// Pocketbase answers once the archive is written, which can take a while
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+	if err != nil {
+		log.Printf("Error deferring backup create: %v", err)
+		return
+	}
+
+	backupName, err := c.PbAdmin.CreateBackup(ctx, i.Member.User.Username)
+	if err != nil {
+		log.Printf("Error creating backup: %v", err)
+		c.edit(s, i, responses.CreateBaseEmbed("Error creating backup", err.Error(), c.BotEnv, nil))
+		return
+	}
+
+	description := fmt.Sprintf("Created backup **%s**", backupName)
+	pruned, err := pb.PruneBackups(ctx, c.PbAdmin, c.Retention)
+	for _, backup := range pruned {
+		description += fmt.Sprintf("\nDeleted backup **%s** past the retention policy", backup.Key)
+	}
+	if err != nil {
+		log.Printf("Error pruning backups: %v", err)
+		description += fmt.Sprintf("\nCould not delete old backups: %v", err)
+	}
+	c.edit(s, i, responses.CreateBaseEmbed("Backup created", description, c.BotEnv, nil))
+}
+
+func (c *BackupCommand) handleDelete(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, key string) {
+	err := c.PbAdmin.DeleteBackup(ctx, key)
+	if err != nil {
+		log.Printf("Error deleting backup %s: %v", key, err)
+		responses.RespondWithEphemeralError(s, i, fmt.Sprintf("Could not delete backup: %v", err))
+		return
+	}
+	responses.RespondWithEmbed(s, i, c.BotEnv, "Backup deleted", fmt.Sprintf("Deleted backup **%s**", key), nil)
+}
+
+// handleDownload uploads the archive of a backup as an attachment only the moderator can see
+func (c *BackupCommand) handleDownload(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, key string) {
+	backup, err := c.findBackup(ctx, key)
+	if err != nil {
+		responses.RespondWithEphemeralError(s, i, err.Error())
+		return
+	}
+	if backup.Size > maxAttachmentSize {
+		responses.RespondWithEphemeralError(s, i, fmt.Sprintf("Backup **%s** is %s, more than the %s Discord allows. Download it from the Pocketbase dashboard instead.",
+			key, describeSize(backup.Size), describeSize(maxAttachmentSize)))
+		return
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Printf("Error deferring backup download: %v", err)
+		return
+	}
+
+	archive, err := c.PbAdmin.DownloadBackup(ctx, key)
+	if err != nil {
+		log.Printf("Error downloading backup %s: %v", key, err)
+		c.edit(s, i, responses.CreateBaseEmbed("Error downloading backup", err.Error(), c.BotEnv, nil))
+		return
+	}
+	defer archive.Close()
+
+	_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{responses.CreateBaseEmbed("Backup downloaded",
+			fmt.Sprintf("Backup **%s**, %s", key, describeSize(backup.Size)), c.BotEnv, nil)},
+		Files: []*discordgo.File{{Name: key, ContentType: "application/zip", Reader: archive}},
+	})
+	if err != nil {
+		log.Printf("Error uploading backup %s: %v", key, err)
+		c.edit(s, i, responses.CreateBaseEmbed("Error downloading backup", err.Error(), c.BotEnv, nil))
+	}
+}
+
+func (c *BackupCommand) edit(s *discordgo.Session, i *discordgo.InteractionCreate, embed *discordgo.MessageEmbed) {
+	_, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds:     &[]*discordgo.MessageEmbed{embed},
+		Components: &[]discordgo.MessageComponent{},
+	})
+	if err != nil {
+		log.Printf("Error editing backup response: %v", err)
+	}
+}
+
+// listBackups returns every backup newest first
+func (c *BackupCommand) listBackups(ctx context.Context) ([]pb.BackupCollection, error) {
+	backups, err := c.PbAdmin.ListBackups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pb.SortBackups(backups)
+	return backups, nil
+}
+
+func (c *BackupCommand) findBackup(ctx context.Context, key string) (pb.BackupCollection, error) {
+	backups, err := c.PbAdmin.ListBackups(ctx)
+	if err != nil {
+		log.Printf("Error listing backups: %v", err)
+		return pb.BackupCollection{}, fmt.Errorf("could not list backups: %w", err)
+	}
+	idx := slices.IndexFunc(backups, func(backup pb.BackupCollection) bool {
+		return backup.Key == key
+	})
+	if idx < 0 {
+		return pb.BackupCollection{}, fmt.Errorf("no backup found for key: %s", key)
+	}
+	return backups[idx], nil
+}
+
+// describeSize shows a size in bytes the way file managers do
+func describeSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size)
+	suffixes := []string{"KB", "MB", "GB", "TB"}
+	idx := -1
+	for value >= unit && idx < len(suffixes)-1 {
+		value /= unit
+		idx++
+	}
+	return fmt.Sprintf("%.1f %s", value, suffixes[idx])
+}
+
+// describeTime shows when a backup was made in the reader's own time zone, with its age
+func describeTime(value string) string {
+	modified, err := pb.ParseTime(value)
+	if err != nil {
+		return value
+	}
+	return fmt.Sprintf("<t:%d:f> (<t:%d:R>)", modified.Unix(), modified.Unix())
+}
+
+// describeAge is describeTime for places Discord does not format timestamps in
+func describeAge(value string) string {
+	modified, err := pb.ParseTime(value)
+	if err != nil {
+		return value
+	}
+	age := time.Since(modified)
+	switch {
+	case age < time.Hour:
+		return fmt.Sprintf("%d minutes old", int(age.Minutes()))
+	case age < 48*time.Hour:
+		return fmt.Sprintf("%d hours old", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%d days old", int(age.Hours()/24))
+	}
+}
+
+// truncate cuts text to at most limit characters
+func truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-1]) + "…"
+}
@@ -0,0 +1,135 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	responses "github.com/arinji2/dasa-bot/responses"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	restoreButtonPrefix = "backup_restore_"
+	cancelButtonID      = "backup_cancel"
+	// reloadDelay gives Pocketbase time to swap in the backup and restart before each try at reloading the data
+	reloadDelay    = 15 * time.Second
+	reloadAttempts = 5
+)
+
+// handleRestore asks to confirm a restore, it replaces every record in Pocketbase
+func (c *BackupCommand) handleRestore(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, key string) {
+	backup, err := c.findBackup(ctx, key)
+	if err != nil {
+		responses.RespondWithEphemeralError(s, i, err.Error())
+		return
+	}
+	customID := restoreButtonPrefix + backup.Key
+	if len(customID) > 100 {
+		responses.RespondWithEphemeralError(s, i, fmt.Sprintf("Backup key **%s** is too long to restore from Discord, use the Pocketbase dashboard instead", key))
+		return
+	}
+
+	description := fmt.Sprintf("Restoring **%s** from %s replaces every college, branch, rank and import with the ones in the backup. "+
+		"Anything changed since then is lost unless it is backed up first. Pocketbase restarts while restoring.", backup.Key, describeTime(backup.Modified))
+	components := []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Restore",
+					Style:    discordgo.DangerButton,
+					CustomID: customID,
+				},
+				discordgo.Button{
+					Label:    "Cancel",
+					Style:    discordgo.SecondaryButton,
+					CustomID: cancelButtonID,
+				},
+			},
+		},
+	}
+	err = responses.RespondWithEmbedAndComponents(s, i, c.BotEnv, "Restore backup?", description, nil, components)
+	if err != nil {
+		log.Printf("Error responding to backup restore: %v", err)
+	}
+}
+
+// HandleBackupButton handles the confirmation buttons of /backup restore
+func (c *BackupCommand) HandleBackupButton(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	customID := i.MessageComponentData().CustomID
+	if customID == cancelButtonID {
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Embeds:     []*discordgo.MessageEmbed{responses.CreateBaseEmbed("Restore cancelled", "Nothing was restored", c.BotEnv, nil)},
+				Components: []discordgo.MessageComponent{},
+			},
+		})
+		if err != nil {
+			log.Printf("Error cancelling backup restore: %v", err)
+		}
+		return
+	}
+	if key, ok := strings.CutPrefix(customID, restoreButtonPrefix); ok {
+		c.confirmRestore(ctx, s, i, key)
+	}
+}
+
+func (c *BackupCommand) confirmRestore(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, key string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		log.Printf("Error deferring backup restore: %v", err)
+		return
+	}
+
+	err = c.PbAdmin.RestoreBackup(ctx, key)
+	if err != nil {
+		log.Printf("Error restoring backup %s: %v", key, err)
+		components := i.Message.Components
+		// leave the buttons so the restore can be tried again
+		_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Embeds:     &[]*discordgo.MessageEmbed{responses.CreateBaseEmbed("Error restoring backup", err.Error(), c.BotEnv, nil)},
+			Components: &components,
+		})
+		if err != nil {
+			log.Printf("Error editing backup restore: %v", err)
+		}
+		return
+	}
+
+	restoredBy := fmt.Sprintf("Restored by <@%s>", i.Member.User.ID)
+	c.edit(s, i, responses.CreateBaseEmbed("Restoring backup",
+		fmt.Sprintf("Restoring backup **%s**, the bot reloads its data once Pocketbase is back\n\n%s", key, restoredBy), c.BotEnv, nil))
+
+	go func() {
+		title := "Backup restored"
+		description := fmt.Sprintf("Restored backup **%s** and reloaded the data\n\n%s", key, restoredBy)
+		if err := c.reload(ctx); err != nil {
+			log.Printf("Error reloading data after restoring %s: %v", key, err)
+			title = "Backup restored, reload failed"
+			description = fmt.Sprintf("Restored backup **%s** but could not reload the data: %v\nRestart the bot once Pocketbase is back to load it.\n\n%s", key, err, restoredBy)
+		}
+		c.edit(s, i, responses.CreateBaseEmbed(title, description, c.BotEnv, nil))
+	}()
+}
+
+// reload waits for Pocketbase to come back from the restore and loads every record again
+func (c *BackupCommand) reload(ctx context.Context) error {
+	var err error
+	for range reloadAttempts {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(reloadDelay):
+		}
+		err = c.Reload(ctx)
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
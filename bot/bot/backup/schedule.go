@@ -0,0 +1,102 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/arinji2/dasa-bot/cron"
+	"github.com/arinji2/dasa-bot/pb"
+	responses "github.com/arinji2/dasa-bot/responses"
+	"github.com/bwmarrin/discordgo"
+)
+
+// RunSchedule takes a backup and prunes the old ones every time schedule fires,
+// posting how it went to the admin channel, until ctx is cancelled
+func (c *BackupCommand) RunSchedule(ctx context.Context, s *discordgo.Session, schedule *cron.Schedule) {
+	for {
+		next := schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Printf("Backup schedule %q never fires, no backups will be taken", schedule)
+			return
+		}
+		log.Printf("Next scheduled backup at %s", next.Format(time.RFC1123))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		c.scheduledBackup(ctx, s, schedule)
+	}
+}
+
+func (c *BackupCommand) scheduledBackup(ctx context.Context, s *discordgo.Session, schedule *cron.Schedule) {
+	started := time.Now()
+	backupName, err := c.PbAdmin.CreateBackup(ctx, pb.ScheduledBackupUser)
+	if err != nil {
+		log.Printf("Error creating scheduled backup: %v", err)
+		c.alert(s, fmt.Sprintf("The scheduled backup could not be taken: %v\n\nTake one by hand with `/backup create`.", err))
+		return
+	}
+	log.Printf("Created scheduled backup %s", backupName)
+
+	fields := []*discordgo.MessageEmbedField{
+		{Name: "Backup", Value: backupName, Inline: true},
+		{Name: "Took", Value: time.Since(started).Round(time.Second).String(), Inline: true},
+		{Name: "Next", Value: describeNext(schedule), Inline: true},
+	}
+
+	pruned, err := pb.PruneBackups(ctx, c.PbAdmin, c.Retention)
+	if err != nil {
+		log.Printf("Error pruning backups: %v", err)
+		c.alert(s, fmt.Sprintf("Took backup **%s** but could not delete the old ones: %v", backupName, err))
+		return
+	}
+	deleted := make([]string, len(pruned))
+	for idx, backup := range pruned {
+		deleted[idx] = backup.Key
+	}
+	if len(deleted) > 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  fmt.Sprintf("Deleted (%d)", len(deleted)),
+			Value: truncate(strings.Join(deleted, "\n"), 1024),
+		})
+	}
+
+	embed := responses.CreateBaseEmbed("Scheduled backup", c.Retention.String(), c.BotEnv, fields)
+	_, err = s.ChannelMessageSendEmbed(c.BotEnv.AdminChannel, embed)
+	if err != nil {
+		log.Printf("Error posting scheduled backup summary: %v", err)
+	}
+}
+
+// alert posts a failed scheduled backup to the admin channel, pinging the moderators
+func (c *BackupCommand) alert(s *discordgo.Session, description string) {
+	mentions := make([]string, len(c.BotEnv.ModRole))
+	for idx, role := range c.BotEnv.ModRole {
+		mentions[idx] = fmt.Sprintf("<@&%s>", role)
+	}
+	_, err := s.ChannelMessageSendComplex(c.BotEnv.AdminChannel, &discordgo.MessageSend{
+		Content: strings.Join(mentions, " "),
+		Embeds:  []*discordgo.MessageEmbed{responses.CreateBaseEmbed("Scheduled backup failed", description, c.BotEnv, nil)},
+		AllowedMentions: &discordgo.MessageAllowedMentions{
+			Roles: c.BotEnv.ModRole,
+		},
+	})
+	if err != nil {
+		log.Printf("Error posting scheduled backup alert: %v", err)
+	}
+}
+
+func describeNext(schedule *cron.Schedule) string {
+	next := schedule.Next(time.Now())
+	if next.IsZero() {
+		return "Never"
+	}
+	return fmt.Sprintf("<t:%d:R>", next.Unix())
+}
@@ -2,6 +2,7 @@
 package bot
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -9,10 +10,17 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/arinji2/dasa-bot/bot/backup"
+	"github.com/arinji2/dasa-bot/bot/branch"
+	"github.com/arinji2/dasa-bot/bot/college"
+	"github.com/arinji2/dasa-bot/bot/dataset"
+	"github.com/arinji2/dasa-bot/bot/export"
+	"github.com/arinji2/dasa-bot/bot/imports"
 	"github.com/arinji2/dasa-bot/bot/insert"
 	rank "github.com/arinji2/dasa-bot/bot/ranks"
 	"github.com/arinji2/dasa-bot/env"
 	"github.com/arinji2/dasa-bot/pb"
+	responses "github.com/arinji2/dasa-bot/responses"
 	"github.com/bwmarrin/discordgo"
 )
 
@@ -21,15 +29,26 @@ type Bot struct {
 	GuildID  string
 	Commands []*discordgo.ApplicationCommand
 	BotEnv   env.Bot
+
+	// ctx is handed to every interaction handler and is cancelled on shutdown
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 var (
-	PbAdmin       *pb.PocketbaseAdmin
-	RankCommand   rank.RankCommand
-	InsertCommand insert.InsertCommand
-	ModRole       []string
-	BotChannel    string
-	AdminChannel  string
+	PbAdmin pb.Store
+	// Data is the dataset every command reads from, refreshes publish into it
+	Data           = dataset.New()
+	RankCommand    rank.RankCommand
+	InsertCommand  insert.InsertCommand
+	CollegeCommand college.CollegeCommand
+	BranchCommand  branch.BranchCommand
+	ImportsCommand imports.ImportsCommand
+	BackupCommand  backup.BackupCommand
+	ExportCommand  export.ExportCommand
+	ModRole        []string
+	BotChannel     string
+	AdminChannel   string
 )
 
 func NewBot(bot env.Bot) (*Bot, error) {
@@ -41,16 +60,66 @@ func NewBot(bot env.Bot) (*Bot, error) {
 	ModRole = bot.ModRole
 	BotChannel = bot.BotChannel
 	AdminChannel = bot.AdminChannel
-	return &Bot{Session: s, GuildID: bot.GuildID, BotEnv: bot}, nil
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Bot{Session: s, GuildID: bot.GuildID, BotEnv: bot, ctx: ctx, cancel: cancel}, nil
 }
 
-func (b *Bot) Run(pbAdmin *pb.PocketbaseAdmin) {
+func (b *Bot) Run(pbAdmin pb.Store, backupEnv env.Backup) {
 	log.Println("Starting bot...")
 	b.Session.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
 		log.Printf("Logged in as: %v#%v", s.State.User.Username, s.State.User.Discriminator)
 	})
 	PbAdmin = pbAdmin
-	refreshData(&b.BotEnv)
+	RankCommand = rank.RankCommand{
+		Data:       Data,
+		PbAdmin:    PbAdmin,
+		BotEnv:     b.BotEnv,
+		BotChannel: BotChannel,
+	}
+	InsertCommand = insert.InsertCommand{
+		Data:      Data,
+		PbAdmin:   PbAdmin,
+		BotEnv:    b.BotEnv,
+		Refresh:   refreshData,
+		Retention: pb.NewRetention(backupEnv),
+	}
+	CollegeCommand = college.CollegeCommand{
+		Data:    Data,
+		PbAdmin: PbAdmin,
+		BotEnv:  b.BotEnv,
+		Refresh: refreshData,
+	}
+	BranchCommand = branch.BranchCommand{
+		Data:    Data,
+		PbAdmin: PbAdmin,
+		BotEnv:  b.BotEnv,
+		Refresh: refreshData,
+	}
+	ImportsCommand = imports.ImportsCommand{
+		PbAdmin: PbAdmin,
+		BotEnv:  b.BotEnv,
+	}
+	BackupCommand = backup.BackupCommand{
+		PbAdmin:   PbAdmin,
+		BotEnv:    b.BotEnv,
+		Retention: pb.NewRetention(backupEnv),
+		Reload:    reloadData,
+	}
+	ExportCommand = export.ExportCommand{
+		PbAdmin: PbAdmin,
+		BotEnv:  b.BotEnv,
+	}
+	err := refreshData(b.ctx)
+	if err != nil {
+		log.Panicf("Cannot load data: %v", err)
+	}
+	go dataSyncer.runSync(b.ctx)
+	if subscriber, ok := PbAdmin.(pb.Subscriber); ok {
+		go dataSyncer.runRealtime(b.ctx, subscriber)
+	}
+	if backupEnv.Schedule != nil {
+		go BackupCommand.RunSchedule(b.ctx, b.Session, backupEnv.Schedule)
+	}
 	createdCommands := b.registerCommands()
 	b.Session.UpdateCustomStatus("Padhlo chahe kahi se, selection hoga dasa se")
 	b.Commands = createdCommands
@@ -83,6 +152,7 @@ func (b *Bot) Run(pbAdmin *pb.PocketbaseAdmin) {
 	<-stop
 
 	log.Println("\nShutting down gracefully...")
+	b.cancel()
 
 	if err := b.Session.Close(); err != nil {
 		log.Printf("Error closing Discord session: %v", err)
@@ -94,6 +164,8 @@ func (b *Bot) Run(pbAdmin *pb.PocketbaseAdmin) {
 }
 
 var (
+	minImportsCount = 1.0
+
 	commands = []*discordgo.ApplicationCommand{
 		{
 			Name:        "refresh-data",
@@ -175,12 +247,12 @@ var (
 		},
 		{
 			Name:        "insert",
-			Description: "Inserts rank data based on inserted PDF with year and round",
+			Description: "Inserts rank data from a CSV, XLSX or DASA cutoff PDF with year and round",
 			Type:        discordgo.ChatApplicationCommand,
 			Options: []*discordgo.ApplicationCommandOption{
 				{
 					Name:         "file",
-					Description:  "CSV file of ranks",
+					Description:  "CSV, XLSX or DASA cutoff PDF file of ranks",
 					Type:         discordgo.ApplicationCommandOptionAttachment,
 					Required:     true,
 					Autocomplete: false,
@@ -188,25 +260,345 @@ var (
 
 				{
 					Name:         "year",
-					Description:  "Year of the ranks",
+					Description:  "Year of the ranks, unless the file has a year column",
 					Type:         discordgo.ApplicationCommandOptionString,
-					Required:     true,
+					Required:     false,
 					Autocomplete: false,
 				},
 
 				{
 					Name:         "round",
-					Description:  "Round of the ranks",
+					Description:  "Round of the ranks, unless the file has a round column",
 					Type:         discordgo.ApplicationCommandOptionString,
-					Required:     true,
+					Required:     false,
+					Autocomplete: false,
+				},
+
+				{
+					Name:        "mode",
+					Description: "What to do with ranks that already exist, skipped by default",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Required:    false,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "Insert, skip existing ranks", Value: "insert"},
+						{Name: "Upsert, update changed opening and closing ranks", Value: "upsert"},
+						{Name: "Replace, delete the round's ranks of the file's CIWG categories first", Value: "replace"},
+					},
+				},
+
+				{
+					Name:         "dry_run",
+					Description:  "Preview what would be inserted without writing anything",
+					Type:         discordgo.ApplicationCommandOptionBoolean,
+					Required:     false,
 					Autocomplete: false,
 				},
 			},
 		},
+		{
+			Name:        "college",
+			Description: "Manage colleges",
+			Type:        discordgo.ChatApplicationCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "add",
+					Description: "Add a college",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        "name",
+							Description: "Full name of the college",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Required:    true,
+						},
+						{
+							Name:        "alias",
+							Description: "Short names of the college, comma separated",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Required:    false,
+						},
+					},
+				},
+				{
+					Name:        "edit",
+					Description: "Rename a college or change its alias",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:         "college",
+							Description:  "College to edit",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
+						},
+						{
+							Name:        "name",
+							Description: "New full name, the old one is kept as an alias",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Required:    false,
+						},
+						{
+							Name:        "alias",
+							Description: "New short names, comma separated, or - to clear them",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Required:    false,
+						},
+					},
+				},
+				{
+					Name:        "remove",
+					Description: "Remove a college that has no ranks",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:         "college",
+							Description:  "College to remove",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
+						},
+					},
+				},
+				{
+					Name:        "show",
+					Description: "Show a college with its aliases and ranks",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:         "college",
+							Description:  "College to show",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
+						},
+					},
+				},
+				{
+					Name:        "merge",
+					Description: "Move every rank of a duplicate college to another and keep its name as an alias",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:         "from",
+							Description:  "Duplicate college, deleted after the merge",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
+						},
+						{
+							Name:         "to",
+							Description:  "College that keeps the ranks",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:        "branch",
+			Description: "Manage branches",
+			Type:        discordgo.ChatApplicationCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "list",
+					Description: "List branches, or the ones that look like duplicates",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        "search",
+							Description: "Only list branches whose code, name or alias contains this",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Required:    false,
+						},
+						{
+							Name:        "duplicates",
+							Description: "Group branches that only differ in case, spacing or punctuation",
+							Type:        discordgo.ApplicationCommandOptionBoolean,
+							Required:    false,
+						},
+					},
+				},
+				{
+					Name:        "edit",
+					Description: "Rename a branch or change its code",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:         "branch",
+							Description:  "Branch to edit",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
+						},
+						{
+							Name:        "name",
+							Description: "New name, the old name and code are kept as an alias",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Required:    false,
+						},
+						{
+							Name:        "code",
+							Description: "New code",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Required:    false,
+						},
+					},
+				},
+				{
+					Name:        "remove",
+					Description: "Remove a branch that has no ranks",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:         "branch",
+							Description:  "Branch to remove",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
+						},
+					},
+				},
+				{
+					Name:        "merge",
+					Description: "Move every rank of a duplicate branch to another and keep its name as an alias",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:         "from",
+							Description:  "Duplicate branch, deleted after the merge",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
+						},
+						{
+							Name:         "to",
+							Description:  "Branch that keeps the ranks, of the same category",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:        "imports",
+			Description: "Browse the history of /insert",
+			Type:        discordgo.ChatApplicationCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "list",
+					Description: "List the latest imports",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        "count",
+							Description: "How many imports to list, 10 by default",
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Required:    false,
+							MinValue:    &minImportsCount,
+							MaxValue:    25,
+						},
+					},
+				},
+				{
+					Name:        "show",
+					Description: "Show everything recorded about one import",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:         "id",
+							Description:  "Import id",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:        "backup",
+			Description: "Manage the Pocketbase backups",
+			Type:        discordgo.ChatApplicationCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "list",
+					Description: "List every backup, newest first",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+				},
+				{
+					Name:        "create",
+					Description: "Back up Pocketbase now",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+				},
+				{
+					Name:        "delete",
+					Description: "Delete a backup",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:         "key",
+							Description:  "Backup key",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
+						},
+					},
+				},
+				{
+					Name:        "restore",
+					Description: "Replace all data with a backup",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:         "key",
+							Description:  "Backup key",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
+						},
+					},
+				},
+				{
+					Name:        "download",
+					Description: "Download a backup as an attachment",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:         "key",
+							Description:  "Backup key",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:        "export",
+			Description: "Download every college, branch and rank as an archive",
+			Type:        discordgo.ChatApplicationCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "format",
+					Description: "Format of the files in the archive, JSON by default",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Required:    false,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "JSON", Value: "json"},
+						{Name: "CSV", Value: "csv"},
+					},
+				},
+			},
+		},
 	}
 
-	commandHandlers = map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate){
-		"refresh-data": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	commandHandlers = map[string]func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate){
+		"refresh-data": func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
 			err := checkPermissions(s, i)
 			if err != nil {
 				return
@@ -214,7 +606,12 @@ var (
 			switch i.Type {
 			case discordgo.InteractionApplicationCommand:
 				timeStart := time.Now()
-				refreshData(nil)
+				err := refreshData(ctx)
+				if err != nil {
+					log.Printf("Error refreshing data: %v", err)
+					responses.RespondWithEphemeralError(s, i, fmt.Sprintf("Could not refresh data: %v", err))
+					return
+				}
 				s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 					Type: discordgo.InteractionResponseChannelMessageWithSource,
 					Data: &discordgo.InteractionResponseData{
@@ -225,7 +622,7 @@ var (
 			}
 		},
 
-		"cutoff": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		"cutoff": func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
 			switch i.Type {
 			case discordgo.InteractionApplicationCommand:
 				RankCommand.HandleRankCutoffResponse(s, i)
@@ -234,7 +631,7 @@ var (
 			}
 		},
 
-		"analyze": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		"analyze": func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
 			switch i.Type {
 			case discordgo.InteractionApplicationCommand:
 				RankCommand.HandleAnalyzeResponse(s, i)
@@ -243,7 +640,98 @@ var (
 			}
 		},
 
-		"insert": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		"insert": func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+			switch i.Type {
+			case discordgo.InteractionApplicationCommand:
+				err := checkChannel(s, i, true)
+				if err != nil {
+					return
+				}
+				err = checkPermissions(s, i)
+				if err != nil {
+					return
+				}
+				// the insert refreshes data itself once it has deferred its response
+				InsertCommand.HandleInsertResponse(ctx, s, i)
+			}
+		},
+
+		"college": func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+			switch i.Type {
+			case discordgo.InteractionApplicationCommand:
+				err := checkChannel(s, i, true)
+				if err != nil {
+					return
+				}
+				err = checkPermissions(s, i)
+				if err != nil {
+					return
+				}
+				CollegeCommand.HandleCollegeResponse(ctx, s, i)
+			case discordgo.InteractionApplicationCommandAutocomplete:
+				CollegeCommand.HandleCollegeAutocomplete(s, i)
+			}
+		},
+
+		"branch": func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+			switch i.Type {
+			case discordgo.InteractionApplicationCommand:
+				err := checkChannel(s, i, true)
+				if err != nil {
+					return
+				}
+				err = checkPermissions(s, i)
+				if err != nil {
+					return
+				}
+				BranchCommand.HandleBranchResponse(ctx, s, i)
+			case discordgo.InteractionApplicationCommandAutocomplete:
+				BranchCommand.HandleBranchAutocomplete(s, i)
+			}
+		},
+
+		"imports": func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+			switch i.Type {
+			case discordgo.InteractionApplicationCommand:
+				err := checkChannel(s, i, true)
+				if err != nil {
+					return
+				}
+				err = checkPermissions(s, i)
+				if err != nil {
+					return
+				}
+				ImportsCommand.HandleImportsResponse(ctx, s, i)
+			case discordgo.InteractionApplicationCommandAutocomplete:
+				// the history is only for moderators
+				if !isAdmin(i) {
+					return
+				}
+				ImportsCommand.HandleImportsAutocomplete(ctx, s, i)
+			}
+		},
+
+		"backup": func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+			switch i.Type {
+			case discordgo.InteractionApplicationCommand:
+				err := checkChannel(s, i, true)
+				if err != nil {
+					return
+				}
+				err = checkPermissions(s, i)
+				if err != nil {
+					return
+				}
+				BackupCommand.HandleBackupResponse(ctx, s, i)
+			case discordgo.InteractionApplicationCommandAutocomplete:
+				if !isAdmin(i) {
+					return
+				}
+				BackupCommand.HandleBackupAutocomplete(ctx, s, i)
+			}
+		},
+
+		"export": func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
 			switch i.Type {
 			case discordgo.InteractionApplicationCommand:
 				err := checkChannel(s, i, true)
@@ -254,8 +742,7 @@ var (
 				if err != nil {
 					return
 				}
-				refreshData(&InsertCommand.BotEnv)
-				InsertCommand.HandleInsertResponse(s, i)
+				ExportCommand.HandleExportResponse(ctx, s, i)
 			}
 		},
 	}
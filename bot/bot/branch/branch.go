@@ -0,0 +1,161 @@
+// Package branch contains the logic for the Branch command
+package branch
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/arinji2/dasa-bot/bot/dataset"
+	"github.com/arinji2/dasa-bot/env"
+	"github.com/arinji2/dasa-bot/pb"
+	responses "github.com/arinji2/dasa-bot/responses"
+	"github.com/bwmarrin/discordgo"
+)
+
+type BranchCommand struct {
+	Data    *dataset.Dataset
+	PbAdmin pb.Store
+	BotEnv  env.Bot
+	// Refresh publishes a written branch instead of waiting for the next sync
+	Refresh func(ctx context.Context) error
+}
+
+func (c *BranchCommand) HandleBranchResponse(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	subcommand := i.ApplicationCommandData().Options[0]
+	switch subcommand.Name {
+	case "list":
+		c.handleList(s, i, subcommand)
+	case "edit":
+		c.handleEdit(ctx, s, i, subcommand)
+	case "remove":
+		c.handleRemove(ctx, s, i, subcommand)
+	case "merge":
+		c.handleMerge(ctx, s, i, subcommand)
+	}
+}
+
+// refresh brings Data up to date once a branch has been written
+func (c *BranchCommand) refresh(ctx context.Context) {
+	err := c.Refresh(ctx)
+	if err != nil {
+		log.Printf("Error refreshing data: %v", err)
+	}
+}
+
+// HandleBranchAutocomplete suggests branches for the focused option of any subcommand
+func (c *BranchCommand) HandleBranchAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	subcommand := i.ApplicationCommandData().Options[0]
+	var searchTerm string
+	for _, option := range subcommand.Options {
+		if option.Focused {
+			searchTerm = strings.ToLower(option.StringValue())
+		}
+	}
+
+	data := c.Data.Load()
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, v := range sortedBranches(data) {
+		if len(choices) >= 25 {
+			break
+		}
+		if searchTerm == "" || matchesSearch(data, v, searchTerm) {
+			name := fmt.Sprintf("%s (%s) %s", v.Code, categoryName(v.Ciwg), v.Name)
+			if len(name) > 100 {
+				name = name[:97] + "..."
+			}
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+				Name:  name,
+				Value: v.ID,
+			})
+		}
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to branch autocomplete: %v", err)
+	}
+}
+
+// matchesSearch reports whether the code, name or an alias of branch contains searchTerm
+func matchesSearch(data *dataset.Snapshot, branch pb.BranchCollection, searchTerm string) bool {
+	return strings.Contains(strings.ToLower(branch.Code), searchTerm) ||
+		strings.Contains(strings.ToLower(branch.Name), searchTerm) ||
+		slices.ContainsFunc(data.BranchAliases(branch.ID), func(alias pb.BranchAliasCollection) bool {
+			return strings.Contains(strings.ToLower(alias.Name), searchTerm) || strings.Contains(strings.ToLower(alias.Code), searchTerm)
+		})
+}
+
+func (c *BranchCommand) handleMerge(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, subcommand *discordgo.ApplicationCommandInteractionDataOption) {
+	options := subcommandOptions(subcommand)
+	data := c.Data.Load()
+	from, ok := data.Branch(options["from"])
+	if !ok {
+		responses.RespondWithEphemeralError(s, i, "No branch found to merge from, pick one from the list")
+		return
+	}
+	to, ok := data.Branch(options["to"])
+	if !ok {
+		responses.RespondWithEphemeralError(s, i, "No branch found to merge into, pick one from the list")
+		return
+	}
+	if from.ID == to.ID {
+		responses.RespondWithEphemeralError(s, i, "A branch cannot be merged into itself")
+		return
+	}
+	// the category decides which cutoffs a rank shows up in
+	if from.Ciwg != to.Ciwg {
+		responses.RespondWithEphemeralError(s, i, fmt.Sprintf("**%s** is %s and **%s** is %s, only branches of the same category can be merged",
+			from.Name, categoryName(from.Ciwg), to.Name, categoryName(to.Ciwg)))
+		return
+	}
+
+	// moving the ranks takes longer than an interaction may go unanswered
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+	if err != nil {
+		log.Printf("Error deferring branch merge: %v", err)
+		return
+	}
+
+	result, mergeErr := c.mergeBranches(ctx, data, from, to)
+	var embed *discordgo.MessageEmbed
+	if mergeErr != nil {
+		log.Printf("Error merging branch %s into %s: %v", from.ID, to.ID, mergeErr)
+		embed = responses.CreateBaseEmbed("Error merging branches", mergeErr.Error(), c.BotEnv, nil)
+	} else {
+		fields := []*discordgo.MessageEmbedField{
+			{Name: "Moved ranks", Value: fmt.Sprintf("%d", result.Moved), Inline: true},
+			{Name: "Dropped ranks", Value: fmt.Sprintf("%d", result.Dropped), Inline: true},
+			{Name: "Aliases", Value: fmt.Sprintf("%d", result.Aliases), Inline: true},
+		}
+		if len(result.Warnings) > 0 {
+			fields = append(fields, &discordgo.MessageEmbedField{Name: "Warnings", Value: truncate(strings.Join(result.Warnings, "\n"), 1024)})
+		}
+		embed = responses.CreateBaseEmbed(
+			"Branches merged",
+			fmt.Sprintf("**%s** (`%s`) was merged into **%s** (`%s`), its name and code now find **%s** in /insert", from.Name, from.Code, to.Name, to.Code, to.Name),
+			c.BotEnv,
+			fields,
+		)
+	}
+
+	_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+	if err != nil {
+		log.Printf("Error editing branch merge response: %v", err)
+	}
+	// a merge that failed after moving the ranks still changed them
+	if mergeErr == nil || result.Moved > 0 || result.Dropped > 0 || result.Aliases > 0 {
+		c.refresh(ctx)
+	}
+}
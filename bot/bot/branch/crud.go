@@ -0,0 +1,232 @@
+package branch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/arinji2/dasa-bot/bot/dataset"
+	"github.com/arinji2/dasa-bot/pb"
+	responses "github.com/arinji2/dasa-bot/responses"
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxDescription is the most characters Discord shows in an embed description
+const maxDescription = 4096
+
+func subcommandOptions(subcommand *discordgo.ApplicationCommandInteractionDataOption) map[string]string {
+	options := make(map[string]string, len(subcommand.Options))
+	for _, option := range subcommand.Options {
+		if option.Type == discordgo.ApplicationCommandOptionString {
+			options[option.Name] = strings.TrimSpace(option.StringValue())
+		}
+	}
+	return options
+}
+
+func (c *BranchCommand) handleList(s *discordgo.Session, i *discordgo.InteractionCreate, subcommand *discordgo.ApplicationCommandInteractionDataOption) {
+	var duplicates bool
+	for _, option := range subcommand.Options {
+		if option.Name == "duplicates" {
+			duplicates = option.BoolValue()
+		}
+	}
+	data := c.Data.Load()
+	if duplicates {
+		c.listDuplicates(s, i, data)
+		return
+	}
+
+	searchTerm := strings.ToLower(subcommandOptions(subcommand)["search"])
+	var lines []string
+	for _, branch := range sortedBranches(data) {
+		if searchTerm == "" || matchesSearch(data, branch, searchTerm) {
+			lines = append(lines, describeBranch(data, branch))
+		}
+	}
+	if len(lines) == 0 {
+		responses.RespondWithEphemeralError(s, i, "No branch matches the search")
+		return
+	}
+	responses.RespondWithEmbed(s, i, c.BotEnv, fmt.Sprintf("%d branches", len(lines)), joinLines(lines, maxDescription), nil)
+}
+
+func (c *BranchCommand) listDuplicates(s *discordgo.Session, i *discordgo.InteractionCreate, data *dataset.Snapshot) {
+	groups := duplicateGroups(data)
+	if len(groups) == 0 {
+		responses.RespondWithEmbed(s, i, c.BotEnv, "No duplicate branches", "Every branch has its own code and name", nil)
+		return
+	}
+
+	// an embed holds at most 25 fields
+	var fields []*discordgo.MessageEmbedField
+	for _, group := range groups[:min(len(groups), 25)] {
+		lines := make([]string, len(group))
+		for idx, branch := range group {
+			lines[idx] = describeBranch(data, branch)
+		}
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  fmt.Sprintf("%s (%s) %s", group[0].Code, categoryName(group[0].Ciwg), truncate(group[0].Name, 200)),
+			Value: joinLines(lines, 1024),
+		})
+	}
+	description := fmt.Sprintf("Found %d groups of branches that only differ in case, spacing or punctuation. The first of each group has the most ranks, merge the others into it with `/branch merge`.", len(groups))
+	if len(groups) > len(fields) {
+		description += fmt.Sprintf(" Showing the first %d.", len(fields))
+	}
+	responses.RespondWithEmbed(s, i, c.BotEnv, "Duplicate branches", description, fields)
+}
+
+func (c *BranchCommand) handleEdit(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, subcommand *discordgo.ApplicationCommandInteractionDataOption) {
+	options := subcommandOptions(subcommand)
+	data := c.Data.Load()
+	branch, ok := data.Branch(options["branch"])
+	if !ok {
+		responses.RespondWithEphemeralError(s, i, "No branch found to edit, pick one from the list")
+		return
+	}
+	name := options["name"]
+	code := options["code"]
+	if name == "" && code == "" {
+		responses.RespondWithEphemeralError(s, i, "Give a new name or code to edit the branch")
+		return
+	}
+	if name == "" {
+		name = branch.Name
+	}
+	if code == "" {
+		code = branch.Code
+	}
+	if existing, ok := nameTaken(data, name, code, branch); ok {
+		responses.RespondWithEphemeralError(s, i, fmt.Sprintf("**%s** (`%s`) already finds the branch **%s** (`%s`), merge them with `/branch merge` instead",
+			name, code, existing.Name, existing.ID))
+		return
+	}
+
+	updated, err := c.PbAdmin.UpdateBranch(ctx, branch.ID, pb.BranchCreateRequest{Name: name, Code: code, Ciwg: branch.Ciwg})
+	if err != nil {
+		log.Printf("Error updating branch %s: %v", branch.ID, err)
+		responses.RespondWithEphemeralError(s, i, fmt.Sprintf("Could not edit the branch: %v", err))
+		return
+	}
+
+	description := fmt.Sprintf("Edited **%s** (`%s`)", updated.Name, updated.Code)
+	// files still use the old name and code, keep them finding the branch
+	if !strings.EqualFold(updated.Name, branch.Name) || !strings.EqualFold(updated.Code, branch.Code) {
+		if _, aliased := data.BranchByAlias(branch.Name, branch.Code, branch.Ciwg); !aliased {
+			_, err := c.PbAdmin.CreateBranchAlias(ctx, pb.BranchAliasCreateRequest{Name: branch.Name, Code: branch.Code, Branch: branch.ID})
+			if err != nil {
+				log.Printf("Error recording alias %s: %v", branch.Name, err)
+				description += fmt.Sprintf("\nCould not keep the old name **%s** (`%s`) as an alias: %v", branch.Name, branch.Code, err)
+			} else {
+				description += fmt.Sprintf("\nThe old name **%s** (`%s`) is kept as an alias", branch.Name, branch.Code)
+			}
+		}
+	}
+	responses.RespondWithEmbed(s, i, c.BotEnv, "Branch edited", description, nil)
+	c.refresh(ctx)
+}
+
+// nameTaken finds a branch other than branch of its category that name and
+// code already lead to
+func nameTaken(data *dataset.Snapshot, name, code string, branch pb.BranchCollection) (pb.BranchCollection, bool) {
+	for _, other := range data.BranchesByCode(code) {
+		if other.ID != branch.ID && other.Ciwg == branch.Ciwg && strings.EqualFold(other.Name, name) {
+			return other, true
+		}
+	}
+	if other, ok := data.BranchByAlias(name, code, branch.Ciwg); ok && other.ID != branch.ID {
+		return other, true
+	}
+	return pb.BranchCollection{}, false
+}
+
+func (c *BranchCommand) handleRemove(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, subcommand *discordgo.ApplicationCommandInteractionDataOption) {
+	options := subcommandOptions(subcommand)
+	branch, ok := c.Data.Load().Branch(options["branch"])
+	if !ok {
+		responses.RespondWithEphemeralError(s, i, "No branch found to remove, pick one from the list")
+		return
+	}
+
+	err := c.PbAdmin.DeleteBranch(ctx, branch.ID)
+	var inUse *pb.InUseError
+	if errors.As(err, &inUse) {
+		responses.RespondWithEphemeralError(s, i, fmt.Sprintf("**%s** still has %d ranks, move them to another branch with `/branch merge` first", branch.Name, inUse.Ranks))
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting branch %s: %v", branch.ID, err)
+		responses.RespondWithEphemeralError(s, i, fmt.Sprintf("Could not remove the branch: %v", err))
+		return
+	}
+	responses.RespondWithEmbed(s, i, c.BotEnv, "Branch removed", fmt.Sprintf("Removed **%s** (`%s`) and its aliases", branch.Name, branch.Code), nil)
+	c.refresh(ctx)
+}
+
+// sortedBranches orders the branches of data by code, category and name
+func sortedBranches(data *dataset.Snapshot) []pb.BranchCollection {
+	branches := slices.Clone(data.Branches)
+	slices.SortFunc(branches, func(a, b pb.BranchCollection) int {
+		if order := strings.Compare(strings.ToLower(a.Code), strings.ToLower(b.Code)); order != 0 {
+			return order
+		}
+		if a.Ciwg != b.Ciwg {
+			if a.Ciwg {
+				return 1
+			}
+			return -1
+		}
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	return branches
+}
+
+// describeBranch is one line about a branch with the id to pass to /branch
+func describeBranch(data *dataset.Snapshot, branch pb.BranchCollection) string {
+	return fmt.Sprintf("`%s` %s, %s, %d ranks, `%s`", branch.Code, branch.Name, categoryName(branch.Ciwg), len(data.BranchRanks(branch.ID)), branch.ID)
+}
+
+func categoryName(ciwg bool) string {
+	if ciwg {
+		return "CIWG"
+	}
+	return "Non-CIWG"
+}
+
+// joinLines joins as many lines as fit in limit characters and counts the rest
+func joinLines(lines []string, limit int) string {
+	var b strings.Builder
+	length := 0
+	for idx, line := range lines {
+		line = truncate(line, limit)
+		if idx > 0 {
+			line = "\n" + line
+		}
+		// keep room to say how many lines were left out
+		more := ""
+		if rest := len(lines) - idx - 1; rest > 0 {
+			more = fmt.Sprintf("\n…and %d more", rest)
+		}
+		if idx > 0 && length+utf8.RuneCountInString(line+more) > limit {
+			fmt.Fprintf(&b, "\n…and %d more", len(lines)-idx)
+			break
+		}
+		b.WriteString(line)
+		length += utf8.RuneCountInString(line)
+	}
+	return b.String()
+}
+
+// truncate cuts text to at most limit characters
+func truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-1]) + "…"
+}
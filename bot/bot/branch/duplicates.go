@@ -0,0 +1,57 @@
+package branch
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/arinji2/dasa-bot/bot/dataset"
+	"github.com/arinji2/dasa-bot/pb"
+)
+
+// duplicateGroups finds branches that are likely the same one inserted twice.
+// /insert creates a branch whenever a name, code and category do not match an
+// existing one exactly, so names that only differ in case, spacing or
+// punctuation pile up. Each group has the branch with the most ranks first,
+// the one the others should be merged into.
+func duplicateGroups(data *dataset.Snapshot) [][]pb.BranchCollection {
+	byKey := make(map[string][]pb.BranchCollection)
+	var keys []string
+	for _, branch := range sortedBranches(data) {
+		key := duplicateKey(branch)
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		byKey[key] = append(byKey[key], branch)
+	}
+
+	var groups [][]pb.BranchCollection
+	for _, key := range keys {
+		group := byKey[key]
+		if len(group) < 2 {
+			continue
+		}
+		slices.SortStableFunc(group, func(a, b pb.BranchCollection) int {
+			return len(data.BranchRanks(b.ID)) - len(data.BranchRanks(a.ID))
+		})
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// duplicateKey is the category, code and name of a branch with case, spacing
+// and punctuation left out
+func duplicateKey(branch pb.BranchCollection) string {
+	code := strings.Join(words(branch.Code), "")
+	name := strings.Join(words(branch.Name), " ")
+	return fmt.Sprintf("%t|%s|%s", branch.Ciwg, code, name)
+}
+
+// words splits text into lowercase words of letters and digits, reading & as and
+func words(text string) []string {
+	text = strings.ReplaceAll(strings.ToLower(text), "&", " and ")
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,91 @@
+package branch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/arinji2/dasa-bot/bot/dataset"
+	"github.com/arinji2/dasa-bot/pb"
+)
+
+// mergeResult is what merging two branches did
+type mergeResult struct {
+	Moved int
+	// Dropped counts ranks the branch merged into already had for the same college and round
+	Dropped  int
+	Aliases  int
+	Warnings []string
+}
+
+// mergeBranches moves every rank of from to to and deletes from, keeping its
+// name and code as an alias of to. The ranks are moved completely or not at all.
+func (c *BranchCommand) mergeBranches(ctx context.Context, data *dataset.Snapshot, from, to pb.BranchCollection) (mergeResult, error) {
+	var result mergeResult
+
+	existing := make(map[string]bool)
+	for _, rank := range data.BranchRanks(to.ID) {
+		existing[mergeKey(rank)] = true
+	}
+	var moves []pb.RankMoveRequest
+	var dropped []pb.RankCollection
+	for _, rank := range data.BranchRanks(from.ID) {
+		if existing[mergeKey(rank)] {
+			dropped = append(dropped, rank)
+			continue
+		}
+		moves = append(moves, pb.RankMoveRequest{ID: rank.ID, Branch: to.ID})
+	}
+
+	m := &pb.RankMover{
+		Store:  c.PbAdmin,
+		Revert: func(id string) pb.RankMoveRequest { return pb.RankMoveRequest{ID: id, Branch: from.ID} },
+	}
+	if err := m.Move(ctx, moves); err != nil {
+		return result, err
+	}
+	if err := m.Delete(ctx, dropped); err != nil {
+		return result, err
+	}
+	result.Moved = len(moves)
+	result.Dropped = len(dropped)
+
+	// aliases cascade with their branch, the ones of from have to move before it is deleted
+	for _, alias := range data.BranchAliases(from.ID) {
+		_, err := c.PbAdmin.UpdateBranchAlias(ctx, alias.ID, pb.BranchAliasCreateRequest{Name: alias.Name, Code: alias.Code, Branch: to.ID})
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Could not keep alias **%s** (`%s`): %v", alias.Name, alias.Code, err))
+			continue
+		}
+		result.Aliases++
+	}
+
+	if keepsName(data, from, to) {
+		_, err := c.PbAdmin.CreateBranchAlias(ctx, pb.BranchAliasCreateRequest{Name: from.Name, Code: from.Code, Branch: to.ID})
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Could not record alias **%s** (`%s`): %v", from.Name, from.Code, err))
+		} else {
+			result.Aliases++
+		}
+	}
+
+	err := c.PbAdmin.DeleteBranch(ctx, from.ID)
+	if err != nil {
+		return result, fmt.Errorf("moved %d ranks but failed to delete %s: %w", result.Moved, from.Name, err)
+	}
+	return result, nil
+}
+
+// keepsName reports whether the name and code of from need an alias to keep
+// finding to, /insert already matches them if they only differ in case
+func keepsName(data *dataset.Snapshot, from, to pb.BranchCollection) bool {
+	if strings.EqualFold(from.Name, to.Name) && strings.EqualFold(from.Code, to.Code) {
+		return false
+	}
+	_, aliased := data.BranchByAlias(from.Name, from.Code, from.Ciwg)
+	return !aliased
+}
+
+func mergeKey(rank pb.RankCollection) string {
+	return fmt.Sprintf("%d|%d|%s", rank.Year, rank.Round, rank.College)
+}
@@ -0,0 +1,156 @@
+// Package college contains the logic for the College command
+package college
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/arinji2/dasa-bot/bot/dataset"
+	"github.com/arinji2/dasa-bot/env"
+	"github.com/arinji2/dasa-bot/pb"
+	responses "github.com/arinji2/dasa-bot/responses"
+	"github.com/bwmarrin/discordgo"
+)
+
+type CollegeCommand struct {
+	Data    *dataset.Dataset
+	PbAdmin pb.Store
+	BotEnv  env.Bot
+	// Refresh publishes a written college instead of waiting for the next sync
+	Refresh func(ctx context.Context) error
+}
+
+func (c *CollegeCommand) HandleCollegeResponse(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	subcommand := i.ApplicationCommandData().Options[0]
+	switch subcommand.Name {
+	case "add":
+		c.handleAdd(ctx, s, i, subcommand)
+	case "edit":
+		c.handleEdit(ctx, s, i, subcommand)
+	case "remove":
+		c.handleRemove(ctx, s, i, subcommand)
+	case "show":
+		c.handleShow(s, i, subcommand)
+	case "merge":
+		c.handleMerge(ctx, s, i, subcommand)
+	}
+}
+
+// refresh brings Data up to date once a college has been written
+func (c *CollegeCommand) refresh(ctx context.Context) {
+	err := c.Refresh(ctx)
+	if err != nil {
+		log.Printf("Error refreshing data: %v", err)
+	}
+}
+
+// HandleCollegeAutocomplete suggests colleges for the focused option of any subcommand
+func (c *CollegeCommand) HandleCollegeAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	subcommand := i.ApplicationCommandData().Options[0]
+	var searchTerm string
+	for _, option := range subcommand.Options {
+		if option.Focused {
+			searchTerm = strings.ToLower(option.StringValue())
+		}
+	}
+
+	data := c.Data.Load()
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, v := range data.Colleges {
+		if len(choices) >= 25 {
+			break
+		}
+		if searchTerm == "" || strings.Contains(strings.ToLower(v.Alias), searchTerm) ||
+			strings.Contains(strings.ToLower(v.Name), searchTerm) ||
+			slices.ContainsFunc(data.CollegeAliasNames(v.ID), func(alias string) bool {
+				return strings.Contains(strings.ToLower(alias), searchTerm)
+			}) {
+			name := v.Name
+			if len(name) > 100 {
+				name = name[:97] + "..."
+			}
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+				Name:  name,
+				Value: v.ID,
+			})
+		}
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to college autocomplete: %v", err)
+	}
+}
+
+func (c *CollegeCommand) handleMerge(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, subcommand *discordgo.ApplicationCommandInteractionDataOption) {
+	options := make(map[string]string, len(subcommand.Options))
+	for _, option := range subcommand.Options {
+		options[option.Name] = option.StringValue()
+	}
+
+	data := c.Data.Load()
+	from, ok := data.College(options["from"])
+	if !ok {
+		responses.RespondWithEphemeralError(s, i, "No college found to merge from, pick one from the list")
+		return
+	}
+	to, ok := data.College(options["to"])
+	if !ok {
+		responses.RespondWithEphemeralError(s, i, "No college found to merge into, pick one from the list")
+		return
+	}
+	if from.ID == to.ID {
+		responses.RespondWithEphemeralError(s, i, "A college cannot be merged into itself")
+		return
+	}
+
+	// moving the ranks takes longer than an interaction may go unanswered
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+	if err != nil {
+		log.Printf("Error deferring college merge: %v", err)
+		return
+	}
+
+	result, mergeErr := c.mergeColleges(ctx, data, from, to)
+	var embed *discordgo.MessageEmbed
+	if mergeErr != nil {
+		log.Printf("Error merging college %s into %s: %v", from.ID, to.ID, mergeErr)
+		embed = responses.CreateBaseEmbed("Error merging colleges", mergeErr.Error(), c.BotEnv, nil)
+	} else {
+		fields := []*discordgo.MessageEmbedField{
+			{Name: "Moved ranks", Value: fmt.Sprintf("%d", result.Moved), Inline: true},
+			{Name: "Dropped ranks", Value: fmt.Sprintf("%d", result.Dropped), Inline: true},
+			{Name: "Aliases", Value: fmt.Sprintf("%d", result.Aliases), Inline: true},
+		}
+		if len(result.Warnings) > 0 {
+			fields = append(fields, &discordgo.MessageEmbedField{Name: "Warnings", Value: truncate(strings.Join(result.Warnings, "\n"), 1024)})
+		}
+		embed = responses.CreateBaseEmbed(
+			"Colleges merged",
+			fmt.Sprintf("**%s** was merged into **%s**, its name now finds **%s** in /cutoff and /insert", from.Name, to.Name, to.Name),
+			c.BotEnv,
+			fields,
+		)
+	}
+
+	_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+	if err != nil {
+		log.Printf("Error editing college merge response: %v", err)
+	}
+	// a merge that failed after moving the ranks still changed them
+	if mergeErr == nil || result.Moved > 0 || result.Dropped > 0 || result.Aliases > 0 {
+		c.refresh(ctx)
+	}
+}
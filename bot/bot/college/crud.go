@@ -0,0 +1,186 @@
+package college
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/arinji2/dasa-bot/bot/dataset"
+	"github.com/arinji2/dasa-bot/pb"
+	responses "github.com/arinji2/dasa-bot/responses"
+	"github.com/bwmarrin/discordgo"
+)
+
+// clearAlias is given as the alias option to remove the alias of a college
+const clearAlias = "-"
+
+func subcommandOptions(subcommand *discordgo.ApplicationCommandInteractionDataOption) map[string]string {
+	options := make(map[string]string, len(subcommand.Options))
+	for _, option := range subcommand.Options {
+		options[option.Name] = strings.TrimSpace(option.StringValue())
+	}
+	return options
+}
+
+// nameTaken finds a college other than exceptID that name already leads to
+func nameTaken(data *dataset.Snapshot, name, exceptID string) (pb.CollegeCollection, bool) {
+	if college, ok := data.CollegeByName(name); ok && college.ID != exceptID {
+		return college, true
+	}
+	if college, ok := data.CollegeByAlias(name); ok && college.ID != exceptID {
+		return college, true
+	}
+	return pb.CollegeCollection{}, false
+}
+
+func (c *CollegeCommand) handleAdd(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, subcommand *discordgo.ApplicationCommandInteractionDataOption) {
+	options := subcommandOptions(subcommand)
+	name := options["name"]
+	if name == "" {
+		responses.RespondWithEphemeralError(s, i, "A college needs a name")
+		return
+	}
+	if existing, ok := nameTaken(c.Data.Load(), name, ""); ok {
+		responses.RespondWithEphemeralError(s, i, fmt.Sprintf("**%s** already finds the college **%s**", name, existing.Name))
+		return
+	}
+
+	college, err := c.PbAdmin.CreateCollege(ctx, pb.CollegeCreateRequest{Name: name, Alias: options["alias"]})
+	if err != nil {
+		log.Printf("Error creating college %s: %v", name, err)
+		responses.RespondWithEphemeralError(s, i, fmt.Sprintf("Could not add the college: %v", err))
+		return
+	}
+	responses.RespondWithEmbed(s, i, c.BotEnv, "College added", fmt.Sprintf("Added **%s**", college.Name), describeCollege(college, nil))
+	c.refresh(ctx)
+}
+
+func (c *CollegeCommand) handleEdit(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, subcommand *discordgo.ApplicationCommandInteractionDataOption) {
+	options := subcommandOptions(subcommand)
+	data := c.Data.Load()
+	college, ok := data.College(options["college"])
+	if !ok {
+		responses.RespondWithEphemeralError(s, i, "No college found to edit, pick one from the list")
+		return
+	}
+	name, renamed := options["name"]
+	alias, realiased := options["alias"]
+	if !renamed && !realiased {
+		responses.RespondWithEphemeralError(s, i, "Give a new name or alias to edit the college")
+		return
+	}
+	if !renamed || name == "" {
+		name = college.Name
+	}
+	if !realiased {
+		alias = college.Alias
+	} else if alias == clearAlias {
+		alias = ""
+	}
+	if existing, ok := nameTaken(data, name, college.ID); ok {
+		responses.RespondWithEphemeralError(s, i, fmt.Sprintf("**%s** already finds the college **%s**", name, existing.Name))
+		return
+	}
+
+	updated, err := c.PbAdmin.UpdateCollege(ctx, college.ID, pb.CollegeCreateRequest{Name: name, Alias: alias})
+	if err != nil {
+		log.Printf("Error updating college %s: %v", college.ID, err)
+		responses.RespondWithEphemeralError(s, i, fmt.Sprintf("Could not edit the college: %v", err))
+		return
+	}
+
+	description := fmt.Sprintf("Edited **%s**", updated.Name)
+	// files still use the old name, keep it finding the college
+	if updated.Name != college.Name && !slices.ContainsFunc(data.CollegeAliasNames(college.ID), func(alias string) bool {
+		return strings.EqualFold(alias, college.Name)
+	}) {
+		_, err := c.PbAdmin.CreateCollegeAlias(ctx, pb.CollegeAliasCreateRequest{Name: college.Name, College: college.ID})
+		if err != nil {
+			log.Printf("Error recording alias %s: %v", college.Name, err)
+			description += fmt.Sprintf("\nCould not keep the old name **%s** as an alias: %v", college.Name, err)
+		} else {
+			description += fmt.Sprintf("\nThe old name **%s** is kept as an alias", college.Name)
+		}
+	}
+	responses.RespondWithEmbed(s, i, c.BotEnv, "College edited", description, describeCollege(updated, data))
+	c.refresh(ctx)
+}
+
+func (c *CollegeCommand) handleRemove(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, subcommand *discordgo.ApplicationCommandInteractionDataOption) {
+	options := subcommandOptions(subcommand)
+	college, ok := c.Data.Load().College(options["college"])
+	if !ok {
+		responses.RespondWithEphemeralError(s, i, "No college found to remove, pick one from the list")
+		return
+	}
+
+	err := c.PbAdmin.DeleteCollege(ctx, college.ID)
+	var inUse *pb.InUseError
+	if errors.As(err, &inUse) {
+		responses.RespondWithEphemeralError(s, i, fmt.Sprintf("**%s** still has %d ranks, move them to another college with `/college merge` first", college.Name, inUse.Ranks))
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting college %s: %v", college.ID, err)
+		responses.RespondWithEphemeralError(s, i, fmt.Sprintf("Could not remove the college: %v", err))
+		return
+	}
+	responses.RespondWithEmbed(s, i, c.BotEnv, "College removed", fmt.Sprintf("Removed **%s** and its aliases", college.Name), nil)
+	c.refresh(ctx)
+}
+
+func (c *CollegeCommand) handleShow(s *discordgo.Session, i *discordgo.InteractionCreate, subcommand *discordgo.ApplicationCommandInteractionDataOption) {
+	options := subcommandOptions(subcommand)
+	data := c.Data.Load()
+	college, ok := data.College(options["college"])
+	if !ok {
+		responses.RespondWithEphemeralError(s, i, "No college found, pick one from the list")
+		return
+	}
+	responses.RespondWithEmbed(s, i, c.BotEnv, college.Name, "", describeCollege(college, data))
+}
+
+// describeCollege lists the fields of a college, and what data knows about it when given
+func describeCollege(college pb.CollegeCollection, data *dataset.Snapshot) []*discordgo.MessageEmbedField {
+	fields := []*discordgo.MessageEmbedField{
+		{Name: "ID", Value: college.ID, Inline: true},
+		{Name: "Alias", Value: orNone(college.Alias), Inline: true},
+	}
+	if data == nil {
+		return fields
+	}
+
+	ranks := data.CollegeRanks(college.ID)
+	rounds := make(map[string]bool)
+	for _, rank := range ranks {
+		rounds[fmt.Sprintf("%d round %d", rank.Year, rank.Round)] = true
+	}
+	latest := "None"
+	if len(ranks) > 0 {
+		latest = fmt.Sprintf("%d round %d", ranks[0].Year, ranks[0].Round)
+	}
+	return append(fields,
+		&discordgo.MessageEmbedField{Name: "Ranks", Value: fmt.Sprintf("%d in %d rounds", len(ranks), len(rounds)), Inline: true},
+		&discordgo.MessageEmbedField{Name: "Latest round", Value: latest, Inline: true},
+		&discordgo.MessageEmbedField{Name: "Recorded aliases", Value: truncate(orNone(strings.Join(data.CollegeAliasNames(college.ID), "\n")), 1024)},
+	)
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "None"
+	}
+	return value
+}
+
+// truncate cuts text to at most limit characters
+func truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-1]) + "…"
+}
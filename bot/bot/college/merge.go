@@ -0,0 +1,120 @@
+package college
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/arinji2/dasa-bot/bot/dataset"
+	"github.com/arinji2/dasa-bot/pb"
+)
+
+// mergeResult is what merging two colleges did
+type mergeResult struct {
+	Moved int
+	// Dropped counts ranks the college merged into already had for the same branch and round
+	Dropped  int
+	Aliases  int
+	Warnings []string
+}
+
+// mergeColleges moves every rank of from to to and deletes from, keeping its
+// names as aliases of to. The ranks are moved completely or not at all. Ranks
+// stored since data was synced are not moved, so from is then kept instead of
+// deleting them along with it.
+func (c *CollegeCommand) mergeColleges(ctx context.Context, data *dataset.Snapshot, from, to pb.CollegeCollection) (mergeResult, error) {
+	var result mergeResult
+
+	existing := make(map[string]bool)
+	for _, rank := range data.Ranks {
+		if rank.College == to.ID {
+			existing[mergeKey(rank)] = true
+		}
+	}
+	var moves []pb.RankMoveRequest
+	var dropped []pb.RankCollection
+	for _, rank := range data.Ranks {
+		if rank.College != from.ID {
+			continue
+		}
+		if existing[mergeKey(rank)] {
+			dropped = append(dropped, rank)
+			continue
+		}
+		moves = append(moves, pb.RankMoveRequest{ID: rank.ID, College: to.ID})
+	}
+
+	m := &pb.RankMover{
+		Store:  c.PbAdmin,
+		Revert: func(id string) pb.RankMoveRequest { return pb.RankMoveRequest{ID: id, College: from.ID} },
+	}
+	if err := m.Move(ctx, moves); err != nil {
+		return result, err
+	}
+	if err := m.Delete(ctx, dropped); err != nil {
+		return result, err
+	}
+	result.Moved = len(moves)
+	result.Dropped = len(dropped)
+
+	// aliases cascade with their college, the ones of from have to move before it is deleted
+	for _, alias := range data.Aliases.Colleges {
+		if alias.College != from.ID {
+			continue
+		}
+		_, err := c.PbAdmin.UpdateCollegeAlias(ctx, alias.ID, pb.CollegeAliasCreateRequest{Name: alias.Name, College: to.ID})
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Could not keep alias **%s**: %v", alias.Name, err))
+			continue
+		}
+		result.Aliases++
+	}
+
+	for _, name := range mergedNames(from, to, data) {
+		_, err := c.PbAdmin.CreateCollegeAlias(ctx, pb.CollegeAliasCreateRequest{Name: name, College: to.ID})
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Could not record alias **%s**: %v", name, err))
+			continue
+		}
+		result.Aliases++
+	}
+
+	err := c.PbAdmin.DeleteCollege(ctx, from.ID)
+	var inUse *pb.InUseError
+	if errors.As(err, &inUse) {
+		return result, fmt.Errorf("moved %d ranks but kept %s, %d ranks were added to it meanwhile, run /college merge again to move them", result.Moved, from.Name, inUse.Ranks)
+	}
+	if err != nil {
+		return result, fmt.Errorf("moved %d ranks but failed to delete %s: %w", result.Moved, from.Name, err)
+	}
+	return result, nil
+}
+
+// mergedNames are the names of from that do not already lead to a college
+func mergedNames(from, to pb.CollegeCollection, data *dataset.Snapshot) []string {
+	candidates := []string{from.Name}
+	if from.Alias != "" {
+		candidates = append(candidates, strings.Split(from.Alias, ",")...)
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range candidates {
+		name = strings.TrimSpace(name)
+		key := strings.ToLower(name)
+		if name == "" || seen[key] || strings.EqualFold(name, to.Name) {
+			continue
+		}
+		seen[key] = true
+		if _, ok := data.CollegeByAlias(name); ok {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func mergeKey(rank pb.RankCollection) string {
+	return fmt.Sprintf("%d|%d|%s", rank.Year, rank.Round, rank.Branch)
+}
@@ -0,0 +1,47 @@
+package college
+
+import (
+	"context"
+	"testing"
+
+	"github.com/arinji2/dasa-bot/bot/dataset"
+	"github.com/arinji2/dasa-bot/pb"
+)
+
+func TestMergeKeepsRanksMissingFromSnapshot(t *testing.T) {
+	ctx := context.Background()
+	colleges := []pb.CollegeCollection{
+		{ID: "rec", Name: "REC Calicut"},
+		{ID: "nitc", Name: "National Institute of Technology Calicut"},
+	}
+	branches := []pb.BranchCollection{{ID: "cse", Name: "Computer Science and Engineering", Code: "CS"}}
+	ranks := []pb.RankCollection{{ID: "rank1", Year: 2024, Round: 1, College: "rec", Branch: "cse", JeeOpen: 100, JeeClose: 200}}
+	store := pb.NewMemoryStore(colleges, branches, ranks)
+	data := dataset.NewSnapshot(colleges, branches, ranks, dataset.Aliases{})
+
+	// stored after the snapshot was synced
+	if _, err := store.CreateRanks(ctx, []pb.RankCreateRequest{{ID: "rank2", Year: 2024, Round: 2, College: "rec", Branch: "cse", JeeOpen: 110, JeeClose: 210}}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &CollegeCommand{PbAdmin: store}
+	result, err := c.mergeColleges(ctx, data, colleges[0], colleges[1])
+	if err == nil {
+		t.Fatal("merge deleted a college that still has ranks")
+	}
+	if result.Moved != 1 {
+		t.Errorf("moved %d ranks, want 1", result.Moved)
+	}
+
+	stored, err := store.GetAllRanks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	colleges, err = store.GetAllColleges(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 2 || len(colleges) != 2 {
+		t.Fatalf("got %d ranks and %d colleges, want the new rank and its college kept", len(stored), len(colleges))
+	}
+}
@@ -0,0 +1,256 @@
+// Package dataset holds the colleges, branches and ranks shared by every command.
+// Data is published as immutable snapshots that are swapped atomically, so
+// handlers can read without locking while a refresh builds the next one.
+package dataset
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/arinji2/dasa-bot/pb"
+)
+
+// Dataset always holds the latest published snapshot
+type Dataset struct {
+	current atomic.Pointer[Snapshot]
+}
+
+func New() *Dataset {
+	d := &Dataset{}
+	d.current.Store(NewSnapshot(nil, nil, nil, Aliases{}))
+	return d
+}
+
+// Load returns the current snapshot, which stays valid and unchanged for as long as it is held
+func (d *Dataset) Load() *Snapshot {
+	return d.current.Load()
+}
+
+// Publish replaces the current snapshot
+func (d *Dataset) Publish(s *Snapshot) {
+	d.current.Store(s)
+}
+
+// Aliases are the other names colleges and branches are known by
+type Aliases struct {
+	Colleges []pb.CollegeAliasCollection
+	Branches []pb.BranchAliasCollection
+}
+
+type roundKey struct {
+	year  int
+	round int
+	ciwg  bool
+}
+
+// Snapshot is an indexed, read-only view of the data. Nothing reachable from
+// it may be modified after NewSnapshot returns.
+type Snapshot struct {
+	Colleges []pb.CollegeCollection
+	Branches []pb.BranchCollection
+	// Ranks are ordered newest year and round first
+	Ranks   []pb.RankCollection
+	Aliases Aliases
+
+	collegesByID   map[string]pb.CollegeCollection
+	collegesByName map[string]pb.CollegeCollection
+	branchesByID   map[string]pb.BranchCollection
+	branchesByCode map[string][]pb.BranchCollection
+	// collegesByAlias and branchesByAlias only hold aliases of records that exist
+	collegesByAlias   map[string]pb.CollegeCollection
+	collegeAliasNames map[string][]string
+	branchesByAlias   map[string][]pb.BranchCollection
+	branchAliases     map[string][]pb.BranchAliasCollection
+	ranksByCollege    map[string][]pb.RankCollection
+	ranksByBranch     map[string][]pb.RankCollection
+	// ranksByRound is sorted by closing rank within each round
+	ranksByRound map[roundKey][]pb.RankCollection
+	years        []int
+}
+
+// NewSnapshot indexes the given records. Ranks are expected to have their
+// college and branch expanded, the slices are owned by the snapshot afterwards.
+func NewSnapshot(colleges []pb.CollegeCollection, branches []pb.BranchCollection, ranks []pb.RankCollection, aliases Aliases) *Snapshot {
+	s := &Snapshot{
+		Colleges:          colleges,
+		Branches:          branches,
+		Ranks:             ranks,
+		Aliases:           aliases,
+		collegesByID:      make(map[string]pb.CollegeCollection, len(colleges)),
+		collegesByName:    make(map[string]pb.CollegeCollection, len(colleges)),
+		branchesByID:      make(map[string]pb.BranchCollection, len(branches)),
+		branchesByCode:    make(map[string][]pb.BranchCollection),
+		collegesByAlias:   make(map[string]pb.CollegeCollection, len(aliases.Colleges)),
+		collegeAliasNames: make(map[string][]string),
+		branchesByAlias:   make(map[string][]pb.BranchCollection),
+		branchAliases:     make(map[string][]pb.BranchAliasCollection),
+		ranksByCollege:    make(map[string][]pb.RankCollection),
+		ranksByBranch:     make(map[string][]pb.RankCollection),
+		ranksByRound:      make(map[roundKey][]pb.RankCollection),
+	}
+
+	slices.SortStableFunc(s.Ranks, func(a, b pb.RankCollection) int {
+		if a.Year != b.Year {
+			return b.Year - a.Year
+		}
+		return b.Round - a.Round
+	})
+
+	for _, college := range colleges {
+		s.collegesByID[college.ID] = college
+		s.collegesByName[strings.ToLower(college.Name)] = college
+	}
+	for _, branch := range branches {
+		code := strings.ToLower(branch.Code)
+		s.branchesByCode[code] = append(s.branchesByCode[code], branch)
+		s.branchesByID[branch.ID] = branch
+	}
+	for _, alias := range aliases.Colleges {
+		if college, ok := s.collegesByID[alias.College]; ok {
+			s.collegesByAlias[strings.ToLower(alias.Name)] = college
+			s.collegeAliasNames[college.ID] = append(s.collegeAliasNames[college.ID], alias.Name)
+		}
+	}
+	for _, alias := range aliases.Branches {
+		if branch, ok := s.branchesByID[alias.Branch]; ok {
+			key := branchAliasKey(alias.Name, alias.Code)
+			s.branchesByAlias[key] = append(s.branchesByAlias[key], branch)
+			s.branchAliases[branch.ID] = append(s.branchAliases[branch.ID], alias)
+		}
+	}
+
+	yearSet := make(map[int]struct{})
+	for _, rank := range s.Ranks {
+		s.ranksByCollege[rank.College] = append(s.ranksByCollege[rank.College], rank)
+		s.ranksByBranch[rank.Branch] = append(s.ranksByBranch[rank.Branch], rank)
+		key := roundKey{year: rank.Year, round: rank.Round, ciwg: rank.Expand.Branch.Ciwg}
+		s.ranksByRound[key] = append(s.ranksByRound[key], rank)
+		yearSet[rank.Year] = struct{}{}
+	}
+	for _, roundRanks := range s.ranksByRound {
+		slices.SortStableFunc(roundRanks, func(a, b pb.RankCollection) int {
+			return a.JeeClose - b.JeeClose
+		})
+	}
+	for year := range yearSet {
+		s.years = append(s.years, year)
+	}
+	sort.Ints(s.years)
+
+	return s
+}
+
+func (s *Snapshot) College(id string) (pb.CollegeCollection, bool) {
+	college, ok := s.collegesByID[id]
+	return college, ok
+}
+
+// CollegeByName matches the full college name, ignoring case
+func (s *Snapshot) CollegeByName(name string) (pb.CollegeCollection, bool) {
+	college, ok := s.collegesByName[strings.ToLower(name)]
+	return college, ok
+}
+
+// CollegeByAlias matches a recorded alias of a college, ignoring case
+func (s *Snapshot) CollegeByAlias(name string) (pb.CollegeCollection, bool) {
+	college, ok := s.collegesByAlias[strings.ToLower(name)]
+	return college, ok
+}
+
+// CollegeAliasNames are the recorded aliases of a college
+func (s *Snapshot) CollegeAliasNames(collegeID string) []string {
+	return s.collegeAliasNames[collegeID]
+}
+
+// CollegeRanks are every rank of a college, newest year and round first
+func (s *Snapshot) CollegeRanks(collegeID string) []pb.RankCollection {
+	return s.ranksByCollege[collegeID]
+}
+
+func (s *Snapshot) Branch(id string) (pb.BranchCollection, bool) {
+	branch, ok := s.branchesByID[id]
+	return branch, ok
+}
+
+// BranchRanks are every rank of a branch, newest year and round first
+func (s *Snapshot) BranchRanks(branchID string) []pb.RankCollection {
+	return s.ranksByBranch[branchID]
+}
+
+// BranchAliases are the recorded aliases of a branch
+func (s *Snapshot) BranchAliases(branchID string) []pb.BranchAliasCollection {
+	return s.branchAliases[branchID]
+}
+
+// BranchByAlias matches a recorded alias of a branch in the ciwg category, ignoring case
+func (s *Snapshot) BranchByAlias(name, code string, ciwg bool) (pb.BranchCollection, bool) {
+	for _, branch := range s.branchesByAlias[branchAliasKey(name, code)] {
+		if branch.Ciwg == ciwg {
+			return branch, true
+		}
+	}
+	return pb.BranchCollection{}, false
+}
+
+func branchAliasKey(name, code string) string {
+	return strings.ToLower(name) + "|" + strings.ToLower(code)
+}
+
+// BranchesByCode returns every branch with code, ignoring case
+func (s *Snapshot) BranchesByCode(code string) []pb.BranchCollection {
+	return s.branchesByCode[strings.ToLower(code)]
+}
+
+// RanksForCollege returns the ranks of a college for a single year and round
+func (s *Snapshot) RanksForCollege(collegeID string, ciwg bool, year, round int) []pb.RankCollection {
+	var ranks []pb.RankCollection
+	for _, rank := range s.ranksByCollege[collegeID] {
+		if rank.Expand.Branch.Ciwg == ciwg && rank.Year == year && rank.Round == round {
+			ranks = append(ranks, rank)
+		}
+	}
+	return ranks
+}
+
+// BranchesForCollege returns the branches a college had ranks for in a year and round
+func (s *Snapshot) BranchesForCollege(collegeID string, ciwg bool, year, round int) []pb.BranchCollection {
+	var branches []pb.BranchCollection
+	for _, rank := range s.RanksForCollege(collegeID, ciwg, year, round) {
+		branches = append(branches, rank.Expand.Branch)
+	}
+	return branches
+}
+
+func (s *Snapshot) SpecificRank(collegeID, branchCode string, ciwg bool, year, round int) (pb.RankCollection, bool) {
+	for _, rank := range s.RanksForCollege(collegeID, ciwg, year, round) {
+		if rank.Expand.Branch.Code == branchCode {
+			return rank, true
+		}
+	}
+	return pb.RankCollection{}, false
+}
+
+// RanksClosingFrom returns the ranks of a round whose closing rank is at least
+// lowerBound, ordered by closing rank
+func (s *Snapshot) RanksClosingFrom(year, round int, ciwg bool, lowerBound int) []pb.RankCollection {
+	roundRanks := s.ranksByRound[roundKey{year: year, round: round, ciwg: ciwg}]
+	start := sort.Search(len(roundRanks), func(idx int) bool {
+		return roundRanks[idx].JeeClose >= lowerBound
+	})
+	return roundRanks[start:]
+}
+
+// Latest returns the newest year and round there are ranks for
+func (s *Snapshot) Latest() (year int, round int, ok bool) {
+	if len(s.Ranks) == 0 {
+		return 0, 0, false
+	}
+	return s.Ranks[0].Year, s.Ranks[0].Round, true
+}
+
+// Years returns every year there are ranks for, in ascending order
+func (s *Snapshot) Years() []int {
+	return s.years
+}
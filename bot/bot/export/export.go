@@ -0,0 +1,78 @@
+// Package export contains the logic for the Export command
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/arinji2/dasa-bot/archive"
+	"github.com/arinji2/dasa-bot/env"
+	"github.com/arinji2/dasa-bot/pb"
+	responses "github.com/arinji2/dasa-bot/responses"
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxAttachmentSize is the largest file Discord accepts from a bot in a server without boosts
+const maxAttachmentSize = 10 << 20
+
+type ExportCommand struct {
+	PbAdmin pb.Store
+	BotEnv  env.Bot
+}
+
+// HandleExportResponse uploads every college, branch, rank and alias as an archive
+func (c *ExportCommand) HandleExportResponse(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	format := archive.FormatJSON
+	for _, option := range i.ApplicationCommandData().Options {
+		if option.Name == "format" {
+			format = archive.Format(option.StringValue())
+		}
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+	if err != nil {
+		log.Printf("Error deferring export: %v", err)
+		return
+	}
+
+	d, err := archive.Collect(ctx, c.PbAdmin)
+	if err != nil {
+		log.Printf("Error collecting export: %v", err)
+		c.edit(s, i, responses.CreateBaseEmbed("Error exporting data", err.Error(), c.BotEnv, nil), nil)
+		return
+	}
+	now := time.Now()
+	var buf bytes.Buffer
+	if err := archive.Write(&buf, d, format, now); err != nil {
+		log.Printf("Error writing export: %v", err)
+		c.edit(s, i, responses.CreateBaseEmbed("Error exporting data", err.Error(), c.BotEnv, nil), nil)
+		return
+	}
+	if buf.Len() > maxAttachmentSize {
+		description := fmt.Sprintf("The archive is %.1f MB, more than Discord allows. Run `go run ./cmd/dasa-export export -format %s` instead.",
+			float64(buf.Len())/(1<<20), format)
+		c.edit(s, i, responses.CreateBaseEmbed("Export too large", description, c.BotEnv, nil), nil)
+		return
+	}
+
+	description := fmt.Sprintf("Exported %s as %s, archive version %d.\nRestore it into an empty Pocketbase with `go run ./cmd/dasa-export import -i %s`.",
+		d.Counts(), format, archive.Version, archive.FileName(now))
+	c.edit(s, i, responses.CreateBaseEmbed("Data exported", description, c.BotEnv, nil), []*discordgo.File{
+		{Name: archive.FileName(now), ContentType: "application/zip", Reader: &buf},
+	})
+}
+
+func (c *ExportCommand) edit(s *discordgo.Session, i *discordgo.InteractionCreate, embed *discordgo.MessageEmbed, files []*discordgo.File) {
+	_, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+		Files:  files,
+	})
+	if err != nil {
+		log.Printf("Error editing export response: %v", err)
+	}
+}
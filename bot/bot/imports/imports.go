@@ -0,0 +1,171 @@
+// Package imports contains the logic for the Imports command
+package imports
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/arinji2/dasa-bot/env"
+	"github.com/arinji2/dasa-bot/pb"
+	responses "github.com/arinji2/dasa-bot/responses"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	defaultListCount = 10
+	// maxListCount keeps the list within one embed, and is Discord's limit for autocomplete choices
+	maxListCount = 25
+)
+
+type ImportsCommand struct {
+	PbAdmin pb.Store
+	BotEnv  env.Bot
+}
+
+func (c *ImportsCommand) HandleImportsResponse(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	subcommand := i.ApplicationCommandData().Options[0]
+	options := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(subcommand.Options))
+	for _, option := range subcommand.Options {
+		options[option.Name] = option
+	}
+
+	switch subcommand.Name {
+	case "list":
+		count := defaultListCount
+		if option, ok := options["count"]; ok {
+			count = min(max(int(option.IntValue()), 1), maxListCount)
+		}
+		c.handleList(ctx, s, i, count)
+	case "show":
+		c.handleShow(ctx, s, i, options["id"].StringValue())
+	}
+}
+
+// HandleImportsAutocomplete suggests the latest imports for the id option
+func (c *ImportsCommand) HandleImportsAutocomplete(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var searchTerm string
+	for _, option := range i.ApplicationCommandData().Options[0].Options {
+		if option.Focused {
+			searchTerm = strings.ToLower(option.StringValue())
+		}
+	}
+
+	records, err := c.PbAdmin.ListImports(ctx, maxListCount)
+	if err != nil {
+		log.Printf("Error listing imports: %v", err)
+	}
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, record := range records {
+		name := fmt.Sprintf("%s · %s · %s · %s", record.ID, record.FileName, record.User, record.Status)
+		if searchTerm != "" && !strings.Contains(strings.ToLower(name), searchTerm) {
+			continue
+		}
+		if len(name) > 100 {
+			name = name[:97] + "..."
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  name,
+			Value: record.ID,
+		})
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to imports autocomplete: %v", err)
+	}
+}
+
+func (c *ImportsCommand) handleList(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, count int) {
+	records, err := c.PbAdmin.ListImports(ctx, count)
+	if err != nil {
+		log.Printf("Error listing imports: %v", err)
+		responses.RespondWithEphemeralError(s, i, fmt.Sprintf("Could not list imports: %v", err))
+		return
+	}
+	if len(records) == 0 {
+		responses.RespondWithEmbed(s, i, c.BotEnv, "Imports", "Nothing has been imported yet", nil)
+		return
+	}
+
+	lines := make([]string, len(records))
+	for idx, record := range records {
+		lines[idx] = fmt.Sprintf("`%s` %s **%s** by %s, %s: %s",
+			record.ID, describeTime(record.Created), record.FileName, record.User, record.Status, describeCounts(record))
+	}
+	description := fmt.Sprintf("The latest %d imports, newest first. Use `/imports show` for the details of one.\n\n", len(records)) +
+		strings.Join(lines, "\n")
+	responses.RespondWithEmbed(s, i, c.BotEnv, "Imports", truncate(description, 4096), nil)
+}
+
+func (c *ImportsCommand) handleShow(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, id string) {
+	record, err := c.PbAdmin.GetImport(ctx, id)
+	if err != nil {
+		log.Printf("Error getting import %s: %v", id, err)
+		responses.RespondWithEphemeralError(s, i, fmt.Sprintf("No import found with id %s", id))
+		return
+	}
+
+	user := record.User
+	if record.UserID != "" {
+		user = fmt.Sprintf("<@%s>", record.UserID)
+	}
+	fields := []*discordgo.MessageEmbedField{
+		{Name: "User", Value: user, Inline: true},
+		{Name: "When", Value: describeTime(record.Created), Inline: true},
+		{Name: "Status", Value: record.Status, Inline: true},
+		{Name: "File", Value: orNone(record.FileName), Inline: true},
+		{Name: "Rounds", Value: orNone(record.Rounds), Inline: true},
+		{Name: "Mode", Value: orNone(record.Mode), Inline: true},
+		{Name: "Created", Value: fmt.Sprintf("%d", record.RanksCreated), Inline: true},
+		{Name: "Updated", Value: fmt.Sprintf("%d", record.RanksUpdated), Inline: true},
+		{Name: "Deleted", Value: fmt.Sprintf("%d", record.RanksDeleted), Inline: true},
+		{Name: "Skipped", Value: fmt.Sprintf("%d", record.RanksSkipped), Inline: true},
+		{Name: "Failed", Value: fmt.Sprintf("%d", record.RanksFailed), Inline: true},
+		{Name: "Duration", Value: fmt.Sprintf("%.1fs", float64(record.DurationMs)/1000), Inline: true},
+		{Name: "Backup", Value: orNone(record.BackupKey), Inline: true},
+		{Name: "File hash", Value: orNone(record.FileHash)},
+		{Name: fmt.Sprintf("New colleges (%d)", len(record.NewColleges)), Value: truncate(orNone(strings.Join(record.NewColleges, "\n")), 1024)},
+		{Name: fmt.Sprintf("New branches (%d)", len(record.NewBranches)), Value: truncate(orNone(strings.Join(record.NewBranches, "\n")), 1024)},
+	}
+	if record.Error != "" {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Error", Value: truncate(record.Error, 1024)})
+	}
+	responses.RespondWithEmbed(s, i, c.BotEnv, "Import "+record.ID, "", fields)
+}
+
+func describeCounts(record pb.ImportCollection) string {
+	return fmt.Sprintf("%d created, %d updated, %d deleted, %d skipped, %d failed",
+		record.RanksCreated, record.RanksUpdated, record.RanksDeleted, record.RanksSkipped, record.RanksFailed)
+}
+
+// describeTime shows a Pocketbase datetime in the reader's own time zone
+func describeTime(value string) string {
+	created, err := pb.ParseTime(value)
+	if err != nil {
+		return value
+	}
+	return fmt.Sprintf("<t:%d:f>", created.Unix())
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "None"
+	}
+	return value
+}
+
+// truncate cuts text to at most limit characters
+func truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-1]) + "…"
+}
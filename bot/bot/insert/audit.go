@@ -0,0 +1,78 @@
+package insert
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/arinji2/dasa-bot/pb"
+)
+
+const (
+	importSucceeded = "success"
+	importFailed    = "failed"
+	importCancelled = "cancelled"
+	// importRejected means the file had errors, so nothing was attempted
+	importRejected = "rejected"
+)
+
+// errRejected is recorded for files that were not inserted because of parse errors
+var errRejected = errors.New("file has rows that could not be parsed")
+
+// recordImport adds the outcome of an insert to the imports collection and
+// returns the id of the record. The audit log is not worth failing an insert
+// over, so errors are only logged.
+func (c *InsertCommand) recordImport(ctx context.Context, plan *importPlan, result insertResult, err error, p *progress) string {
+	record := pb.ImportCreateRequest{
+		User:         plan.UserName,
+		UserID:       plan.UserID,
+		FileName:     plan.FileName,
+		FileHash:     plan.FileHash,
+		Rounds:       plan.describeRounds(),
+		Mode:         plan.Mode,
+		Status:       importSucceeded,
+		RanksCreated: result.Created,
+		RanksUpdated: result.Updated,
+		RanksDeleted: result.Deleted,
+		RanksSkipped: result.Skipped,
+		NewBranches:  result.NewBranches,
+		NewColleges:  result.NewColleges,
+		BackupKey:    result.BackupKey,
+		DurationMs:   p.elapsed().Milliseconds(),
+	}
+	if len(plan.Rounds) == 1 {
+		record.Year, record.Round = plan.Rounds[0].Year, plan.Rounds[0].Round
+	}
+
+	var failure *insertFailure
+	switch {
+	case err == nil:
+	case errors.Is(err, errRejected):
+		record.Status = importRejected
+		record.RanksFailed = len(plan.Errors)
+		record.Error = err.Error()
+	default:
+		record.Status = importFailed
+		if errors.Is(err, errCancelled) {
+			record.Status = importCancelled
+		}
+		record.Error = describeError(err)
+		// a failed insert is undone, nothing it planned to write is left
+		record.RanksFailed = len(plan.Ranks) + len(plan.Updates)
+		record.RanksSkipped = len(plan.Duplicates)
+		if errors.As(err, &failure) {
+			record.BackupKey = failure.BackupKey
+			if failure.RollbackErr != nil {
+				record.Error += "\nundoing the insert failed: " + describeError(failure.RollbackErr)
+			}
+		}
+	}
+
+	// record cancelled inserts as well
+	created, err := c.PbAdmin.CreateImport(context.WithoutCancel(ctx), record)
+	if err != nil {
+		log.Printf("Error recording import of %s: %v", plan.FileName, err)
+		return ""
+	}
+	return created.ID
+}
@@ -0,0 +1,484 @@
+package insert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/arinji2/dasa-bot/pb"
+)
+
+// insertResult is what executing a plan actually did
+type insertResult struct {
+	Logs      []string
+	Created   int
+	Updated   int
+	Deleted   int
+	Skipped   int
+	BackupKey string
+	// Changes describes every updated rank as old → new
+	Changes []string
+	// NewColleges and NewBranches name the records that were created
+	NewColleges []string
+	NewBranches []string
+}
+
+// stepError stops an insert before any rank is written
+type stepError struct {
+	Title string
+	Err   error
+}
+
+func (e *stepError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *stepError) Unwrap() error {
+	return e.Err
+}
+
+// insertFailure is returned when a batch failed part way through an insert.
+// Everything written before it is deleted again, if that fails too the backup
+// taken before the insert is the way back.
+type insertFailure struct {
+	// Action is what was being done to Rank or Branch, e.g. "create"
+	Action string
+	// Line is the row that was rejected, zero when it was not a row of the file
+	Line int
+	// Record is the rejected row as it was read from the file
+	Record      []string
+	Rank        *pb.RankCollection
+	College     *pb.CollegeCreateRequest
+	Branch      *pb.BranchCreateRequest
+	Err         error
+	RollbackErr error
+	BackupKey   string
+}
+
+func (e *insertFailure) Error() string {
+	return e.Err.Error()
+}
+
+func (e *insertFailure) Unwrap() error {
+	return e.Err
+}
+
+// executePlan backs up Pocketbase, then deletes replaced ranks and writes the
+// planned colleges, branches and ranks in batches. A round is written completely
+// or not at all, cancelling ctx between batches undoes what was written so far.
+func (c *InsertCommand) executePlan(ctx context.Context, plan *importPlan, p *progress) (insertResult, error) {
+	var result insertResult
+	p.update(func(counts *progressCounts) {
+		counts.Stage = "Backing up"
+		counts.Total = len(plan.Ranks) + len(plan.Duplicates) + len(plan.Updates)
+		if counts.Parsed == 0 {
+			counts.Parsed = counts.Total
+		}
+	})
+	if err := cancelled(ctx); err != nil {
+		return result, &stepError{Title: "Insert cancelled", Err: err}
+	}
+
+	backupName, err := c.PbAdmin.CreateBackup(ctx, plan.UserName)
+	if err != nil {
+		return result, &stepError{Title: "Error creating backup", Err: err}
+	}
+	result.BackupKey = backupName
+	if err := cancelled(ctx); err != nil {
+		return result, &stepError{Title: "Insert cancelled", Err: err}
+	}
+	result.Logs = append(result.Logs, fmt.Sprintf("Created backup with name **%s**", backupName))
+
+	// the backup just taken is the newest, so pruning never deletes it
+	pruned, err := pb.PruneBackups(ctx, c.PbAdmin, c.Retention)
+	for _, backup := range pruned {
+		result.Logs = append(result.Logs, fmt.Sprintf("Deleted backup **%s** past the retention policy", backup.Key))
+	}
+	if err != nil {
+		log.Printf("Error pruning backups: %v", err)
+		result.Logs = append(result.Logs, fmt.Sprintf("Could not delete old backups: %v", err))
+	}
+	result.Logs = append(result.Logs, fmt.Sprintf("Parsed **%d** ranks", len(plan.Ranks)+len(plan.Duplicates)+len(plan.Updates)))
+
+	w := &batchWriter{store: c.PbAdmin, backupKey: backupName, progress: p}
+	p.stage("Deleting replaced ranks")
+
+	err = w.deleteRanks(ctx, plan.Replaced)
+	if err != nil {
+		return result, err
+	}
+	if plan.Mode == modeReplace {
+		result.Deleted = len(plan.Replaced)
+		result.Logs = append(result.Logs, fmt.Sprintf("Deleted **%d** existing ranks", result.Deleted))
+	}
+
+	p.stage("Creating colleges and branches")
+	data := c.Data.Load()
+	colleges := make(map[string]pb.CollegeCollection, len(plan.NewColleges))
+	var missingColleges []plannedCollege
+	for _, college := range plan.NewColleges {
+		// another insert may have created it since the plan was made
+		if existing, ok := data.CollegeByName(college.Request.Name); ok {
+			colleges[college.Key] = existing
+			continue
+		}
+		missingColleges = append(missingColleges, college)
+	}
+	createdColleges, err := w.createColleges(ctx, missingColleges)
+	if err != nil {
+		return result, err
+	}
+	for idx, college := range createdColleges {
+		colleges[missingColleges[idx].Key] = college
+		result.NewColleges = append(result.NewColleges, college.Name)
+	}
+	if len(plan.NewColleges) > 0 {
+		result.Logs = append(result.Logs, fmt.Sprintf("Created **%d** new colleges", len(createdColleges)))
+	}
+
+	branches := make(map[string]pb.BranchCollection, len(plan.NewBranches))
+	var missing []plannedBranch
+	for _, branch := range plan.NewBranches {
+		// another insert may have created it since the plan was made
+		if existing, ok := findBranch(data.BranchesByCode(branch.Request.Code), branch.Request); ok {
+			branches[branch.Key] = existing
+			continue
+		}
+		missing = append(missing, branch)
+	}
+	created, err := w.createBranches(ctx, missing)
+	if err != nil {
+		return result, err
+	}
+	for idx, branch := range created {
+		branches[missing[idx].Key] = branch
+		result.NewBranches = append(result.NewBranches, fmt.Sprintf("%s %s", branch.Name, describeBranch(branch)))
+	}
+	if len(plan.NewBranches) > 0 {
+		result.Logs = append(result.Logs, fmt.Sprintf("Created **%d** new branches", len(created)))
+	}
+
+	// ranks stored since the plan was made are skipped, the same as at planning
+	replaced := make(map[string]bool, len(plan.Replaced))
+	for _, rank := range plan.Replaced {
+		replaced[rank.ID] = true
+	}
+	existingRanks := make(map[string]bool)
+	for _, rank := range data.Ranks {
+		if plan.hasRound(rank.Year, rank.Round) && !replaced[rank.ID] {
+			existingRanks[rankKey(rank.Year, rank.Round, rank.College, rank.Branch)] = true
+		}
+	}
+
+	var ranks []plannedRank
+	skipped := 0
+	for _, planned := range plan.Ranks {
+		if planned.NewCollege != "" {
+			college := colleges[planned.NewCollege]
+			planned.Rank.College = college.ID
+			planned.Rank.Expand.College = college
+		}
+		if planned.NewBranch != "" {
+			branch := branches[planned.NewBranch]
+			planned.Rank.Branch = branch.ID
+			planned.Rank.Expand.Branch = branch
+		}
+		if existingRanks[rankKey(planned.Rank.Year, planned.Rank.Round, planned.Rank.College, planned.Rank.Branch)] {
+			skipped++
+			continue
+		}
+		ranks = append(ranks, planned)
+	}
+
+	p.update(func(counts *progressCounts) {
+		counts.Stage = "Creating ranks"
+		counts.Skipped = len(plan.Duplicates) + skipped
+	})
+	err = w.createRanks(ctx, ranks)
+	if err != nil {
+		return result, err
+	}
+
+	p.stage("Updating ranks")
+	err = w.updateRanks(ctx, plan.Updates)
+	if err != nil {
+		return result, err
+	}
+	for _, planned := range plan.Updates {
+		result.Changes = append(result.Changes, fmt.Sprintf("%s %s: %s", planned.Rank.Expand.College.Name, describeBranch(planned.Rank.Expand.Branch), describeChange(*planned.Existing, planned.Rank)))
+	}
+
+	result.Skipped = len(plan.Duplicates) + skipped
+	result.Created = len(ranks)
+	result.Updated = len(plan.Updates)
+	result.Logs = append(result.Logs, fmt.Sprintf("Skipped **%d** ranks", result.Skipped))
+	if plan.Mode == modeUpsert {
+		result.Logs = append(result.Logs, fmt.Sprintf("Updated **%d** ranks", result.Updated))
+	}
+	result.Logs = append(result.Logs, fmt.Sprintf("Successfully created **%d** ranks", result.Created))
+	return result, nil
+}
+
+// batchWriter writes in chunks of pb.MaxBatchSize and remembers what it
+// created so a failed chunk can undo the ones before it
+type batchWriter struct {
+	store     pb.Store
+	backupKey string
+	progress  *progress
+
+	collegeIDs []string
+	branchIDs  []string
+	rankIDs    []string
+	// previous holds the values updated ranks had before
+	previous []pb.RankUpdateRequest
+	// deleted holds the ranks deleted so they can be recreated under the same ids
+	deleted []pb.RankCreateRequest
+}
+
+func (w *batchWriter) createColleges(ctx context.Context, colleges []plannedCollege) ([]pb.CollegeCollection, error) {
+	var created []pb.CollegeCollection
+	for start := 0; start < len(colleges); start += pb.MaxBatchSize {
+		if err := cancelled(ctx); err != nil {
+			return nil, w.rollback(ctx, &insertFailure{Action: "create", Err: err})
+		}
+		chunk := colleges[start:min(start+pb.MaxBatchSize, len(colleges))]
+		requests := make([]pb.CollegeCreateRequest, len(chunk))
+		for idx, college := range chunk {
+			requests[idx] = college.Request
+		}
+
+		// a batch is never cut off half way, cancelling takes effect before the next one
+		records, err := w.store.CreateColleges(context.WithoutCancel(ctx), requests)
+		if err != nil {
+			failure := &insertFailure{Action: "create", Err: err}
+			var batchErr *pb.BatchError
+			if errors.As(err, &batchErr) && batchErr.Index < len(chunk) {
+				failure.College = &chunk[batchErr.Index].Request
+			}
+			return nil, w.rollback(ctx, failure)
+		}
+		for _, record := range records {
+			w.collegeIDs = append(w.collegeIDs, record.ID)
+		}
+		created = append(created, records...)
+	}
+	return created, nil
+}
+
+func (w *batchWriter) createBranches(ctx context.Context, branches []plannedBranch) ([]pb.BranchCollection, error) {
+	var created []pb.BranchCollection
+	for start := 0; start < len(branches); start += pb.MaxBatchSize {
+		if err := cancelled(ctx); err != nil {
+			return nil, w.rollback(ctx, &insertFailure{Action: "create", Err: err})
+		}
+		chunk := branches[start:min(start+pb.MaxBatchSize, len(branches))]
+		requests := make([]pb.BranchCreateRequest, len(chunk))
+		for idx, branch := range chunk {
+			requests[idx] = branch.Request
+		}
+
+		records, err := w.store.CreateBranches(context.WithoutCancel(ctx), requests)
+		if err != nil {
+			failure := &insertFailure{Action: "create", Err: err}
+			var batchErr *pb.BatchError
+			if errors.As(err, &batchErr) && batchErr.Index < len(chunk) {
+				failure.Branch = &chunk[batchErr.Index].Request
+			}
+			return nil, w.rollback(ctx, failure)
+		}
+		for _, record := range records {
+			w.branchIDs = append(w.branchIDs, record.ID)
+		}
+		created = append(created, records...)
+	}
+	return created, nil
+}
+
+func (w *batchWriter) createRanks(ctx context.Context, ranks []plannedRank) error {
+	for start := 0; start < len(ranks); start += pb.MaxBatchSize {
+		if err := cancelled(ctx); err != nil {
+			return w.rollback(ctx, &insertFailure{Action: "create", Err: err})
+		}
+		chunk := ranks[start:min(start+pb.MaxBatchSize, len(ranks))]
+		requests := make([]pb.RankCreateRequest, len(chunk))
+		for idx, planned := range chunk {
+			requests[idx] = pb.RankCreateRequest{
+				Year:     planned.Rank.Year,
+				Round:    planned.Rank.Round,
+				JeeOpen:  planned.Rank.JeeOpen,
+				JeeClose: planned.Rank.JeeClose,
+				College:  planned.Rank.College,
+				Branch:   planned.Rank.Branch,
+			}
+		}
+
+		records, err := w.store.CreateRanks(context.WithoutCancel(ctx), requests)
+		if err != nil {
+			failure := &insertFailure{Action: "create", Err: err}
+			var batchErr *pb.BatchError
+			if errors.As(err, &batchErr) && batchErr.Index < len(chunk) {
+				failure.Line = chunk[batchErr.Index].Line
+				failure.Record = chunk[batchErr.Index].Record
+				failure.Rank = &chunk[batchErr.Index].Rank
+			}
+			return w.rollback(ctx, failure)
+		}
+		for _, record := range records {
+			w.rankIDs = append(w.rankIDs, record.ID)
+		}
+		w.progress.update(func(counts *progressCounts) {
+			counts.Created += len(records)
+		})
+	}
+	return nil
+}
+
+func (w *batchWriter) updateRanks(ctx context.Context, ranks []plannedRank) error {
+	for start := 0; start < len(ranks); start += pb.MaxBatchSize {
+		if err := cancelled(ctx); err != nil {
+			return w.rollback(ctx, &insertFailure{Action: "update", Err: err})
+		}
+		chunk := ranks[start:min(start+pb.MaxBatchSize, len(ranks))]
+		requests := make([]pb.RankUpdateRequest, len(chunk))
+		for idx, planned := range chunk {
+			requests[idx] = pb.RankUpdateRequest{
+				ID:       planned.Existing.ID,
+				JeeOpen:  planned.Rank.JeeOpen,
+				JeeClose: planned.Rank.JeeClose,
+			}
+		}
+
+		_, err := w.store.UpdateRanks(context.WithoutCancel(ctx), requests)
+		if err != nil {
+			failure := &insertFailure{Action: "update", Err: err}
+			var batchErr *pb.BatchError
+			if errors.As(err, &batchErr) && batchErr.Index < len(chunk) {
+				failure.Line = chunk[batchErr.Index].Line
+				failure.Record = chunk[batchErr.Index].Record
+				failure.Rank = &chunk[batchErr.Index].Rank
+			}
+			return w.rollback(ctx, failure)
+		}
+		w.progress.update(func(counts *progressCounts) {
+			counts.Updated += len(chunk)
+		})
+		for _, planned := range chunk {
+			w.previous = append(w.previous, pb.RankUpdateRequest{
+				ID:       planned.Existing.ID,
+				JeeOpen:  planned.Existing.JeeOpen,
+				JeeClose: planned.Existing.JeeClose,
+			})
+		}
+	}
+	return nil
+}
+
+func (w *batchWriter) deleteRanks(ctx context.Context, ranks []pb.RankCollection) error {
+	for start := 0; start < len(ranks); start += pb.MaxBatchSize {
+		if err := cancelled(ctx); err != nil {
+			return w.rollback(ctx, &insertFailure{Action: "delete", Err: err})
+		}
+		chunk := ranks[start:min(start+pb.MaxBatchSize, len(ranks))]
+		ids := make([]string, len(chunk))
+		for idx, rank := range chunk {
+			ids[idx] = rank.ID
+		}
+
+		err := w.store.DeleteRecords(context.WithoutCancel(ctx), "ranks", ids)
+		if err != nil {
+			failure := &insertFailure{Action: "delete", Err: err}
+			var batchErr *pb.BatchError
+			if errors.As(err, &batchErr) && batchErr.Index < len(chunk) {
+				failure.Rank = &chunk[batchErr.Index]
+			}
+			return w.rollback(ctx, failure)
+		}
+		for _, rank := range chunk {
+			w.deleted = append(w.deleted, pb.RankCreateRequest{
+				ID:       rank.ID,
+				Year:     rank.Year,
+				Round:    rank.Round,
+				JeeOpen:  rank.JeeOpen,
+				JeeClose: rank.JeeClose,
+				College:  rank.College,
+				Branch:   rank.Branch,
+			})
+		}
+	}
+	return nil
+}
+
+// rollback undoes everything written so far in reverse: created ranks are
+// deleted, updated ranks get their old values back, deleted ranks are
+// recreated and finally the created branches and colleges are deleted
+func (w *batchWriter) rollback(ctx context.Context, failure *insertFailure) error {
+	failure.BackupKey = w.backupKey
+	w.progress.update(func(counts *progressCounts) {
+		counts.Stage = "Undoing the insert"
+		if !errors.Is(failure.Err, errCancelled) {
+			counts.Failed++
+		}
+	})
+	// finish undoing the insert even if the bot is shutting down
+	ctx = context.WithoutCancel(ctx)
+
+	steps := []struct {
+		description string
+		size        int
+		run         func(start, end int) error
+	}{
+		{"delete created ranks", len(w.rankIDs), func(start, end int) error {
+			return w.store.DeleteRecords(ctx, "ranks", w.rankIDs[start:end])
+		}},
+		{"revert updated ranks", len(w.previous), func(start, end int) error {
+			_, err := w.store.UpdateRanks(ctx, w.previous[start:end])
+			return err
+		}},
+		{"recreate deleted ranks", len(w.deleted), func(start, end int) error {
+			_, err := w.store.CreateRanks(ctx, w.deleted[start:end])
+			return err
+		}},
+		{"delete created branches", len(w.branchIDs), func(start, end int) error {
+			return w.store.DeleteRecords(ctx, "branches", w.branchIDs[start:end])
+		}},
+		{"delete created colleges", len(w.collegeIDs), func(start, end int) error {
+			return w.store.DeleteRecords(ctx, "colleges", w.collegeIDs[start:end])
+		}},
+	}
+	for _, step := range steps {
+		for start := 0; start < step.size; start += pb.MaxBatchSize {
+			err := step.run(start, min(start+pb.MaxBatchSize, step.size))
+			if err != nil {
+				failure.RollbackErr = fmt.Errorf("failed to %s: %w", step.description, err)
+				return failure
+			}
+		}
+	}
+	return failure
+}
+
+func describeBranch(branch pb.BranchCollection) string {
+	if branch.Ciwg {
+		return fmt.Sprintf("%s (CIWG)", branch.Code)
+	}
+	return branch.Code
+}
+
+func findBranch(branches []pb.BranchCollection, request pb.BranchCreateRequest) (pb.BranchCollection, bool) {
+	for _, branch := range branches {
+		if strings.EqualFold(branch.Name, request.Name) && branch.Ciwg == request.Ciwg {
+			return branch, true
+		}
+	}
+	return pb.BranchCollection{}, false
+}
+
+// cancelled returns why ctx was cancelled, errCancelled when the insert was stopped with its Cancel button
+func cancelled(ctx context.Context) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	return context.Cause(ctx)
+}
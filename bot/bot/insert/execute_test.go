@@ -0,0 +1,208 @@
+package insert
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/arinji2/dasa-bot/pb"
+)
+
+// failingStore rejects the nth call of an operation, counting from 1
+type failingStore struct {
+	*pb.MemoryStore
+	fail  map[string]int
+	calls map[string]int
+}
+
+func (s *failingStore) failed(operation string) error {
+	s.calls[operation]++
+	if s.calls[operation] == s.fail[operation] {
+		return fmt.Errorf("%s rejected", operation)
+	}
+	return nil
+}
+
+func (s *failingStore) CreateRanks(ctx context.Context, ranks []pb.RankCreateRequest) ([]pb.RankCollection, error) {
+	if err := s.failed("create ranks"); err != nil {
+		return nil, err
+	}
+	return s.MemoryStore.CreateRanks(ctx, ranks)
+}
+
+func (s *failingStore) UpdateRanks(ctx context.Context, ranks []pb.RankUpdateRequest) ([]pb.RankCollection, error) {
+	if err := s.failed("update ranks"); err != nil {
+		return nil, err
+	}
+	return s.MemoryStore.UpdateRanks(ctx, ranks)
+}
+
+func (s *failingStore) DeleteRecords(ctx context.Context, collection string, ids []string) error {
+	if err := s.failed("delete " + collection); err != nil {
+		return err
+	}
+	return s.MemoryStore.DeleteRecords(ctx, collection, ids)
+}
+
+// storeState lists the colleges, branches and ranks of store by id and value
+func storeState(t *testing.T, store pb.Store) []string {
+	t.Helper()
+	ctx := context.Background()
+	colleges, err := store.GetAllColleges(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	branches, err := store.GetAllBranches(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranks, err := store.GetAllRanks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var state []string
+	for _, college := range colleges {
+		state = append(state, "college "+college.ID)
+	}
+	for _, branch := range branches {
+		state = append(state, "branch "+branch.ID)
+	}
+	for _, rank := range ranks {
+		state = append(state, fmt.Sprintf("rank %s %d/%d %s %s %d-%d", rank.ID, rank.Year, rank.Round, rank.College, rank.Branch, rank.JeeOpen, rank.JeeClose))
+	}
+	slices.Sort(state)
+	return state
+}
+
+func TestExecuteRollback(t *testing.T) {
+	// more new colleges than fit one batch, each with a rank
+	var newColleges strings.Builder
+	newCollegeResolutions := map[string]string{branchResolutionKey("Ocean Engineering", "OC", false): createNew}
+	for idx := range pb.MaxBatchSize + 10 {
+		name := fmt.Sprintf("Test Institute %d", idx)
+		fmt.Fprintf(&newColleges, "%s,CS,Computer Science and Engineering,false,%d,%d\n", name, idx+1, idx+2)
+		newCollegeResolutions[collegeResolutionKey(name)] = createNew
+	}
+	newColleges.WriteString("National Institute of Technology Calicut,OC,Ocean Engineering,false,700,800\n")
+
+	// a full batch and then some of stored ranks for round 3
+	var round3 []pb.RankCollection
+	for idx := range pb.MaxBatchSize + 10 {
+		round3 = append(round3, pb.RankCollection{ID: fmt.Sprintf("old%d", idx), Year: 2024, Round: 3, College: "nitc", Branch: "cse", JeeOpen: idx, JeeClose: idx + 1})
+	}
+
+	tests := []struct {
+		name        string
+		file        string
+		round       int
+		mode        string
+		resolutions map[string]string
+		fail        map[string]int
+		// wantAction is what was being done when the insert failed
+		wantAction   string
+		wantRollback bool
+	}{
+		{
+			name: "later rank batch fails", file: newColleges.String(), round: 1, mode: modeReplace,
+			resolutions: newCollegeResolutions,
+			fail:        map[string]int{"create ranks": 2},
+			wantAction:  "create",
+		},
+		{
+			name: "update fails", round: 1, mode: modeUpsert,
+			file: "National Institute of Technology Calicut,CS,Computer Science and Engineering,false,150,250\n" +
+				"Test Institute,EC,Electronics and Communication Engineering,false,300,400\n",
+			resolutions: map[string]string{collegeResolutionKey("Test Institute"): createNew},
+			fail:        map[string]int{"update ranks": 1},
+			wantAction:  "update",
+		},
+		{
+			name: "later delete batch fails", round: 3, mode: modeReplace,
+			file:       "National Institute of Technology Calicut,CS,Computer Science and Engineering,false,1,2\n",
+			fail:       map[string]int{"delete ranks": 2},
+			wantAction: "delete",
+		},
+		{
+			name: "rollback fails", file: newColleges.String(), round: 1, mode: modeReplace,
+			resolutions: newCollegeResolutions,
+			// the replaced ranks are deleted first, deleting the created ones again fails
+			fail:         map[string]int{"create ranks": 2, "delete ranks": 2},
+			wantAction:   "create",
+			wantRollback: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := newTestStore()
+			requests := rankRequests(round3)
+			for start := 0; start < len(requests); start += pb.MaxBatchSize {
+				if _, err := memory.CreateRanks(context.Background(), requests[start:min(start+pb.MaxBatchSize, len(requests))]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			store := &failingStore{MemoryStore: memory, fail: tt.fail, calls: make(map[string]int)}
+			c := &InsertCommand{Data: loadData(t, store), PbAdmin: store}
+			before := storeState(t, store)
+
+			plan, err := c.parseRecords(readAll(csv.NewReader(strings.NewReader(testHeader+tt.file))), 2024, tt.round, tt.mode, tt.resolutions, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(plan.Errors) > 0 || len(plan.Unresolved) > 0 {
+				t.Fatalf("got %d errors and %d unresolved names, want a plan to execute", len(plan.Errors), len(plan.Unresolved))
+			}
+
+			_, err = c.executePlan(context.Background(), plan, nil)
+			var failure *insertFailure
+			if !errors.As(err, &failure) || failure.Action != tt.wantAction {
+				t.Fatalf("got %v, want a failure to %s", err, tt.wantAction)
+			}
+			if failure.BackupKey == "" {
+				t.Error("failure does not name the backup")
+			}
+
+			description := c.describeFailure(failure).embed.Description
+			if !tt.wantRollback {
+				if failure.RollbackErr != nil {
+					t.Fatalf("rollback failed: %v", failure.RollbackErr)
+				}
+				// replaced ranks come back under their old ids
+				if after := storeState(t, store); !slices.Equal(after, before) {
+					t.Fatalf("got %q after the rollback, want %q", after, before)
+				}
+				if !strings.Contains(description, "nothing was changed") {
+					t.Errorf("got %q, want it to say nothing was changed", description)
+				}
+				return
+			}
+
+			if failure.RollbackErr == nil {
+				t.Fatal("got no rollback error")
+			}
+			if !strings.Contains(description, failure.RollbackErr.Error()) || !strings.Contains(description, failure.BackupKey) {
+				t.Errorf("got %q, want the rollback error and the backup to restore", description)
+			}
+		})
+	}
+}
+
+func rankRequests(ranks []pb.RankCollection) []pb.RankCreateRequest {
+	requests := make([]pb.RankCreateRequest, len(ranks))
+	for idx, rank := range ranks {
+		requests[idx] = pb.RankCreateRequest{
+			ID:       rank.ID,
+			Year:     rank.Year,
+			Round:    rank.Round,
+			JeeOpen:  rank.JeeOpen,
+			JeeClose: rank.JeeClose,
+			College:  rank.College,
+			Branch:   rank.Branch,
+		}
+	}
+	return requests
+}
@@ -0,0 +1,335 @@
+package insert
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/arinji2/dasa-bot/bot/dataset"
+	"github.com/ledongthuc/pdf"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	formatCSV  = "csv"
+	formatXLSX = "xlsx"
+	formatPDF  = "pdf"
+)
+
+// recordReader yields the header and then every row, one per call, and
+// io.EOF once there are none left. *csv.Reader is one.
+type recordReader interface {
+	Read() ([]string, error)
+}
+
+// detectFormat goes by the file's magic bytes, falling back to its extension
+func detectFormat(fileName string, body []byte) string {
+	switch {
+	case bytes.HasPrefix(body, []byte("%PDF-")):
+		return formatPDF
+	case bytes.HasPrefix(body, []byte("PK\x03\x04")):
+		return formatXLSX
+	}
+
+	switch strings.ToLower(path.Ext(fileName)) {
+	case ".pdf":
+		return formatPDF
+	case ".xlsx":
+		return formatXLSX
+	}
+	return formatCSV
+}
+
+// openRecords returns a reader over the rows of body, starting with the header
+func openRecords(format string, body []byte, data *dataset.Snapshot) (recordReader, error) {
+	switch format {
+	case formatXLSX:
+		return openXLSX(body)
+	case formatPDF:
+		return openPDF(body, data)
+	}
+	return csv.NewReader(bytes.NewReader(body)), nil
+}
+
+// sliceReader hands out rows that were read up front. A row with an error
+// is reported along with it, the same as a malformed CSV line.
+type sliceReader struct {
+	rows [][]string
+	errs map[int]error
+	next int
+}
+
+func (r *sliceReader) Read() ([]string, error) {
+	if r.next >= len(r.rows) {
+		return nil, io.EOF
+	}
+	idx := r.next
+	r.next++
+	return r.rows[idx], r.errs[idx]
+}
+
+// readAll reads every row of reader up front, so the file can be parsed again later
+func readAll(reader recordReader) *sliceReader {
+	records := &sliceReader{errs: make(map[int]error)}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			records.errs[len(records.rows)] = err
+		}
+		records.rows = append(records.rows, row)
+	}
+	return records
+}
+
+// rewind starts over from the header
+func (r *sliceReader) rewind() {
+	r.next = 0
+}
+
+// openXLSX reads the first sheet of a workbook, laid out like the CSV.
+// Trailing empty cells are left out of rows, which columnMap.get treats as empty.
+func openXLSX(body []byte) (recordReader, error) {
+	file, err := excelize.OpenReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open workbook: %w", err)
+	}
+	defer file.Close()
+
+	sheets := file.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, errors.New("workbook has no sheets")
+	}
+	rows, err := file.GetRows(sheets[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sheet %s: %w", sheets[0], err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("could not read header from sheet %s", sheets[0])
+	}
+	return &sliceReader{rows: rows}, nil
+}
+
+// pdfColumns are the columns of the official DASA opening and closing rank
+// tables, matched against the header row by the start of their titles.
+// Earlier prefixes win, the titles have changed a little between years.
+var pdfColumns = []struct {
+	name     string
+	prefixes []string
+}{
+	{"institute", []string{"institute"}},
+	{"program", []string{"academic program", "program", "branch"}},
+	{"quota", []string{"quota", "category", "seat type"}},
+	{"open", []string{"opening"}},
+	{"close", []string{"closing"}},
+}
+
+var pdfRankRegex = regexp.MustCompile(`^\d+`)
+
+// pdfHeaderRow names the columns pdfRecord fills in
+var pdfHeaderRow = []string{"college_name", "branch_code", "branch_name", "is_ciwg", "first_rank", "last_rank"}
+
+type pdfRow struct {
+	cells map[string]string
+}
+
+// openPDF reads the rank tables of a DASA cutoff PDF. Cells are assigned to
+// columns by where the header titles start, and lines without ranks are the
+// wrapped rest of the institute or program name of the line before them.
+func openPDF(body []byte, data *dataset.Snapshot) (recordReader, error) {
+	reader, err := pdf.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pdf: %w", err)
+	}
+
+	var rows []pdfRow
+	var columnX map[string]float64
+	for pageNum := 1; pageNum <= reader.NumPage(); pageNum++ {
+		page := reader.Page(pageNum)
+		if page.V.IsNull() {
+			continue
+		}
+		lines, err := page.GetTextByRow()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read page %d: %w", pageNum, err)
+		}
+
+		// a wrapped line only continues a row above it on the same page, not a page title
+		continues := false
+		for _, line := range lines {
+			if header, ok := pdfHeader(line.Content); ok {
+				// every page repeats the header, the first one found is enough
+				if columnX == nil {
+					columnX = header
+				}
+				continues = false
+				continue
+			}
+			if columnX == nil {
+				continue
+			}
+
+			cells := make(map[string]string)
+			for _, text := range line.Content {
+				column := pdfColumnAt(columnX, text.X)
+				if column == "" {
+					continue
+				}
+				cells[column] = joinPDFText(cells[column], text.S)
+			}
+
+			if cells["open"] == "" && cells["close"] == "" {
+				if continues {
+					previous := rows[len(rows)-1]
+					for _, column := range []string{"institute", "program"} {
+						if cells[column] != "" {
+							previous.cells[column] = strings.TrimSpace(previous.cells[column] + " " + cells[column])
+						}
+					}
+				}
+				continue
+			}
+			rows = append(rows, pdfRow{cells: cells})
+			continues = true
+		}
+	}
+	if columnX == nil {
+		return nil, errors.New("could not find the opening and closing rank table header in the pdf")
+	}
+
+	records := &sliceReader{
+		rows: [][]string{pdfHeaderRow},
+		errs: make(map[int]error),
+	}
+	for _, row := range rows {
+		record, err := pdfRecord(row, data)
+		if err != nil {
+			records.errs[len(records.rows)] = err
+		}
+		records.rows = append(records.rows, record)
+	}
+	return records, nil
+}
+
+// pdfHeader returns where each column starts if line is the table header
+func pdfHeader(line pdf.TextHorizontal) (map[string]float64, bool) {
+	var words []pdf.Text
+	for _, text := range line {
+		if strings.TrimSpace(text.S) != "" {
+			words = append(words, text)
+		}
+	}
+
+	header := make(map[string]float64)
+	for _, column := range pdfColumns {
+		for _, prefix := range column.prefixes {
+			if x, ok := pdfTitleX(words, prefix); ok {
+				header[column.name] = x
+				break
+			}
+		}
+	}
+	return header, len(header) == len(pdfColumns)
+}
+
+// pdfTitleX finds the text run a title starting with prefix begins at
+func pdfTitleX(words []pdf.Text, prefix string) (float64, bool) {
+	prefix = strings.ReplaceAll(prefix, " ", "")
+	for idx := range words {
+		// titles may be split over several text runs, down to single letters
+		var title string
+		for _, word := range words[idx:] {
+			title += strings.ToLower(strings.ReplaceAll(word.S, " ", ""))
+			if len(title) >= len(prefix) {
+				break
+			}
+		}
+		if strings.HasPrefix(title, prefix) {
+			return words[idx].X, true
+		}
+	}
+	return 0, false
+}
+
+// joinPDFText appends a text run to a cell. Runs are either whole words or
+// single letters depending on the PDF, only words need a space between them.
+func joinPDFText(cell, text string) string {
+	if cell == "" {
+		return strings.TrimSpace(text)
+	}
+	if len(strings.TrimSpace(text)) > 1 || strings.HasPrefix(text, " ") || strings.HasSuffix(cell, " ") {
+		return strings.Join(strings.Fields(cell+" "+text), " ")
+	}
+	return cell + text
+}
+
+// pdfColumnAt finds the column whose title starts closest before x
+func pdfColumnAt(columnX map[string]float64, x float64) string {
+	type start struct {
+		name string
+		x    float64
+	}
+	starts := make([]start, 0, len(columnX))
+	for name, columnStart := range columnX {
+		starts = append(starts, start{name, columnStart})
+	}
+	sort.Slice(starts, func(a, b int) bool {
+		return starts[a].x < starts[b].x
+	})
+
+	// cells are often a little left of their title
+	const slack = 5
+	column := ""
+	for _, s := range starts {
+		if x+slack >= s.x {
+			column = s.name
+		}
+	}
+	return column
+}
+
+// pdfRecord converts a table row to the columns of pdfHeaderRow. The tables have no branch
+// codes, so the program has to match the name of a branch that already exists.
+func pdfRecord(row pdfRow, data *dataset.Snapshot) ([]string, error) {
+	institute := row.cells["institute"]
+	program := row.cells["program"]
+	quota := strings.ToLower(row.cells["quota"])
+	isCiwg := strings.Contains(quota, "ciwg") && !strings.Contains(quota, "non")
+
+	// preparatory ranks carry a trailing P, the number is what is stored
+	openRank := pdfRankRegex.FindString(row.cells["open"])
+	closeRank := pdfRankRegex.FindString(row.cells["close"])
+	record := []string{institute, "", program, strconv.FormatBool(isCiwg), openRank, closeRank}
+
+	if program == "" {
+		return record, nil
+	}
+
+	var branchCode string
+	for _, branch := range data.Branches {
+		if !strings.EqualFold(branch.Name, program) {
+			continue
+		}
+		branchCode = branch.Code
+		if branch.Ciwg == isCiwg {
+			break
+		}
+	}
+	if branchCode == "" {
+		return record, RankParseError{
+			Message: fmt.Sprintf("Program **%s** matches no branch, and the pdf has no branch code to create it with", program),
+			Fix:     fmt.Sprintf("Insert a rank of %s once through a CSV or XLSX row with its branch code, then import the pdf again", program),
+		}
+	}
+	record[1] = branchCode
+	return record, nil
+}
@@ -0,0 +1,143 @@
+package insert
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// readFixture opens a file under testdata the way an attachment is opened
+func readFixture(t *testing.T, name string) *sliceReader {
+	t.Helper()
+	body, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := openRecords(detectFormat(name, body), body, loadData(t, newTestStore()).Load())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return readAll(reader)
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		body     string
+		want     string
+	}{
+		{name: "pdf magic", fileName: "ranks.csv", body: "%PDF-1.4\n", want: formatPDF},
+		{name: "zip magic", fileName: "ranks.csv", body: "PK\x03\x04", want: formatXLSX},
+		{name: "pdf extension", fileName: "RANKS.PDF", body: "", want: formatPDF},
+		{name: "xlsx extension", fileName: "ranks.xlsx", body: "", want: formatXLSX},
+		{name: "csv", fileName: "ranks.csv", body: testHeader, want: formatCSV},
+		{name: "no extension", fileName: "ranks", body: testHeader, want: formatCSV},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectFormat(tt.fileName, []byte(tt.body)); got != tt.want {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenXLSX(t *testing.T) {
+	records := readFixture(t, "ranks.xlsx")
+	want := [][]string{
+		{"college_name", "branch_code", "branch_name", "is_ciwg", "first_rank", "last_rank"},
+		{"National Institute of Technology Calicut", "CS", "Computer Science and Engineering", "FALSE", "1000", "5000"},
+		{"National Institute of Technology Calicut", "CS", "Computer Science and Engineering", "CIWG", "100", "800"},
+		{"Indian Institute of Information Technology Allahabad", "EC", "Electronics and Communication Engineering", "false", "4000", "9000"},
+		// the empty closing rank is a trailing cell, so it is left out
+		{"National Institute of Technology Calicut", "EC", "Electronics and Communication Engineering", "FALSE", "2000"},
+	}
+	assertRows(t, records, want)
+	if len(records.errs) != 0 {
+		t.Fatalf("got row errors %v", records.errs)
+	}
+
+	c := &InsertCommand{Data: loadData(t, newTestStore())}
+	plan, err := c.parseRecords(records, 2024, 2, modeInsert, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Ranks) != 3 || len(plan.Errors) != 1 {
+		t.Fatalf("got %d ranks and %d errors, want 3 and 1", len(plan.Ranks), len(plan.Errors))
+	}
+	// lines count from the first row after the header
+	if plan.Errors[0].Line != 4 {
+		t.Fatalf("got an error on line %d, want line 4", plan.Errors[0].Line)
+	}
+}
+
+func TestOpenPDF(t *testing.T) {
+	records := readFixture(t, "ranks.pdf")
+	want := [][]string{
+		pdfHeaderRow,
+		// the institute and program are wrapped onto the line below
+		{"National Institute of Technology Calicut", "CS", "Computer Science and Engineering", "false", "1000", "5000"},
+		{"National Institute of Technology Calicut", "CS", "Computer Science and Engineering", "true", "100", "800"},
+		// Non-CIWG is not CIWG and the preparatory P is dropped
+		{"National Institute of Technology Calicut", "EC", "Electronics and Communication Engineering", "false", "2000", "3000"},
+		// on the second page, after a title that is not part of any row
+		{"Indian Institute of Information Technology Allahabad", "", "Biotechnology", "false", "7000", "9500"},
+	}
+	assertRows(t, records, want)
+	// no branch is named Biotechnology, so there is no code to store it under
+	for idx := range want {
+		if _, failed := records.errs[idx]; failed != (idx == 4) {
+			t.Errorf("row %d: got error %v", idx, records.errs[idx])
+		}
+	}
+
+	c := &InsertCommand{Data: loadData(t, newTestStore())}
+	plan, err := c.parseRecords(records, 2024, 2, modeInsert, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Errors) != 1 {
+		t.Fatalf("got %d errors, want 1", len(plan.Errors))
+	}
+	// the row is read fine, it is the program that needs fixing
+	parseErr := plan.Errors[0]
+	if parseErr.Line != 4 || !strings.Contains(parseErr.Message, "Biotechnology") || !strings.Contains(parseErr.Fix, "Biotechnology") {
+		t.Fatalf("got %+v, want an error on line 4 naming the program", parseErr)
+	}
+}
+
+func TestPDFColumnAt(t *testing.T) {
+	columnX := map[string]float64{"institute": 40, "program": 250, "quota": 430, "open": 490, "close": 545}
+	tests := []struct {
+		x    float64
+		want string
+	}{
+		{x: 10, want: ""},
+		{x: 40, want: "institute"},
+		{x: 200, want: "institute"},
+		{x: 250, want: "program"},
+		// within the slack left of a title
+		{x: 246, want: "program"},
+		{x: 244, want: "institute"},
+		{x: 600, want: "close"},
+	}
+	for _, tt := range tests {
+		if got := pdfColumnAt(columnX, tt.x); got != tt.want {
+			t.Errorf("x %v: got %q, want %q", tt.x, got, tt.want)
+		}
+	}
+}
+
+func assertRows(t *testing.T, records *sliceReader, want [][]string) {
+	t.Helper()
+	if len(records.rows) != len(want) {
+		t.Fatalf("got %d rows, want %d: %q", len(records.rows), len(want), records.rows)
+	}
+	for idx := range want {
+		if !slices.Equal(records.rows[idx], want[idx]) {
+			t.Errorf("row %d: got %q, want %q", idx, records.rows[idx], want[idx])
+		}
+	}
+}
@@ -3,430 +3,529 @@ package insert
 
 import (
 	"bytes"
-	"encoding/csv"
+	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
-	"strconv"
 	"strings"
-	"sync"
 
+	"github.com/arinji2/dasa-bot/bot/dataset"
 	"github.com/arinji2/dasa-bot/convert"
 	"github.com/arinji2/dasa-bot/env"
+	"github.com/arinji2/dasa-bot/network"
 	"github.com/arinji2/dasa-bot/pb"
 	responses "github.com/arinji2/dasa-bot/responses"
 	"github.com/bwmarrin/discordgo"
 )
 
+const (
+	// embedDescriptionLimit is the longest embed description Discord accepts
+	embedDescriptionLimit = 4096
+
+	confirmButtonPrefix = "insert_confirm_"
+	cancelButtonPrefix  = "insert_cancel_"
+	restoreButtonPrefix = "insert_restore_"
+)
+
 type InsertCommand struct {
-	RankData    []pb.RankCollection
-	BranchData  []pb.BranchCollection
-	CollegeData []pb.CollegeCollection
-	PbAdmin     pb.PocketbaseAdmin
-	BotEnv      env.Bot
+	Data    *dataset.Dataset
+	PbAdmin pb.Store
+	BotEnv  env.Bot
+	// Refresh brings Data up to date before a file is read against it
+	Refresh func(ctx context.Context) error
+	// Retention is applied to the backups after every insert takes one
+	Retention pb.Retention
 }
 
-func (c *InsertCommand) HandleInsertResponse(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func (c *InsertCommand) HandleInsertResponse(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
 	data := i.ApplicationCommandData()
-	c.HandleInsertData(s, i, &data)
+	c.HandleInsertData(ctx, s, i, &data)
 }
 
-func (c *InsertCommand) HandleInsertData(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionData) {
-	var logs []string
-	year := data.Options[1].StringValue()
-
-	yearInt, err := convert.StringToInt(year)
-	if err != nil {
-		log.Printf("Error converting year to int: %v", err)
-		responses.RespondWithEphemeralError(s, i, "Invalid year format")
-		return
+func (c *InsertCommand) HandleInsertData(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionData) {
+	options := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(data.Options))
+	for _, option := range data.Options {
+		options[option.Name] = option
 	}
 
-	round := data.Options[2].StringValue()
-
-	roundInt, err := convert.StringToInt(round)
-	if err != nil {
-		log.Printf("Error converting round to int: %v", err)
-		responses.RespondWithEphemeralError(s, i, "Invalid round format")
-		return
+	// year and round may instead be columns of the file
+	var yearInt, roundInt int
+	var err error
+	if option, ok := options["year"]; ok {
+		yearInt, err = convert.StringToInt(option.StringValue())
+		if err != nil {
+			log.Printf("Error converting year to int: %v", err)
+			responses.RespondWithEphemeralError(s, i, "Invalid year format")
+			return
+		}
 	}
-	attachmentID := i.ApplicationCommandData().Options[0].Value.(string)
-	attachmentURL := i.ApplicationCommandData().Resolved.Attachments[attachmentID].URL
 
-	// get the file contents
-	res, err := http.DefaultClient.Get(attachmentURL)
-	if err != nil {
-		responses.RespondWithEmbed(s, i, c.BotEnv, "Error getting file", err.Error(), nil)
-		return
+	if option, ok := options["round"]; ok {
+		roundInt, err = convert.StringToInt(option.StringValue())
+		if err != nil {
+			log.Printf("Error converting round to int: %v", err)
+			responses.RespondWithEphemeralError(s, i, "Invalid round format")
+			return
+		}
 	}
-	defer res.Body.Close()
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		responses.RespondWithEmbed(s, i, c.BotEnv, "Error reading file", err.Error(), nil)
-		return
+
+	mode := modeInsert
+	if option, ok := options["mode"]; ok {
+		mode = option.StringValue()
 	}
 
-	reader := bytes.NewReader(body)
-	csvReader := csv.NewReader(reader)
-	_, err = csvReader.Read()
-	if err != nil {
-		responses.RespondWithEmbed(s, i, c.BotEnv, "Error: Could not read header from file", err.Error(), nil)
-		return
+	dryRun := false
+	if option, ok := options["dry_run"]; ok {
+		dryRun = option.BoolValue()
 	}
 
-	userName := i.Member.User.Username
-	backupList, err := c.PbAdmin.ListBackups()
+	attachmentID := options["file"].Value.(string)
+	attachment := data.Resolved.Attachments[attachmentID]
+
+	// reading and inserting a file takes longer than an interaction may go unanswered
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
 	if err != nil {
-		responses.RespondWithEmbed(s, i, c.BotEnv, "Error listing backup", err.Error(), nil)
+		log.Printf("Error deferring insert: %v", err)
 		return
 	}
 
-	logs = append(logs, fmt.Sprintf("Found **%d** backups", len(backupList)))
-	if len(backupList) > 3 {
-		logs = append(logs, fmt.Sprintf("Reached limit of 3, deleting backup of key **%s**", backupList[0].Key))
-		err = c.PbAdmin.DeleteBackup(backupList[0].Key)
+	userName, userID := i.Member.User.Username, i.Member.User.ID
+	c.runJob(ctx, s, i, func(ctx context.Context, p *progress) reply {
+		p.stage("Refreshing data")
+		err := c.Refresh(ctx)
 		if err != nil {
-			responses.RespondWithEmbed(s, i, c.BotEnv, "Error deleting backup", err.Error(), nil)
-			return
+			log.Printf("Error refreshing data: %v", err)
+			return reply{embed: responses.CreateBaseEmbed("Error refreshing data", fmt.Sprintf("Could not refresh data: %v", err), c.BotEnv, nil)}
 		}
-	}
-	backupName, err := c.PbAdmin.CreateBackup(userName)
-	if err != nil {
-		responses.RespondWithEmbed(s, i, c.BotEnv, "Error creating backup", err.Error(), nil)
-		return
-	}
 
-	logs = append(logs, fmt.Sprintf("Created backup with name **%s**", backupName))
+		// get the file contents
+		p.stage("Downloading file")
+		body, err := network.GetFile(ctx, attachment.URL)
+		if err != nil {
+			if errors.Is(context.Cause(ctx), errCancelled) {
+				return c.cancelled("**" + attachment.Filename + "**")
+			}
+			return reply{embed: responses.CreateBaseEmbed("Error getting file", err.Error(), c.BotEnv, nil)}
+		}
 
-	parsedRanks, parsedErrs, err := c.parseRankingData(csvReader, yearInt, roundInt)
-	if err != nil {
-		responses.RespondWithEmbed(s, i, c.BotEnv, "Error parsing data", err.Error(), nil)
-		return
-	}
+		p.stage("Reading file")
+		reader, err := openRecords(detectFormat(attachment.Filename, body), body, c.Data.Load())
+		if err != nil {
+			return reply{embed: responses.CreateBaseEmbed("Error: Could not read file", err.Error(), c.BotEnv, nil)}
+		}
 
-	if len(parsedErrs) > 0 {
-		var description string
-		if len(parsedErrs) > 10 {
-			description += fmt.Sprintf("First 10 errors out of %d: \n", len(parsedErrs))
+		plan, err := c.parseRecords(readAll(reader), yearInt, roundInt, mode, nil, p)
+		if err != nil {
+			return reply{embed: responses.CreateBaseEmbed("Error: Could not read header from file", err.Error(), c.BotEnv, nil)}
 		}
-		for i, err := range parsedErrs {
-			if i > 10 {
-				break
-			}
-			// add 1 since we remove the header
-			description += fmt.Sprintf("Line Number: %d \n %s \n\n", (err.Line + 1), err.Message)
+		plan.FileName = attachment.Filename
+		plan.FileHash = fmt.Sprintf("%x", sha256.Sum256(body))
+		plan.UserName = userName
+		plan.UserID = userID
+		plan.DryRun = dryRun
+
+		if ctx.Err() != nil {
+			return c.cancelled(plan.describeRounds())
+		}
+		if len(plan.Unresolved) > 0 {
+			return c.resolutionPrompt(pendingPlans.put(plan), plan)
 		}
+		return c.proceed(ctx, plan, p)
+	})
+}
 
-		responses.RespondWithEmbed(s, i, c.BotEnv, "Error with parsing data", description, nil)
-		return
+// parseRecords parses records from the header on, counting the rows as it goes
+func (c *InsertCommand) parseRecords(records *sliceReader, year, round int, mode string, resolutions map[string]string, p *progress) (*importPlan, error) {
+	records.rewind()
+	p.update(func(counts *progressCounts) {
+		counts.Stage = "Parsing rows"
+		counts.Total = max(len(records.rows)-1, 0)
+		counts.Parsed = 0
+	})
+	plan, err := c.parseRankingData(&countingReader{recordReader: records, progress: p}, year, round, mode, resolutions)
+	if err != nil {
+		return nil, err
 	}
+	plan.records = records
+	p.update(func(counts *progressCounts) {
+		counts.Failed = len(plan.Errors)
+	})
+	return plan, nil
+}
 
-	// Replace the existing rank creation section with this code
+// proceed previews, rejects or inserts a fully resolved plan
+func (c *InsertCommand) proceed(ctx context.Context, plan *importPlan, p *progress) reply {
+	if plan.DryRun {
+		return c.preview(plan)
+	}
+	if len(plan.Errors) > 0 {
+		c.recordImport(ctx, plan, insertResult{}, errRejected, p)
+		r := reply{embed: responses.CreateBaseEmbed("Error with parsing data", describeParseErrors(plan.Errors), c.BotEnv, nil)}
+		return withErrorReport(r, parseErrorReport(plan.Errors))
+	}
+	return c.execute(ctx, plan, p)
+}
 
-	logs = append(logs, fmt.Sprintf("Parsed **%d** ranks", len(parsedRanks)))
+// reply is an answer to /insert, edited into the deferred interaction response
+type reply struct {
+	embed      *discordgo.MessageEmbed
+	components []discordgo.MessageComponent
+	files      []*discordgo.File
+}
 
-	var wg sync.WaitGroup
-	type RankCreateError struct {
-		Rank pb.RankCollection
-		Err  error
+// edit replaces the message of a deferred interaction, dropping components r does not have
+func (c *InsertCommand) edit(s *discordgo.Session, i *discordgo.InteractionCreate, r reply) {
+	components := r.components
+	if components == nil {
+		components = []discordgo.MessageComponent{}
 	}
+	_, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds:     &[]*discordgo.MessageEmbed{r.embed},
+		Components: &components,
+		Files:      r.files,
+	})
+	if err != nil {
+		log.Printf("Error editing insert response: %v", err)
+	}
+}
 
-	errorChan := make(chan RankCreateError, len(parsedRanks))
+// cancelled tells that an insert was stopped before anything was written or after it was undone
+func (c *InsertCommand) cancelled(rounds string) reply {
+	return reply{embed: responses.CreateBaseEmbed("Insert cancelled", fmt.Sprintf("Nothing was written for %s", rounds), c.BotEnv, nil)}
+}
 
-	// Create a buffered channel to limit concurrent goroutines to 10
-	semaphore := make(chan struct{}, 10)
+// HandleInsertButton handles the buttons and menus of /insert replies: confirming
+// or cancelling a dry run, resolving unknown names, stopping a running insert
+// and restoring a backup
+func (c *InsertCommand) HandleInsertButton(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	customID := i.MessageComponentData().CustomID
+	if jobID, ok := strings.CutPrefix(customID, stopButtonPrefix); ok {
+		c.handleStop(s, i, jobID)
+		return
+	}
+	if backupKey, ok := strings.CutPrefix(customID, restoreButtonPrefix); ok {
+		c.handleRestore(ctx, s, i, backupKey)
+		return
+	}
+	if resolveID, ok := strings.CutPrefix(customID, resolveSelectPrefix); ok {
+		c.handleResolve(ctx, s, i, resolveID)
+		return
+	}
 
-	skipped := 0
-	var skippedMutex sync.Mutex
+	planID, confirmed := strings.CutPrefix(customID, confirmButtonPrefix)
+	if !confirmed {
+		planID = strings.TrimPrefix(customID, cancelButtonPrefix)
+	}
 
-	for _, rank := range parsedRanks {
-		wg.Add(1)
-		go func(rank pb.RankCollection) {
-			defer wg.Done()
+	plan, ok := pendingPlans.take(planID)
+	if !ok {
+		responses.RespondWithEphemeralError(s, i, "This insert is no longer available, run /insert again")
+		return
+	}
 
-			// Acquire semaphore (blocks if 10 goroutines are already running)
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }() // Release semaphore when done
+	if !confirmed {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Embeds:     []*discordgo.MessageEmbed{c.cancelled(plan.describeRounds()).embed},
+				Components: []discordgo.MessageComponent{},
+			},
+		})
+		return
+	}
 
-			_, exists, err := c.PbAdmin.CreateRank(pb.RankCreateRequest{
-				Year:     rank.Year,
-				Round:    rank.Round,
-				JeeOpen:  rank.JeeOpen,
-				JeeClose: rank.JeeClose,
-				College:  rank.College,
-				Branch:   rank.Branch,
-			}, rank.Expand.Branch.Ciwg)
+	// inserting takes longer than an interaction may go unanswered
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		log.Printf("Error deferring insert confirmation: %v", err)
+		return
+	}
 
-			if exists {
-				skippedMutex.Lock()
-				skipped++
-				skippedMutex.Unlock()
-			}
+	plan.UserName = i.Member.User.Username
+	plan.UserID = i.Member.User.ID
+	c.runJob(ctx, s, i, func(ctx context.Context, p *progress) reply {
+		return c.confirm(ctx, plan, p)
+	})
+}
 
-			if err != nil && !exists {
-				errorChan <- RankCreateError{
-					Rank: rank,
-					Err:  err,
-				}
-			}
-		}(rank)
+// confirm inserts a previewed plan, saving the aliases picked while resolving it first
+func (c *InsertCommand) confirm(ctx context.Context, plan *importPlan, p *progress) reply {
+	if len(plan.PendingAliases) > 0 {
+		p.stage("Saving aliases")
+		c.saveAliases(ctx, plan.PendingAliases, plan.Resolutions)
 	}
+	return c.execute(ctx, plan, p)
+}
 
-	wg.Wait()
-	close(errorChan)
+// handleRestore restores the backup taken before an insert that could not be rolled back
+func (c *InsertCommand) handleRestore(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, backupKey string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		log.Printf("Error deferring backup restore: %v", err)
+		return
+	}
 
-	var createErrors []RankCreateError
-	for err := range errorChan {
-		createErrors = append(createErrors, err)
+	title := "Restoring backup"
+	description := fmt.Sprintf("Restoring backup **%s**, Pocketbase restarts once it is done", backupKey)
+	components := []discordgo.MessageComponent{}
+	err = c.PbAdmin.RestoreBackup(ctx, backupKey)
+	if err != nil {
+		log.Printf("Error restoring backup %s: %v", backupKey, err)
+		title = "Error restoring backup"
+		description = describeError(err)
+		// leave the button so the restore can be tried again
+		components = i.Message.Components
 	}
 
-	if len(createErrors) > 0 {
-		var description string
-		if len(createErrors) > 10 {
-			description += fmt.Sprintf("First 10 errors out of %d: \n", len(createErrors))
-		}
-		for i, err := range createErrors {
-			if i > 10 {
-				break
-			}
-			description += fmt.Sprintf("Failed to create rank with Jee Open: %d and College Name %s \n %s \n\n", err.Rank.JeeOpen, err.Rank.Expand.College.Name, err.Err.Error())
-		}
-		responses.RespondWithEmbed(s, i, c.BotEnv, "Error with creating data", description, nil)
-		return
+	_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds:     &[]*discordgo.MessageEmbed{responses.CreateBaseEmbed(title, description, c.BotEnv, nil)},
+		Components: &components,
+	})
+	if err != nil {
+		log.Printf("Error editing backup restore: %v", err)
 	}
+}
 
-	logs = append(logs, fmt.Sprintf("Skipped **%d** ranks", skipped))
-	logs = append(logs, fmt.Sprintf("Successfully created **%d** ranks", len(parsedRanks)-skipped))
+// execute runs plan and describes the outcome as an embed, with a restore
+// button when a failed insert could not be undone
+func (c *InsertCommand) execute(ctx context.Context, plan *importPlan, p *progress) reply {
+	result, err := c.executePlan(ctx, plan, p)
+	importID := c.recordImport(ctx, plan, result, err, p)
+	var stepErr *stepError
+	var failure *insertFailure
+	switch {
+	case errors.Is(err, errCancelled) && !(errors.As(err, &failure) && failure.RollbackErr != nil):
+		return c.cancelled(plan.describeRounds())
+	case errors.As(err, &stepErr):
+		return reply{embed: responses.CreateBaseEmbed(stepErr.Title, stepErr.Err.Error(), c.BotEnv, nil)}
+	case errors.As(err, &failure):
+		return c.describeFailure(failure)
+	case err != nil:
+		return reply{embed: responses.CreateBaseEmbed("Error inserting data", err.Error(), c.BotEnv, nil)}
+	}
 
 	fields := []*discordgo.MessageEmbedField{
 		{
 			Name:   "Logs",
-			Value:  strings.Join(logs, "\n"),
+			Value:  truncateText(strings.Join(result.Logs, "\n"), 1024),
 			Inline: true,
 		},
 	}
-	responses.RespondWithEmbed(s, i, c.BotEnv, "Successfully created ranks", fmt.Sprintf("Successfully inserted ranks for Year: %d and Round %d", yearInt, roundInt), fields)
-}
-
-type RankParseError struct {
-	Line    int
-	Record  []string
-	Message string
+	if len(result.Changes) > 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  "Changes",
+			Value: truncateLines(result.Changes, 1000),
+		})
+	}
+	description := fmt.Sprintf("Successfully inserted ranks for %s", plan.describeRounds())
+	if importID != "" {
+		description += fmt.Sprintf("\n\nRecorded as import `%s`, see `/imports show`", importID)
+	}
+	return reply{embed: responses.CreateBaseEmbed("Successfully created ranks", description, c.BotEnv, fields)}
 }
 
-func (c *InsertCommand) parseRankingData(reader *csv.Reader, year, round int) ([]pb.RankCollection, []RankParseError, error) {
-	var ranks []pb.RankCollection
-	var errors []RankParseError
-	lineNumber := 0
-
-	collegeIDMap := make(map[string]pb.CollegeCollection, len(c.CollegeData))
-	collegeNameMap := make(map[string]pb.CollegeCollection, len(c.CollegeData))
-	for _, college := range c.CollegeData {
-		collegeIDMap[college.ID] = college
-		normalizedName := strings.ToLower(strings.ReplaceAll(college.Name, ",", ""))
-		collegeNameMap[normalizedName] = college
+func (c *InsertCommand) describeFailure(failure *insertFailure) reply {
+	var description string
+	switch {
+	case failure.Rank != nil && failure.Line > 0:
+		// add 1 since we remove the header
+		description = fmt.Sprintf("Failed to %s rank on Line Number: %d with Jee Open: %d and College Name %s \n %s", failure.Action, failure.Line+1, failure.Rank.JeeOpen, failure.Rank.Expand.College.Name, describeError(failure.Err))
+	case failure.Rank != nil:
+		description = fmt.Sprintf("Failed to %s rank with Jee Open: %d and College Name %s \n %s", failure.Action, failure.Rank.JeeOpen, failure.Rank.Expand.College.Name, describeError(failure.Err))
+	case failure.College != nil:
+		description = fmt.Sprintf("Failed to create college %s \n %s", failure.College.Name, describeError(failure.Err))
+	case failure.Branch != nil:
+		description = fmt.Sprintf("Failed to create branch %s (%s) \n %s", failure.Branch.Name, failure.Branch.Code, describeError(failure.Err))
+	default:
+		description = describeError(failure.Err)
 	}
 
-	branchIDMap := make(map[string]pb.BranchCollection, len(c.BranchData))
-	branchKeyMap := make(map[string]pb.BranchCollection, len(c.BranchData))
-	for _, branch := range c.BranchData {
-		branchIDMap[branch.ID] = branch
-		key := fmt.Sprintf("%s-%s-%t", strings.ToLower(branch.Name), strings.ToLower(branch.Code), branch.Ciwg)
-		branchKeyMap[key] = branch
+	if failure.RollbackErr == nil {
+		description += "\n\nEverything created by this insert was removed again, nothing was changed."
+		r := reply{embed: responses.CreateBaseEmbed("Error with creating data", truncateText(description, embedDescriptionLimit), c.BotEnv, nil)}
+		return withErrorReport(r, failureErrorReport(failure))
 	}
 
-	for {
-		record, err := reader.Read()
-		lineNumber++
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			errors = append(errors, RankParseError{
-				Line:    lineNumber,
-				Message: fmt.Sprintf("Error reading record: %v", err),
-			})
-			continue
-		}
+	description += fmt.Sprintf("\n\nRemoving the ranks that were already created failed, so the round is only partly inserted: %s", describeError(failure.RollbackErr))
+	description += fmt.Sprintf("\n\nRestore the backup **%s** taken before the insert to undo it.", failure.BackupKey)
+	components := []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Restore backup",
+					Style:    discordgo.DangerButton,
+					CustomID: restoreButtonPrefix + failure.BackupKey,
+				},
+			},
+		},
+	}
+	r := reply{
+		embed:      responses.CreateBaseEmbed("Error with creating data", truncateText(description, embedDescriptionLimit), c.BotEnv, nil),
+		components: components,
+	}
+	return withErrorReport(r, failureErrorReport(failure))
+}
 
-		if len(record) < 7 {
-			errors = append(errors, RankParseError{
-				Line:    lineNumber,
-				Record:  record,
-				Message: fmt.Sprintf("Malformed row with %d columns", len(record)),
-			})
-			continue
-		}
+// preview shows what plan would write along with the full report, and holds
+// on to the plan so it can be confirmed as is
+func (c *InsertCommand) preview(plan *importPlan) reply {
+	report, err := plan.report()
+	if err != nil {
+		return reply{embed: responses.CreateBaseEmbed("Error creating report", err.Error(), c.BotEnv, nil)}
+	}
 
-		collegeName := record[0]
-		if collegeName == "" {
-			errors = append(errors, RankParseError{
-				Line:    lineNumber,
-				Record:  record,
-				Message: "Empty 'college_name' value",
-			})
-			continue
+	newBranches := "None"
+	if len(plan.NewBranches) > 0 {
+		names := make([]string, 0, len(plan.NewBranches))
+		for _, branch := range plan.NewBranches {
+			category := "Non CIWG"
+			if branch.Request.Ciwg {
+				category = "CIWG"
+			}
+			names = append(names, fmt.Sprintf("%s (%s, %s)", branch.Request.Name, branch.Request.Code, category))
 		}
+		newBranches = truncateLines(names, 1000)
+	}
 
-		branchCode := record[1]
-		if branchCode == "" {
-			errors = append(errors, RankParseError{
-				Line:    lineNumber,
-				Record:  record,
-				Message: "Empty 'branch_code' value",
-			})
-			continue
+	fields := []*discordgo.MessageEmbedField{
+		{Name: "Ranks to create", Value: fmt.Sprintf("%d", len(plan.Ranks)), Inline: true},
+		{Name: "Duplicates skipped", Value: fmt.Sprintf("%d", len(plan.Duplicates)), Inline: true},
+		{Name: "Ranks to update", Value: fmt.Sprintf("%d", len(plan.Updates)), Inline: true},
+		{Name: "Ranks to delete", Value: fmt.Sprintf("%d", len(plan.Replaced)), Inline: true},
+		{Name: "Errors", Value: fmt.Sprintf("%d", len(plan.Errors)), Inline: true},
+		{Name: fmt.Sprintf("New branches (%d)", len(plan.NewBranches)), Value: newBranches},
+	}
+	if len(plan.NewColleges) > 0 {
+		names := make([]string, len(plan.NewColleges))
+		for idx, college := range plan.NewColleges {
+			names[idx] = college.Request.Name
 		}
-
-		branchName := record[2]
-		if branchName == "" {
-			errors = append(errors, RankParseError{
-				Line:    lineNumber,
-				Record:  record,
-				Message: "Empty 'branch_name' value",
-			})
-			continue
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  fmt.Sprintf("New colleges (%d)", len(plan.NewColleges)),
+			Value: truncateLines(names, 1000),
+		})
+	}
+	if len(plan.Matches) > 0 {
+		matches := make([]string, len(plan.Matches))
+		for idx, match := range plan.Matches {
+			matches[idx] = fmt.Sprintf("%s → %s (`%s`)", match.Input, match.Name, match.ID)
 		}
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  fmt.Sprintf("Fuzzy matches (%d)", len(plan.Matches)),
+			Value: truncateLines(matches, 1000),
+		})
+	}
 
-		isCiWg, err := strconv.ParseBool(record[3])
-		if err != nil {
-			errors = append(errors, RankParseError{
-				Line:    lineNumber,
-				Record:  record,
-				Message: fmt.Sprintf("Invalid 'is_ciwg' value: %v", err),
-			})
-			continue
+	description := fmt.Sprintf("Dry run in %s mode for %s from **%s**, nothing has been written yet. The attached report lists every row.", plan.Mode, plan.describeRounds(), plan.FileName)
+	if plan.Mode == modeReplace {
+		description += fmt.Sprintf("\n\nOnly the stored ranks of the categories in the file are deleted: %s.", plan.describeCategories())
+	}
+	var components []discordgo.MessageComponent
+	if len(plan.Errors) > 0 {
+		description += "\n\nFix the errors and run the dry run again to be able to confirm.\n\n" + describeParseErrors(plan.Errors)
+	} else {
+		planID := pendingPlans.put(plan)
+		components = []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    "Confirm",
+						Style:    discordgo.SuccessButton,
+						CustomID: confirmButtonPrefix + planID,
+					},
+					discordgo.Button{
+						Label:    "Cancel",
+						Style:    discordgo.DangerButton,
+						CustomID: cancelButtonPrefix + planID,
+					},
+				},
+			},
 		}
+	}
 
-		firstRank, err := convert.StringToInt(record[4])
-		if err != nil {
-			errors = append(errors, RankParseError{
-				Line:    lineNumber,
-				Record:  record,
-				Message: fmt.Sprintf("Invalid 'first_rank' value: %v", err),
-			})
-			continue
-		}
+	r := reply{
+		embed:      responses.CreateBaseEmbed("Insert preview", description, c.BotEnv, fields),
+		components: components,
+		files: []*discordgo.File{
+			{
+				Name:        "insert-preview.csv",
+				ContentType: "text/csv",
+				Reader:      bytes.NewReader(report),
+			},
+		},
+	}
+	if len(plan.Errors) > 0 {
+		r = withErrorReport(r, parseErrorReport(plan.Errors))
+	}
+	return r
+}
 
-		lastRank, err := convert.StringToInt(record[5])
-		if err != nil {
-			errors = append(errors, RankParseError{
-				Line:    lineNumber,
-				Record:  record,
-				Message: fmt.Sprintf("Invalid 'last_rank' value: %v", err),
-			})
-			continue
+// describeParseErrors sums up parse errors by their suggested fix, the
+// attached error report has every one of them
+func describeParseErrors(parsedErrs []RankParseError) string {
+	counts := make(map[string]int)
+	var fixes []string
+	for _, parseErr := range parsedErrs {
+		if counts[parseErr.Fix] == 0 {
+			fixes = append(fixes, parseErr.Fix)
 		}
+		counts[parseErr.Fix]++
+	}
 
-		extraIDS := record[6]
-		var branchID string
-		var collegeID string
-
-		if extraIDS != "" {
-			if strings.Contains(extraIDS, ":") {
-				parts := strings.Split(extraIDS, ":")
-				if len(parts) == 2 {
-					if after, ok := strings.CutPrefix(parts[0], "b-"); ok {
-						branchID = after
-					}
-					if after, ok := strings.CutPrefix(parts[1], "c-"); ok {
-						collegeID = after
-					}
-				}
-			} else if after, ok := strings.CutPrefix(extraIDS, "c-"); ok {
-				collegeID = after
-			} else if after, ok := strings.CutPrefix(extraIDS, "b-"); ok {
-				branchID = after
-			} else {
-				errors = append(errors, RankParseError{
-					Line:    lineNumber,
-					Record:  record,
-					Message: fmt.Sprintf("Invalid 'extra_id' value: %v", extraIDS),
-				})
-			}
-		}
+	lines := make([]string, len(fixes))
+	for idx, fix := range fixes {
+		lines[idx] = fmt.Sprintf("**%d** rows: %s", counts[fix], fix)
+	}
+	return fmt.Sprintf("**%d** rows could not be parsed, the attached error report lists every one with a suggested fix.\n\n", len(parsedErrs)) +
+		truncateLines(lines, 1500)
+}
 
-		var collegeData pb.CollegeCollection
-		var found bool
-
-		if collegeID != "" {
-			collegeData, found = collegeIDMap[collegeID]
-			if !found {
-				errors = append(errors, RankParseError{
-					Line:    lineNumber,
-					Record:  record,
-					Message: fmt.Sprintf("Invalid 'college_id' value: %v", collegeID),
-				})
-				continue
-			}
-		} else {
-			normalizedName := strings.ToLower(strings.ReplaceAll(collegeName, ",", ""))
-			collegeData, found = collegeNameMap[normalizedName]
-			if !found {
-				errors = append(errors, RankParseError{
-					Line:    lineNumber,
-					Record:  record,
-					Message: fmt.Sprintf("College of name **%v** dosent exist. Try adding the college id with c-(collegeID) as a 6th argument", collegeName),
-				})
-				continue
-			}
-		}
+// withErrorReport attaches report to r, the reply still goes out without it if it cannot be written
+func withErrorReport(r reply, report []importError) reply {
+	files, err := errorReportFiles(report)
+	if err != nil {
+		log.Printf("Error writing insert error report: %v", err)
+		return r
+	}
+	r.files = append(r.files, files...)
+	return r
+}
 
-		var branchData pb.BranchCollection
-		if branchID != "" {
-			branchData, found = branchIDMap[branchID]
-			if !found {
-				errors = append(errors, RankParseError{
-					Line:    lineNumber,
-					Record:  record,
-					Message: fmt.Sprintf("Invalid 'branch_id' value: %v for 'college id' %v", branchID, collegeData.ID),
-				})
-				continue
-			}
-		} else {
-			key := fmt.Sprintf("%s-%s-%t", strings.ToLower(branchName), strings.ToLower(branchCode), isCiWg)
-			branchData, found = branchKeyMap[key]
-			if !found {
-				branchData, err = c.PbAdmin.CreateBranch(pb.BranchCreateRequest{
-					Name: branchName,
-					Code: branchCode,
-					Ciwg: isCiWg,
-				})
-
-				c.BranchData = append(c.BranchData, branchData)
-				if err != nil {
-					errors = append(errors, RankParseError{
-						Line:    lineNumber,
-						Record:  record,
-						Message: fmt.Sprintf("Error creating branch: %v", err),
-					})
-					continue
-				}
-				// add new branch to map
-				branchIDMap[branchData.ID] = branchData
-				branchKeyMap[key] = branchData
-			}
+// truncateLines joins lines, stopping before limit characters
+func truncateLines(lines []string, limit int) string {
+	var b strings.Builder
+	for idx, line := range lines {
+		more := fmt.Sprintf("\n...and %d more", len(lines)-idx)
+		if b.Len()+len(line)+1+len(more) > limit {
+			b.WriteString(more)
+			break
 		}
-
-		rankCollection := pb.RankCollection{
-			JeeOpen:  firstRank,
-			JeeClose: lastRank,
-			Year:     year,
-			Round:    round,
-			College:  collegeData.ID,
-			Branch:   branchData.ID,
-			Expand: struct {
-				College pb.CollegeCollection "json:\"college\""
-				Branch  pb.BranchCollection  "json:\"branch\""
-			}{
-				College: collegeData,
-				Branch:  branchData,
-			},
+		if idx > 0 {
+			b.WriteString("\n")
 		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
 
-		ranks = append(ranks, rankCollection)
+// describeError lists Pocketbase validation errors field by field so moderators
+// can tell which column of the row was rejected.
+func describeError(err error) string {
+	var apiErr *network.APIError
+	if !errors.As(err, &apiErr) || len(apiErr.Data) == 0 {
+		return err.Error()
 	}
 
-	return ranks, errors, nil
+	lines := []string{apiErr.Message}
+	for _, field := range apiErr.Fields() {
+		lines = append(lines, fmt.Sprintf("- `%s`: %s", field, apiErr.Data[field].Message))
+	}
+	return strings.Join(lines, "\n")
 }
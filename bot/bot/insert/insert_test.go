@@ -0,0 +1,398 @@
+package insert
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/arinji2/dasa-bot/bot/dataset"
+	"github.com/arinji2/dasa-bot/pb"
+)
+
+const testHeader = "college_name,branch_code,branch_name,is_ciwg,first_rank,last_rank\n"
+
+func newTestStore() *pb.MemoryStore {
+	return pb.NewMemoryStore(
+		[]pb.CollegeCollection{
+			{ID: "nitc", Name: "National Institute of Technology Calicut", Alias: "NITC"},
+			{ID: "iiita", Name: "Indian Institute of Information Technology Allahabad", Alias: "IIITA"},
+		},
+		[]pb.BranchCollection{
+			{ID: "cse", Name: "Computer Science and Engineering", Code: "CS"},
+			{ID: "cseciwg", Name: "Computer Science and Engineering", Code: "CS", Ciwg: true},
+			{ID: "ece", Name: "Electronics and Communication Engineering", Code: "EC"},
+		},
+		[]pb.RankCollection{
+			{ID: "rank1", Year: 2024, Round: 1, College: "nitc", Branch: "cse", JeeOpen: 100, JeeClose: 200},
+			{ID: "rank2", Year: 2024, Round: 1, College: "iiita", Branch: "ece", JeeOpen: 500, JeeClose: 900},
+			{ID: "rank3", Year: 2023, Round: 1, College: "nitc", Branch: "cse", JeeOpen: 90, JeeClose: 180},
+		},
+	)
+}
+
+// loadData publishes everything in store the way the background sync does
+func loadData(t *testing.T, store pb.Store) *dataset.Dataset {
+	t.Helper()
+	ctx := context.Background()
+	colleges, err := store.GetAllColleges(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	branches, err := store.GetAllBranches(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranks, err := store.GetAllRanks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collegeAliases, err := store.GetAllCollegeAliases(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	branchAliases, err := store.GetAllBranchAliases(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := dataset.New()
+	d.Publish(dataset.NewSnapshot(colleges, branches, ranks, dataset.Aliases{Colleges: collegeAliases, Branches: branchAliases}))
+	return d
+}
+
+// storedRank finds the rank of a round, college and branch in store
+func storedRank(t *testing.T, store pb.Store, year, round int, college, branch string) (pb.RankCollection, bool) {
+	t.Helper()
+	ranks, err := store.GetAllRanks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rank := range ranks {
+		if rank.Year == year && rank.Round == round && rank.College == college && rank.Branch == branch {
+			return rank, true
+		}
+	}
+	return pb.RankCollection{}, false
+}
+
+func TestInsertParseAndExecute(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		year, round int
+		mode        string
+		resolutions map[string]string
+
+		wantRanks      int
+		wantDuplicates int
+		wantUpdates    int
+		wantReplaced   int
+		wantErrors     int
+		wantUnresolved int
+		// wantStored is how many ranks Pocketbase has after the plan is executed
+		wantStored int
+		check      func(t *testing.T, store pb.Store)
+	}{
+		{
+			name: "creates new ranks and skips stored ones",
+			file: testHeader +
+				"National Institute of Technology Calicut,EC,Electronics and Communication Engineering,false,300,400\n" +
+				"National Institute of Technology Calicut,CS,Computer Science and Engineering,false,100,200\n" +
+				"National Institute of Technology Calicut,CS,Computer Science and Engineering,CIWG,10,20\n",
+			year: 2024, round: 1, mode: modeInsert,
+			wantRanks: 2, wantDuplicates: 1, wantStored: 5,
+			check: func(t *testing.T, store pb.Store) {
+				rank, ok := storedRank(t, store, 2024, 1, "nitc", "cseciwg")
+				if !ok || rank.JeeOpen != 10 || rank.JeeClose != 20 {
+					t.Errorf("got CIWG rank %+v, want 10 to 20", rank)
+				}
+			},
+		},
+		{
+			name: "repeated rows are only created once",
+			file: testHeader +
+				"National Institute of Technology Calicut,EC,Electronics and Communication Engineering,false,300,400\n" +
+				"national institute of technology calicut,ec,electronics and communication engineering,false,300,400\n",
+			year: 2024, round: 2, mode: modeInsert,
+			wantRanks: 1, wantDuplicates: 1, wantStored: 4,
+		},
+		{
+			name: "commas in college names are ignored",
+			file: testHeader +
+				"\"National Institute of Technology, Calicut\",EC,Electronics and Communication Engineering,false,300,400\n",
+			year: 2024, round: 1, mode: modeInsert,
+			wantRanks: 1, wantStored: 4,
+			check: func(t *testing.T, store pb.Store) {
+				if _, ok := storedRank(t, store, 2024, 1, "nitc", "ece"); !ok {
+					t.Error("rank was not created for nitc")
+				}
+			},
+		},
+		{
+			name: "extra ids pick the college and branch",
+			file: "college_name,branch_code,branch_name,is_ciwg,first_rank,last_rank,extra_id\n" +
+				"Zephyr Academy of Marine Studies,EC,Electronics and Communication Engineering,false,300,400,c-iiita\n" +
+				"National Institute of Technology Calicut,XX,Anything,false,300,400,b-ece\n" +
+				"Zephyr Academy of Marine Studies,XX,Anything,true,300,400,b-cseciwg:c-iiita\n",
+			year: 2024, round: 2, mode: modeInsert,
+			wantRanks: 3, wantStored: 6,
+			check: func(t *testing.T, store pb.Store) {
+				if _, ok := storedRank(t, store, 2024, 2, "iiita", "cseciwg"); !ok {
+					t.Error("rank was not created for both extra ids")
+				}
+			},
+		},
+		{
+			name: "upsert corrects changed ranks",
+			file: testHeader +
+				"National Institute of Technology Calicut,CS,Computer Science and Engineering,false,150,250\n" +
+				"Indian Institute of Information Technology Allahabad,EC,Electronics and Communication Engineering,false,500,900\n",
+			year: 2024, round: 1, mode: modeUpsert,
+			wantUpdates: 1, wantDuplicates: 1, wantStored: 3,
+			check: func(t *testing.T, store pb.Store) {
+				rank, _ := storedRank(t, store, 2024, 1, "nitc", "cse")
+				if rank.ID != "rank1" || rank.JeeOpen != 150 || rank.JeeClose != 250 {
+					t.Errorf("got %+v, want rank1 updated to 150 to 250", rank)
+				}
+			},
+		},
+		{
+			name: "replace deletes the stored round only",
+			file: testHeader +
+				"Indian Institute of Information Technology Allahabad,EC,Electronics and Communication Engineering,false,450,850\n",
+			year: 2024, round: 1, mode: modeReplace,
+			wantRanks: 1, wantReplaced: 2, wantStored: 2,
+			check: func(t *testing.T, store pb.Store) {
+				if _, ok := storedRank(t, store, 2024, 1, "nitc", "cse"); ok {
+					t.Error("replaced rank of nitc is still stored")
+				}
+				if _, ok := storedRank(t, store, 2023, 1, "nitc", "cse"); !ok {
+					t.Error("rank of another year was deleted")
+				}
+			},
+		},
+		{
+			name: "replace keeps the categories not in the file",
+			file: testHeader +
+				"National Institute of Technology Calicut,CS,Computer Science and Engineering,CIWG,10,20\n",
+			year: 2024, round: 1, mode: modeReplace,
+			wantRanks: 1, wantStored: 4,
+			check: func(t *testing.T, store pb.Store) {
+				if _, ok := storedRank(t, store, 2024, 1, "nitc", "cse"); !ok {
+					t.Error("non CIWG rank was deleted by a CIWG file")
+				}
+			},
+		},
+		{
+			name: "year and round columns override the options",
+			file: "year,round," + testHeader +
+				"2022,3,National Institute of Technology Calicut,CS,Computer Science and Engineering,false,80,160\n" +
+				"2022,4,National Institute of Technology Calicut,CS,Computer Science and Engineering,false,85,170\n",
+			mode:      modeInsert,
+			wantRanks: 2, wantStored: 5,
+			check: func(t *testing.T, store pb.Store) {
+				if _, ok := storedRank(t, store, 2022, 4, "nitc", "cse"); !ok {
+					t.Error("rank of round 4 was not created")
+				}
+			},
+		},
+		{
+			name: "rows that cannot be parsed are errors",
+			file: testHeader +
+				",CS,Computer Science and Engineering,false,100,200\n" +
+				"National Institute of Technology Calicut,CS,Computer Science and Engineering,false,abc,200\n" +
+				"National Institute of Technology Calicut,CS,Computer Science and Engineering,maybe,100,200\n" +
+				"National Institute of Technology Calicut,CS\n",
+			year: 2024, round: 3, mode: modeInsert,
+			wantErrors: 4,
+		},
+		{
+			name: "missing year without an option is an error",
+			file: testHeader +
+				"National Institute of Technology Calicut,CS,Computer Science and Engineering,false,100,200\n",
+			round: 1, mode: modeInsert,
+			wantErrors: 1,
+		},
+		{
+			name: "unknown names wait for a resolution",
+			file: testHeader +
+				"Zephyr Academy of Marine Studies,CS,Computer Science and Engineering,false,100,200\n" +
+				"Zephyr Academy of Marine Studies,OC,Ocean Engineering,false,300,400\n",
+			year: 2024, round: 2, mode: modeInsert,
+			wantUnresolved: 2,
+		},
+		{
+			name: "resolved names create the college and branch",
+			file: testHeader +
+				"Zephyr Academy of Marine Studies,CS,Computer Science and Engineering,false,100,200\n" +
+				"Zephyr Academy of Marine Studies,OC,Ocean Engineering,false,300,400\n",
+			year: 2024, round: 2, mode: modeInsert,
+			resolutions: map[string]string{
+				collegeResolutionKey("Zephyr Academy of Marine Studies"): createNew,
+				branchResolutionKey("Ocean Engineering", "OC", false):    createNew,
+			},
+			wantRanks: 2, wantStored: 5,
+			check: func(t *testing.T, store pb.Store) {
+				colleges, _ := store.GetAllColleges(context.Background())
+				branches, _ := store.GetAllBranches(context.Background())
+				if len(colleges) != 3 || len(branches) != 4 {
+					t.Errorf("got %d colleges and %d branches, want 3 and 4", len(colleges), len(branches))
+				}
+			},
+		},
+		{
+			name: "resolution to an existing college",
+			file: testHeader +
+				"Zephyr Academy of Marine Studies,CS,Computer Science and Engineering,false,100,200\n",
+			year: 2024, round: 2, mode: modeInsert,
+			resolutions: map[string]string{
+				collegeResolutionKey("Zephyr Academy of Marine Studies"): "iiita",
+			},
+			wantRanks: 1, wantStored: 4,
+			check: func(t *testing.T, store pb.Store) {
+				if _, ok := storedRank(t, store, 2024, 2, "iiita", "cse"); !ok {
+					t.Error("rank was not created for the resolved college")
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore()
+			c := &InsertCommand{Data: loadData(t, store), PbAdmin: store}
+
+			records := readAll(csv.NewReader(strings.NewReader(tt.file)))
+			plan, err := c.parseRecords(records, tt.year, tt.round, tt.mode, tt.resolutions, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(plan.Ranks) != tt.wantRanks || len(plan.Duplicates) != tt.wantDuplicates ||
+				len(plan.Updates) != tt.wantUpdates || len(plan.Replaced) != tt.wantReplaced ||
+				len(plan.Errors) != tt.wantErrors || len(plan.Unresolved) != tt.wantUnresolved {
+				t.Fatalf("got %d ranks, %d duplicates, %d updates, %d replaced, %d errors and %d unresolved, want %d, %d, %d, %d, %d and %d",
+					len(plan.Ranks), len(plan.Duplicates), len(plan.Updates), len(plan.Replaced), len(plan.Errors), len(plan.Unresolved),
+					tt.wantRanks, tt.wantDuplicates, tt.wantUpdates, tt.wantReplaced, tt.wantErrors, tt.wantUnresolved)
+			}
+			if len(plan.Errors) > 0 || len(plan.Unresolved) > 0 {
+				return
+			}
+
+			result, err := c.executePlan(context.Background(), plan, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Created != tt.wantRanks || result.Updated != tt.wantUpdates {
+				t.Errorf("created %d and updated %d ranks, want %d and %d", result.Created, result.Updated, tt.wantRanks, tt.wantUpdates)
+			}
+			backups, err := store.ListBackups(context.Background())
+			if err != nil || len(backups) != 1 {
+				t.Errorf("got %d backups and %v, want the one taken before the insert", len(backups), err)
+			}
+			ranks, err := store.GetAllRanks(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ranks) != tt.wantStored {
+				t.Errorf("got %d stored ranks, want %d", len(ranks), tt.wantStored)
+			}
+			if tt.check != nil {
+				tt.check(t, store)
+			}
+		})
+	}
+}
+
+func TestInsertMatchesRecordedAliases(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore()
+	if _, err := store.CreateCollegeAlias(ctx, pb.CollegeAliasCreateRequest{Name: "REC Calicut", College: "nitc"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CreateBranchAlias(ctx, pb.BranchAliasCreateRequest{Name: "Computer Engineering", Code: "CO", Branch: "cse"}); err != nil {
+		t.Fatal(err)
+	}
+	c := &InsertCommand{Data: loadData(t, store), PbAdmin: store}
+	file := testHeader + "REC Calicut,CO,Computer Engineering,false,100,200\n"
+
+	plan, err := c.parseRecords(readAll(csv.NewReader(strings.NewReader(file))), 2024, 2, modeInsert, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Ranks) != 1 || len(plan.Unresolved) != 0 {
+		t.Fatalf("got %d ranks and %d unresolved, want 1 and 0", len(plan.Ranks), len(plan.Unresolved))
+	}
+	if rank := plan.Ranks[0].Rank; rank.College != "nitc" || rank.Branch != "cse" {
+		t.Fatalf("got %s %s, want nitc cse", rank.College, rank.Branch)
+	}
+}
+
+func TestInsertSkipsRanksStoredSincePlanning(t *testing.T) {
+	store := newTestStore()
+	c := &InsertCommand{Data: loadData(t, store), PbAdmin: store}
+	file := testHeader + "National Institute of Technology Calicut,EC,Electronics and Communication Engineering,false,300,400\n"
+	plan, err := c.parseRecords(readAll(csv.NewReader(strings.NewReader(file))), 2024, 1, modeInsert, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// another insert stores the same rank while the plan waits to be confirmed
+	_, err = store.CreateRanks(context.Background(), []pb.RankCreateRequest{{Year: 2024, Round: 1, College: "nitc", Branch: "ece", JeeOpen: 300, JeeClose: 400}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Data = loadData(t, store)
+
+	result, err := c.executePlan(context.Background(), plan, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Created != 0 || result.Skipped != 1 {
+		t.Errorf("created %d and skipped %d ranks, want 0 and 1", result.Created, result.Skipped)
+	}
+}
+
+// prunedStore holds many old backups, so retention deletes more than a field can list
+type prunedStore struct {
+	*pb.MemoryStore
+	old []pb.BackupCollection
+}
+
+func (s *prunedStore) ListBackups(ctx context.Context) ([]pb.BackupCollection, error) {
+	backups, err := s.MemoryStore.ListBackups(ctx)
+	return append(backups, s.old...), err
+}
+
+func (s *prunedStore) DeleteBackup(_ context.Context, _ string) error {
+	return nil
+}
+
+func TestExecuteLogsFitEmbedField(t *testing.T) {
+	store := &prunedStore{MemoryStore: newTestStore()}
+	for day := range 60 {
+		modified := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, day)
+		store.old = append(store.old, pb.BackupCollection{
+			Key:      fmt.Sprintf("moderator_%s.zip", modified.Format("02_01_2006_15_04_05")),
+			Modified: modified.Format("2006-01-02 15:04:05.000Z"),
+		})
+	}
+	c := &InsertCommand{Data: loadData(t, store), PbAdmin: store, Retention: pb.Retention{Keep: 1}}
+	file := testHeader + "National Institute of Technology Calicut,EC,Electronics and Communication Engineering,false,300,400\n"
+	plan, err := c.parseRecords(readAll(csv.NewReader(strings.NewReader(file))), 2024, 2, modeInsert, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := c.execute(context.Background(), plan, nil)
+	if len(r.embed.Fields) == 0 || r.embed.Fields[0].Name != "Logs" {
+		t.Fatalf("got fields %+v, want the logs first", r.embed.Fields)
+	}
+	for _, field := range r.embed.Fields {
+		if length := utf8.RuneCountInString(field.Value); length > 1024 {
+			t.Errorf("field %s is %d characters, Discord allows 1024", field.Name, length)
+		}
+	}
+}
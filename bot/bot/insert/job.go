@@ -0,0 +1,217 @@
+package insert
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	responses "github.com/arinji2/dasa-bot/responses"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	stopButtonPrefix = "insert_stop_"
+	// progressInterval is how often a running insert edits its progress into the response
+	progressInterval = 3 * time.Second
+	progressBarWidth = 20
+)
+
+// errCancelled is returned by an insert stopped with its Cancel button
+var errCancelled = errors.New("insert was cancelled")
+
+// progress counts what a running insert has done so far. It is written by the
+// job and read by the progress updates, a nil progress counts nothing.
+type progress struct {
+	mu      sync.Mutex
+	counts  progressCounts
+	started time.Time
+}
+
+type progressCounts struct {
+	Stage string
+	// Total is the number of rows of the file
+	Total   int
+	Parsed  int
+	Created int
+	Updated int
+	Skipped int
+	Failed  int
+}
+
+func (p *progress) update(fn func(counts *progressCounts)) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fn(&p.counts)
+}
+
+func (p *progress) snapshot() progressCounts {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.counts
+}
+
+// elapsed is how long the job has been running
+func (p *progress) elapsed() time.Duration {
+	if p == nil || p.started.IsZero() {
+		return 0
+	}
+	return time.Since(p.started)
+}
+
+func (p *progress) stage(stage string) {
+	p.update(func(counts *progressCounts) {
+		counts.Stage = stage
+	})
+}
+
+// countingReader counts the rows handed to the parser
+type countingReader struct {
+	recordReader
+	progress *progress
+	header   bool
+}
+
+func (r *countingReader) Read() ([]string, error) {
+	row, err := r.recordReader.Read()
+	switch {
+	case err == io.EOF:
+	case !r.header:
+		r.header = true
+	default:
+		r.progress.update(func(counts *progressCounts) {
+			counts.Parsed++
+		})
+	}
+	return row, err
+}
+
+// jobStore holds the cancel functions of running inserts by the id of their Cancel button
+type jobStore struct {
+	mu   sync.Mutex
+	jobs map[string]context.CancelFunc
+}
+
+var runningJobs = &jobStore{jobs: make(map[string]context.CancelFunc)}
+
+func (js *jobStore) start(ctx context.Context) (string, context.Context, func()) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	id := rand.Text()
+	ctx, cancel := context.WithCancelCause(ctx)
+	js.jobs[id] = func() { cancel(errCancelled) }
+	done := func() {
+		js.mu.Lock()
+		defer js.mu.Unlock()
+		delete(js.jobs, id)
+		cancel(nil)
+	}
+	return id, ctx, done
+}
+
+// cancel stops a running job, it is false once the job has finished
+func (js *jobStore) cancel(id string) bool {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	cancel, ok := js.jobs[id]
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// runJob does work in the background, editing its progress into the deferred
+// response of i every few seconds until work returns the final reply
+func (c *InsertCommand) runJob(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, work func(ctx context.Context, p *progress) reply) {
+	jobID, jobCtx, done := runningJobs.start(ctx)
+	p := &progress{started: time.Now()}
+	p.stage("Starting")
+
+	stopped := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			c.edit(s, i, c.progressReply(jobID, p.snapshot(), jobCtx.Err() != nil))
+			select {
+			case <-stopped:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	go func() {
+		final := work(jobCtx, p)
+		done()
+		close(stopped)
+		// the final reply must not be overwritten by a progress update still in flight
+		<-finished
+		c.edit(s, i, final)
+	}()
+}
+
+// progressReply shows the counts of a running insert with a bar and its Cancel button
+func (c *InsertCommand) progressReply(jobID string, counts progressCounts, cancelling bool) reply {
+	done := counts.Parsed
+	if counts.Created+counts.Updated+counts.Skipped+counts.Failed > 0 {
+		done = counts.Created + counts.Updated + counts.Skipped + counts.Failed
+	}
+	description := counts.Stage
+	if counts.Total > 0 {
+		ratio := min(float64(done)/float64(counts.Total), 1)
+		filled := int(ratio * progressBarWidth)
+		description += fmt.Sprintf("\n`%s%s` %d%%", strings.Repeat("█", filled), strings.Repeat("░", progressBarWidth-filled), int(ratio*100))
+	}
+	if cancelling {
+		description += "\n\nCancelling, anything already written is being removed again."
+	}
+
+	fields := []*discordgo.MessageEmbedField{
+		{Name: "Parsed", Value: fmt.Sprintf("%d", counts.Parsed), Inline: true},
+		{Name: "Created", Value: fmt.Sprintf("%d", counts.Created), Inline: true},
+		{Name: "Updated", Value: fmt.Sprintf("%d", counts.Updated), Inline: true},
+		{Name: "Skipped", Value: fmt.Sprintf("%d", counts.Skipped), Inline: true},
+		{Name: "Failed", Value: fmt.Sprintf("%d", counts.Failed), Inline: true},
+	}
+	var components []discordgo.MessageComponent
+	if !cancelling {
+		components = []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    "Cancel",
+						Style:    discordgo.DangerButton,
+						CustomID: stopButtonPrefix + jobID,
+					},
+				},
+			},
+		}
+	}
+	return reply{
+		embed:      responses.CreateBaseEmbed("Inserting ranks", description, c.BotEnv, fields),
+		components: components,
+	}
+}
+
+// handleStop cancels a running insert, its job edits in the outcome once it has stopped
+func (c *InsertCommand) handleStop(s *discordgo.Session, i *discordgo.InteractionCreate, jobID string) {
+	if !runningJobs.cancel(jobID) {
+		responses.RespondWithEphemeralError(s, i, "This insert has already finished")
+		return
+	}
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+}
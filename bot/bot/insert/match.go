@@ -0,0 +1,242 @@
+package insert
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/arinji2/dasa-bot/pb"
+)
+
+const (
+	// matchThreshold is the lowest similarity a fuzzy match is accepted at
+	matchThreshold = 0.85
+	// matchMargin is how far ahead of the runner up a match has to be to be unambiguous
+	matchMargin = 0.05
+	// suggestionFloor is the lowest similarity still worth suggesting
+	suggestionFloor = 0.5
+	suggestionCount = 3
+)
+
+// nameExpansions spells out the abbreviations used in institute and program names
+var nameExpansions = map[string]string{
+	"nit":   "national institute of technology",
+	"iiit":  "indian institute of information technology",
+	"iit":   "indian institute of technology",
+	"iiest": "indian institute of engineering science and technology",
+	"spa":   "school of planning and architecture",
+	"engg":  "engineering",
+	"engr":  "engineering",
+	"tech":  "technology",
+	"sci":   "science",
+	"univ":  "university",
+	"inst":  "institute",
+}
+
+// normalizeName lowercases name, drops punctuation and expands abbreviations
+func normalizeName(name string) string {
+	name = strings.ReplaceAll(strings.ToLower(name), "&", " and ")
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var tokens []string
+	for _, word := range words {
+		if word == "the" {
+			continue
+		}
+		if expanded, ok := nameExpansions[word]; ok {
+			word = expanded
+		}
+		tokens = append(tokens, word)
+	}
+	return strings.Join(tokens, " ")
+}
+
+// genericWords are shared by so many names that they say little about which one is meant
+var genericWords = map[string]bool{
+	"of": true, "and": true, "for": true, "in": true, "at": true,
+	"national": true, "indian": true, "institute": true, "technology": true, "information": true,
+	"university": true, "college": true, "school": true, "engineering": true, "science": true,
+}
+
+// similarity scores two normalized names from 0 to 1. Word order is ignored
+// by also comparing the names with their words sorted. Long shared words like
+// "national institute of technology" would make any two NITs look alike, so
+// the words left without the generic ones have to be alike as well.
+func similarity(a, b string) float64 {
+	score := wordSimilarity(a, b)
+	distinctA, distinctB := distinctWords(a), distinctWords(b)
+	if distinctA != "" && distinctB != "" {
+		score = min(score, wordSimilarity(distinctA, distinctB))
+	}
+	return score
+}
+
+func wordSimilarity(a, b string) float64 {
+	return max(editSimilarity(a, b), editSimilarity(sortedWords(a), sortedWords(b)))
+}
+
+func sortedWords(s string) string {
+	words := strings.Fields(s)
+	slices.Sort(words)
+	return strings.Join(words, " ")
+}
+
+func distinctWords(s string) string {
+	var words []string
+	for _, word := range strings.Fields(s) {
+		if !genericWords[word] {
+			words = append(words, word)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func editSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+type matchCandidate[T any] struct {
+	Item  T
+	Score float64
+}
+
+// fuzzyMatch is the outcome of looking up one name
+type fuzzyMatch[T any] struct {
+	// Found is set when the best candidate is confident and unambiguous
+	Found bool
+	// Candidates are the closest items, best first
+	Candidates []matchCandidate[T]
+}
+
+func (m fuzzyMatch[T]) Best() T {
+	return m.Candidates[0].Item
+}
+
+// fuzzyIndex matches names against items known by one or more names. Lookups
+// are cached, files repeat the same few names on many rows.
+type fuzzyIndex[T any] struct {
+	items []T
+	names [][]string
+	cache map[string]fuzzyMatch[T]
+}
+
+func newFuzzyIndex[T any](items []T, names func(T) []string) *fuzzyIndex[T] {
+	index := &fuzzyIndex[T]{
+		items: items,
+		names: make([][]string, len(items)),
+		cache: make(map[string]fuzzyMatch[T]),
+	}
+	for idx, item := range items {
+		for _, name := range names(item) {
+			if normalized := normalizeName(name); normalized != "" {
+				index.names[idx] = append(index.names[idx], normalized)
+			}
+		}
+	}
+	return index
+}
+
+func (ix *fuzzyIndex[T]) match(name string) fuzzyMatch[T] {
+	query := normalizeName(name)
+	if cached, ok := ix.cache[query]; ok {
+		return cached
+	}
+
+	var candidates []matchCandidate[T]
+	for _, candidate := range ix.ranked(name, len(ix.items)) {
+		if candidate.Score >= suggestionFloor {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	result := fuzzyMatch[T]{Candidates: candidates[:min(len(candidates), suggestionCount)]}
+	if len(candidates) > 0 && candidates[0].Score >= matchThreshold {
+		result.Found = len(candidates) == 1 || candidates[0].Score-candidates[1].Score >= matchMargin
+	}
+	ix.cache[query] = result
+	return result
+}
+
+// ranked returns the limit items closest to name, best first
+func (ix *fuzzyIndex[T]) ranked(name string, limit int) []matchCandidate[T] {
+	query := normalizeName(name)
+	candidates := make([]matchCandidate[T], len(ix.items))
+	for idx, item := range ix.items {
+		best := 0.0
+		for _, itemName := range ix.names[idx] {
+			best = max(best, similarity(query, itemName))
+		}
+		candidates[idx] = matchCandidate[T]{Item: item, Score: best}
+	}
+	slices.SortStableFunc(candidates, func(a, b matchCandidate[T]) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return 0
+	})
+	return candidates[:min(len(candidates), limit)]
+}
+
+// collegeNames are the names a college is matched by, every part of a
+// comma separated alias counts on its own
+func collegeNames(college pb.CollegeCollection) []string {
+	names := []string{college.Name}
+	if college.Alias != "" {
+		names = append(names, college.Alias)
+		names = append(names, strings.Split(college.Alias, ",")...)
+	}
+	return names
+}
+
+func branchNames(branch pb.BranchCollection) []string {
+	return []string{branch.Name}
+}
+
+// describeCollegeCandidates lists suggested colleges with the id to pass as extra_id
+func describeCollegeCandidates(candidates []matchCandidate[pb.CollegeCollection]) string {
+	suggestions := make([]string, len(candidates))
+	for idx, candidate := range candidates {
+		suggestions[idx] = fmt.Sprintf("%s (`c-%s`)", candidate.Item.Name, candidate.Item.ID)
+	}
+	return strings.Join(suggestions, ", ")
+}
+
+// describeBranchCandidates lists suggested branches with the id to pass as extra_id
+func describeBranchCandidates(candidates []matchCandidate[pb.BranchCollection]) string {
+	suggestions := make([]string, len(candidates))
+	for idx, candidate := range candidates {
+		suggestions[idx] = fmt.Sprintf("%s %s (`b-%s`)", candidate.Item.Name, describeBranch(candidate.Item), candidate.Item.ID)
+	}
+	return strings.Join(suggestions, ", ")
+}
@@ -0,0 +1,144 @@
+package insert
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/arinji2/dasa-bot/pb"
+)
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "NIT Calicut", want: "national institute of technology calicut"},
+		{name: "IIIT Allahabad", want: "indian institute of information technology allahabad"},
+		{name: "IIT-Bombay", want: "indian institute of technology bombay"},
+		{name: "The IIEST, Shibpur", want: "indian institute of engineering science and technology shibpur"},
+		{name: "Computer Sci. & Engg.", want: "computer science and engineering"},
+		// only whole words are expanded
+		{name: "IIITDM Kancheepuram", want: "iiitdm kancheepuram"},
+		{name: "  ", want: ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeName(tt.name); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "calicut", b: "", want: 7},
+		{a: "calicut", b: "calicut", want: 0},
+		{a: "calicut", b: "calcut", want: 1},
+		{a: "kitten", b: "sitting", want: 3},
+		{a: "nagpur", b: "raipur", want: 2},
+	}
+	for _, tt := range tests {
+		if got := levenshtein([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("%q %q: got %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := levenshtein([]rune(tt.b), []rune(tt.a)); got != tt.want {
+			t.Errorf("%q %q: got %d the other way round, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want float64
+	}{
+		{name: "abbreviation", a: "NIT Calicut", b: "National Institute of Technology Calicut", want: 1},
+		{name: "word order", a: "Calicut NIT", b: "National Institute of Technology Calicut", want: 1},
+		// one edit in the seven letters of the only distinct word
+		{name: "typo", a: "NIT Calcut", b: "NIT Calicut", want: 1 - 1.0/7},
+		// sharing "national institute of technology" does not make two NITs alike
+		{name: "different nit", a: "NIT Surat", b: "NIT Calicut", want: 1 - 6.0/7},
+		// the expanded names differ by "information ", 12 of 49 letters
+		{name: "iiit is not iit", a: "IIIT Bombay", b: "IIT Bombay", want: 1 - 12.0/49},
+		{name: "empty", a: "", b: "", want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := similarity(normalizeName(tt.a), normalizeName(tt.b))
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	colleges := []pb.CollegeCollection{
+		{ID: "nitc", Name: "National Institute of Technology Calicut", Alias: "NITC, REC Calicut"},
+		{ID: "nitt", Name: "National Institute of Technology Tiruchirappalli"},
+		// the same institute stored under another spelling
+		{ID: "nitt2", Name: "National Institute of Technology Tiruchirapalli"},
+		{ID: "iiita", Name: "Indian Institute of Information Technology Allahabad"},
+		{ID: "iitb", Name: "Indian Institute of Technology Bombay"},
+	}
+	tests := []struct {
+		name       string
+		query      string
+		wantFound  bool
+		wantBest   string
+		candidates []string
+	}{
+		{name: "nit expansion", query: "NIT Calicut", wantFound: true, wantBest: "nitc", candidates: []string{"nitc"}},
+		{name: "iiit expansion", query: "IIIT Allahabad", wantFound: true, wantBest: "iiita", candidates: []string{"iiita"}},
+		{name: "alias part", query: "REC Calicut", wantFound: true, wantBest: "nitc", candidates: []string{"nitc"}},
+		// 6/7 is just above the threshold
+		{name: "typo above threshold", query: "National Institute of Technology Calcut", wantFound: true, wantBest: "nitc", candidates: []string{"nitc"}},
+		// one edit from one spelling and two from the other
+		{name: "typo far enough ahead", query: "NIT Tiruchirapali", wantFound: true, wantBest: "nitt2", candidates: []string{"nitt2", "nitt"}},
+		// 5/7 is a suggestion, not a match
+		{name: "near miss below threshold", query: "NIT Calic", wantBest: "nitc", candidates: []string{"nitc"}},
+		{name: "near miss across expansions", query: "IIIT Bombay", wantBest: "iitb", candidates: []string{"iitb"}},
+		// one edit from both spellings, so neither is ahead by the margin
+		{name: "ambiguous", query: "NIT Tiruchiraapalli", wantBest: "nitt", candidates: []string{"nitt", "nitt2"}},
+		{name: "no match", query: "Birla Institute of Technology", candidates: nil},
+		{name: "other nit", query: "NIT Surat", candidates: nil},
+	}
+	index := newFuzzyIndex(colleges, collegeNames)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match := index.match(tt.query)
+			if match.Found != tt.wantFound {
+				t.Fatalf("got found %v, want %v with candidates %+v", match.Found, tt.wantFound, match.Candidates)
+			}
+			var ids []string
+			for _, candidate := range match.Candidates {
+				ids = append(ids, candidate.Item.ID)
+			}
+			if !slices.Equal(ids, tt.candidates) {
+				t.Fatalf("got candidates %v, want %v", ids, tt.candidates)
+			}
+			if tt.wantBest != "" && match.Best().ID != tt.wantBest {
+				t.Fatalf("got best %s, want %s", match.Best().ID, tt.wantBest)
+			}
+		})
+	}
+}
+
+func TestFuzzyMatchSuggestionCount(t *testing.T) {
+	var branches []pb.BranchCollection
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		branches = append(branches, pb.BranchCollection{ID: id, Name: "Computer Science and Engineering"})
+	}
+	match := newFuzzyIndex(branches, branchNames).match("Computer Sci & Engg")
+	if match.Found {
+		t.Fatal("got a match between identical names, want it to be ambiguous")
+	}
+	if len(match.Candidates) != suggestionCount {
+		t.Fatalf("got %d candidates, want %d", len(match.Candidates), suggestionCount)
+	}
+}
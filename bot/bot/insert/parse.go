@@ -0,0 +1,544 @@
+package insert
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/arinji2/dasa-bot/convert"
+	"github.com/arinji2/dasa-bot/pb"
+)
+
+// columnAliases lists the header names accepted for every column, the first
+// being the name used in messages
+var columnAliases = map[string][]string{
+	"college_name": {"college_name", "college", "institute", "institute_name"},
+	"branch_code":  {"branch_code", "code", "program_code"},
+	"branch_name":  {"branch_name", "branch", "program", "program_name", "academic_program_name"},
+	"is_ciwg":      {"is_ciwg", "ciwg", "quota", "category"},
+	"first_rank":   {"first_rank", "opening_rank", "jee_open", "opening"},
+	"last_rank":    {"last_rank", "closing_rank", "jee_close", "closing"},
+	"extra_id":     {"extra_id", "extra_ids", "ids"},
+	"year":         {"year"},
+	"round":        {"round"},
+}
+
+var requiredColumns = []string{"college_name", "branch_code", "branch_name", "first_rank", "last_rank"}
+
+// columnMap holds the index of every column the header named
+type columnMap map[string]int
+
+// get returns the trimmed value of column, empty when the column or cell is missing
+func (m columnMap) get(record []string, column string) string {
+	idx, ok := m[column]
+	if !ok || idx >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[idx])
+}
+
+// mapHeader matches every header to a column. Unknown, duplicate and missing
+// required headers are all reported at once.
+func mapHeader(header []string) (columnMap, error) {
+	aliasColumn := make(map[string]string)
+	for column, aliases := range columnAliases {
+		for _, alias := range aliases {
+			aliasColumn[alias] = column
+		}
+	}
+
+	columns := make(columnMap)
+	var problems []string
+	for idx, name := range header {
+		normalized := normalizeHeader(name)
+		if normalized == "" {
+			continue
+		}
+		column, ok := aliasColumn[normalized]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("Unknown column **%s**", name))
+			continue
+		}
+		if _, ok := columns[column]; ok {
+			problems = append(problems, fmt.Sprintf("Column **%s** is given more than once", column))
+			continue
+		}
+		columns[column] = idx
+	}
+	for _, column := range requiredColumns {
+		if _, ok := columns[column]; !ok {
+			problems = append(problems, fmt.Sprintf("Missing column **%s**, also accepted as %s", column, strings.Join(columnAliases[column][1:], ", ")))
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid header:\n%s", strings.Join(problems, "\n"))
+	}
+	return columns, nil
+}
+
+// normalizeHeader lowercases name and joins its words with underscores
+func normalizeHeader(name string) string {
+	name = strings.TrimPrefix(name, "\ufeff")
+	name = strings.NewReplacer("-", " ", "_", " ", "/", " ").Replace(strings.ToLower(name))
+	return strings.Join(strings.Fields(name), "_")
+}
+
+// parseCiwg accepts booleans as well as the quota names used in the DASA tables
+func parseCiwg(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	if parsed, err := strconv.ParseBool(value); err == nil {
+		return parsed, nil
+	}
+	normalized := strings.ToLower(value)
+	switch {
+	case strings.Contains(normalized, "non") || normalized == "dasa":
+		return false, nil
+	case strings.Contains(normalized, "ciwg"):
+		return true, nil
+	}
+	return false, fmt.Errorf("expected true, false, DASA or CIWG, got %q", value)
+}
+
+// parseRowNumber reads an optional per row year or round, falling back to fallback
+func parseRowNumber(value string, fallback int) (int, error) {
+	if value == "" {
+		if fallback == 0 {
+			return 0, fmt.Errorf("missing value, add the column or pass it as an option")
+		}
+		return fallback, nil
+	}
+	return convert.StringToInt(value)
+}
+
+type RankParseError struct {
+	Line    int
+	Record  []string
+	Message string
+	// Fix suggests how to correct the row
+	Fix string
+}
+
+// Error lets a recordReader explain a row it could not read, Line and Record are filled in by the parser
+func (e RankParseError) Error() string {
+	return e.Message
+}
+
+// parseRankingData resolves every row of reader against the current data and
+// plans the writes. Nothing is written. Colleges and branches are looked up by
+// name, recorded alias and then fuzzily, names still not found are left to
+// resolutions, which either point them at an existing record or plan a new one.
+// Rows with names that have no resolution yet are left out and the names
+// returned in Unresolved. year and round apply to rows without their own, zero
+// means every row has to have one.
+func (c *InsertCommand) parseRankingData(reader recordReader, year, round int, mode string, resolutions map[string]string) (*importPlan, error) {
+	plan := &importPlan{Year: year, Round: round, Mode: mode, Resolutions: resolutions}
+	if plan.Resolutions == nil {
+		plan.Resolutions = make(map[string]string)
+	}
+	var errors []RankParseError
+	lineNumber := 0
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("could not read header from file: %w", err)
+	}
+	columns, err := mapHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	plan.Columns = columns
+	requiredWidth := 0
+	for _, column := range requiredColumns {
+		requiredWidth = max(requiredWidth, columns[column]+1)
+	}
+
+	data := c.Data.Load()
+	collegeIDMap := make(map[string]pb.CollegeCollection, len(data.Colleges))
+	collegeNameMap := make(map[string]pb.CollegeCollection, len(data.Colleges))
+	for _, college := range data.Colleges {
+		collegeIDMap[college.ID] = college
+		normalizedName := strings.ToLower(strings.ReplaceAll(college.Name, ",", ""))
+		collegeNameMap[normalizedName] = college
+	}
+
+	collegeIndex := newFuzzyIndex(data.Colleges, collegeNames)
+
+	branchIDMap := make(map[string]pb.BranchCollection, len(data.Branches))
+	branchKeyMap := make(map[string]pb.BranchCollection, len(data.Branches))
+	// branches are only matched fuzzily by name against those with the same code and category
+	branchesByCode := make(map[string][]pb.BranchCollection)
+	branchesByCategory := make(map[bool][]pb.BranchCollection)
+	for _, branch := range data.Branches {
+		branchIDMap[branch.ID] = branch
+		branchKeyMap[branchKey(branch.Name, branch.Code, branch.Ciwg)] = branch
+		codeKey := branchKey("", branch.Code, branch.Ciwg)
+		branchesByCode[codeKey] = append(branchesByCode[codeKey], branch)
+		branchesByCategory[branch.Ciwg] = append(branchesByCategory[branch.Ciwg], branch)
+	}
+	branchCodeIndexes := make(map[string]*fuzzyIndex[pb.BranchCollection])
+	branchCategoryIndexes := map[bool]*fuzzyIndex[pb.BranchCollection]{
+		false: newFuzzyIndex(branchesByCategory[false], branchNames),
+		true:  newFuzzyIndex(branchesByCategory[true], branchNames),
+	}
+
+	// existingRanks holds the stored ranks by rankKey
+	existingRanks := make(map[string]pb.RankCollection, len(data.Ranks))
+	for _, rank := range data.Ranks {
+		existingRanks[rankKey(rank.Year, rank.Round, rank.College, rank.Branch)] = rank
+	}
+	newColleges := make(map[string]bool)
+	newBranches := make(map[string]bool)
+	seen := make(map[string]bool)
+
+	for {
+		record, err := reader.Read()
+		lineNumber++
+		if err == io.EOF {
+			break
+		}
+		if parseErr, ok := err.(RankParseError); ok {
+			parseErr.Line = lineNumber
+			parseErr.Record = record
+			errors = append(errors, parseErr)
+			continue
+		}
+		if err != nil {
+			errors = append(errors, RankParseError{
+				Line:    lineNumber,
+				Record:  record,
+				Message: fmt.Sprintf("Error reading record: %v", err),
+				Fix:     "Check the row for stray quotes and for every column of the header",
+			})
+			continue
+		}
+
+		if len(record) < requiredWidth {
+			errors = append(errors, RankParseError{
+				Line:    lineNumber,
+				Record:  record,
+				Message: fmt.Sprintf("Malformed row with %d columns", len(record)),
+				Fix:     "Fill in every required column of the row",
+			})
+			continue
+		}
+
+		collegeName := columns.get(record, "college_name")
+		if collegeName == "" {
+			errors = append(errors, RankParseError{
+				Line:    lineNumber,
+				Record:  record,
+				Message: "Empty 'college_name' value",
+				Fix:     "Add the college name",
+			})
+			continue
+		}
+
+		branchCode := columns.get(record, "branch_code")
+		if branchCode == "" {
+			errors = append(errors, RankParseError{
+				Line:    lineNumber,
+				Record:  record,
+				Message: "Empty 'branch_code' value",
+				Fix:     "Add the branch code, e.g. CS",
+			})
+			continue
+		}
+
+		branchName := columns.get(record, "branch_name")
+		if branchName == "" {
+			errors = append(errors, RankParseError{
+				Line:    lineNumber,
+				Record:  record,
+				Message: "Empty 'branch_name' value",
+				Fix:     "Add the branch name",
+			})
+			continue
+		}
+
+		isCiWg, err := parseCiwg(columns.get(record, "is_ciwg"))
+		if err != nil {
+			errors = append(errors, RankParseError{
+				Line:    lineNumber,
+				Record:  record,
+				Message: fmt.Sprintf("Invalid 'is_ciwg' value: %v", err),
+				Fix:     "Use true, false, DASA or CIWG",
+			})
+			continue
+		}
+
+		firstRank, err := convert.StringToInt(columns.get(record, "first_rank"))
+		if err != nil {
+			errors = append(errors, RankParseError{
+				Line:    lineNumber,
+				Record:  record,
+				Message: fmt.Sprintf("Invalid 'first_rank' value: %v", err),
+				Fix:     "Use a whole number for the opening rank",
+			})
+			continue
+		}
+
+		lastRank, err := convert.StringToInt(columns.get(record, "last_rank"))
+		if err != nil {
+			errors = append(errors, RankParseError{
+				Line:    lineNumber,
+				Record:  record,
+				Message: fmt.Sprintf("Invalid 'last_rank' value: %v", err),
+				Fix:     "Use a whole number for the closing rank",
+			})
+			continue
+		}
+
+		rowYear, err := parseRowNumber(columns.get(record, "year"), year)
+		if err != nil {
+			errors = append(errors, RankParseError{
+				Line:    lineNumber,
+				Record:  record,
+				Message: fmt.Sprintf("Invalid 'year' value: %v", err),
+				Fix:     "Use a year such as 2024, or leave it empty and pass the year option",
+			})
+			continue
+		}
+
+		rowRound, err := parseRowNumber(columns.get(record, "round"), round)
+		if err != nil {
+			errors = append(errors, RankParseError{
+				Line:    lineNumber,
+				Record:  record,
+				Message: fmt.Sprintf("Invalid 'round' value: %v", err),
+				Fix:     "Use a round number such as 1, or leave it empty and pass the round option",
+			})
+			continue
+		}
+
+		extraIDS := columns.get(record, "extra_id")
+		var branchID string
+		var collegeID string
+
+		if extraIDS != "" {
+			if strings.Contains(extraIDS, ":") {
+				parts := strings.Split(extraIDS, ":")
+				if len(parts) == 2 {
+					if after, ok := strings.CutPrefix(parts[0], "b-"); ok {
+						branchID = after
+					}
+					if after, ok := strings.CutPrefix(parts[1], "c-"); ok {
+						collegeID = after
+					}
+				}
+			} else if after, ok := strings.CutPrefix(extraIDS, "c-"); ok {
+				collegeID = after
+			} else if after, ok := strings.CutPrefix(extraIDS, "b-"); ok {
+				branchID = after
+			} else {
+				errors = append(errors, RankParseError{
+					Line:    lineNumber,
+					Record:  record,
+					Message: fmt.Sprintf("Invalid 'extra_id' value: %v", extraIDS),
+					Fix:     "Use c-<college id>, b-<branch id> or b-<branch id>:c-<college id>",
+				})
+			}
+		}
+
+		var collegeData pb.CollegeCollection
+		var found bool
+		var notes []string
+		var newCollege string
+		// unresolved rows are still checked so every unknown name is asked about at once
+		unresolved := false
+
+		if collegeID != "" {
+			collegeData, found = collegeIDMap[collegeID]
+			if !found {
+				errors = append(errors, RankParseError{
+					Line:    lineNumber,
+					Record:  record,
+					Message: fmt.Sprintf("Invalid 'college_id' value: %v", collegeID),
+					Fix:     "Use the id of an existing college after c-, or leave extra_id empty",
+				})
+				continue
+			}
+		} else {
+			normalizedName := strings.ToLower(strings.ReplaceAll(collegeName, ",", ""))
+			collegeData, found = collegeNameMap[normalizedName]
+			if !found {
+				collegeData, found = data.CollegeByAlias(collegeName)
+			}
+			if !found {
+				match := collegeIndex.match(collegeName)
+				resolution, resolved := plan.Resolutions[collegeResolutionKey(collegeName)]
+				switch {
+				case match.Found:
+					collegeData = match.Best()
+					notes = append(notes, fmt.Sprintf("matched college %s", collegeData.Name))
+					plan.addMatch(nameMatch{Kind: kindCollege, Input: collegeName, Name: collegeData.Name, ID: "c-" + collegeData.ID})
+				case resolution == createNew:
+					collegeData = pb.CollegeCollection{Name: collegeName}
+					newCollege = collegeResolutionKey(collegeName)
+					if !newColleges[newCollege] {
+						newColleges[newCollege] = true
+						plan.NewColleges = append(plan.NewColleges, plannedCollege{
+							Key:     newCollege,
+							Request: pb.CollegeCreateRequest{Name: collegeName},
+						})
+					}
+				case resolved:
+					collegeData, found = collegeIDMap[resolution]
+					if !found {
+						errors = append(errors, RankParseError{
+							Line:    lineNumber,
+							Record:  record,
+							Message: fmt.Sprintf("College **%v** was resolved to %v, which no longer exists", collegeName, resolution),
+							Fix:     "Run /insert again and pick the college anew",
+						})
+						continue
+					}
+					notes = append(notes, fmt.Sprintf("resolved college %s", collegeData.Name))
+				default:
+					plan.addUnresolved(unresolvedName{Kind: kindCollege, Name: collegeName})
+					unresolved = true
+				}
+			}
+		}
+
+		var branchData pb.BranchCollection
+		var newBranch string
+		if branchID != "" {
+			branchData, found = branchIDMap[branchID]
+			if !found {
+				errors = append(errors, RankParseError{
+					Line:    lineNumber,
+					Record:  record,
+					Message: fmt.Sprintf("Invalid 'branch_id' value: %v for 'college id' %v", branchID, collegeData.ID),
+					Fix:     "Use the id of an existing branch after b-, or leave extra_id empty",
+				})
+				continue
+			}
+		} else {
+			key := branchKey(branchName, branchCode, isCiWg)
+			branchData, found = branchKeyMap[key]
+			if !found {
+				branchData, found = data.BranchByAlias(branchName, branchCode, isCiWg)
+			}
+			if !found {
+				codeKey := branchKey("", branchCode, isCiWg)
+				codeIndex, ok := branchCodeIndexes[codeKey]
+				if !ok {
+					codeIndex = newFuzzyIndex(branchesByCode[codeKey], branchNames)
+					branchCodeIndexes[codeKey] = codeIndex
+				}
+				match := codeIndex.match(branchName)
+				resolution, resolved := plan.Resolutions[branchResolutionKey(branchName, branchCode, isCiWg)]
+				switch {
+				case match.Found:
+					branchData = match.Best()
+					notes = append(notes, fmt.Sprintf("matched branch %s", branchData.Name))
+					plan.addMatch(nameMatch{Kind: kindBranch, Input: branchName, Name: branchData.Name, ID: "b-" + branchData.ID})
+				case resolution == createNew:
+					if similar := branchCategoryIndexes[isCiWg].match(branchName).Candidates; len(similar) > 0 {
+						notes = append(notes, "similar to "+describeBranchCandidates(similar))
+					}
+					branchData = pb.BranchCollection{
+						Name: branchName,
+						Code: branchCode,
+						Ciwg: isCiWg,
+					}
+					newBranch = key
+					if !newBranches[key] {
+						newBranches[key] = true
+						plan.NewBranches = append(plan.NewBranches, plannedBranch{
+							Key:     key,
+							Request: pb.BranchCreateRequest{Name: branchName, Code: branchCode, Ciwg: isCiWg},
+						})
+					}
+				case resolved:
+					branchData, found = branchIDMap[resolution]
+					if !found {
+						errors = append(errors, RankParseError{
+							Line:    lineNumber,
+							Record:  record,
+							Message: fmt.Sprintf("Branch **%v** was resolved to %v, which no longer exists", branchName, resolution),
+							Fix:     "Run /insert again and pick the branch anew",
+						})
+						continue
+					}
+					notes = append(notes, fmt.Sprintf("resolved branch %s", branchData.Name))
+				default:
+					plan.addUnresolved(unresolvedName{Kind: kindBranch, Name: branchName, Code: branchCode, Ciwg: isCiWg})
+					unresolved = true
+				}
+			}
+		}
+		if unresolved {
+			continue
+		}
+
+		rankCollection := pb.RankCollection{
+			JeeOpen:  firstRank,
+			JeeClose: lastRank,
+			Year:     rowYear,
+			Round:    rowRound,
+			College:  collegeData.ID,
+			Branch:   branchData.ID,
+			Expand: struct {
+				College pb.CollegeCollection "json:\"college\""
+				Branch  pb.BranchCollection  "json:\"branch\""
+			}{
+				College: collegeData,
+				Branch:  branchData,
+			},
+		}
+
+		planned := plannedRank{
+			Line:       lineNumber,
+			Record:     record,
+			Rank:       rankCollection,
+			NewCollege: newCollege,
+			NewBranch:  newBranch,
+			Note:       strings.Join(notes, "; "),
+		}
+
+		plan.addRound(rowYear, rowRound, isCiWg)
+		// planned records have no id yet, so their key stands in for it
+		rankCollege, rankBranch := collegeData.ID, branchData.ID
+		if newCollege != "" {
+			rankCollege = "new:" + newCollege
+		}
+		if newBranch != "" {
+			rankBranch = "new:" + newBranch
+		}
+		key := rankKey(rowYear, rowRound, rankCollege, rankBranch)
+		if seen[key] {
+			plan.Duplicates = append(plan.Duplicates, planned)
+			continue
+		}
+		seen[key] = true
+		// replaced ranks are gone by the time the file is inserted, so nothing can duplicate them
+		if existing, ok := existingRanks[key]; ok && mode != modeReplace {
+			planned.Existing = &existing
+			if mode == modeUpsert && (existing.JeeOpen != firstRank || existing.JeeClose != lastRank) {
+				planned.Rank.ID = existing.ID
+				plan.Updates = append(plan.Updates, planned)
+			} else {
+				plan.Duplicates = append(plan.Duplicates, planned)
+			}
+			continue
+		}
+		plan.Ranks = append(plan.Ranks, planned)
+	}
+
+	if mode == modeReplace {
+		for _, rank := range data.Ranks {
+			// a file of only non CIWG rows leaves the round's CIWG ranks alone, and the other way around
+			if plan.hasCategory(rank.Year, rank.Round, rank.Expand.Branch.Ciwg) {
+				plan.Replaced = append(plan.Replaced, rank)
+			}
+		}
+	}
+
+	plan.Errors = errors
+	return plan, nil
+}
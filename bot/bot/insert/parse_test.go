@@ -0,0 +1,94 @@
+package insert
+
+import (
+	"encoding/csv"
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestMapHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  []string
+		want    columnMap
+		wantErr string
+	}{
+		{
+			name:   "canonical names",
+			header: []string{"college_name", "branch_code", "branch_name", "is_ciwg", "first_rank", "last_rank"},
+			want:   columnMap{"college_name": 0, "branch_code": 1, "branch_name": 2, "is_ciwg": 3, "first_rank": 4, "last_rank": 5},
+		},
+		{
+			name:   "renamed",
+			header: []string{"Institute", "Program Code", "Academic Program Name", "Quota", "Opening Rank", "Closing Rank"},
+			want:   columnMap{"college_name": 0, "branch_code": 1, "branch_name": 2, "is_ciwg": 3, "first_rank": 4, "last_rank": 5},
+		},
+		{
+			name:   "reordered with optional columns",
+			header: []string{"closing-rank", "YEAR", " jee_open ", "round", "code", "college", "branch", "extra ids"},
+			want:   columnMap{"last_rank": 0, "year": 1, "first_rank": 2, "round": 3, "branch_code": 4, "college_name": 5, "branch_name": 6, "extra_id": 7},
+		},
+		{
+			name:   "byte order mark and blank columns",
+			header: []string{"\ufeffcollege_name", "", "branch_code", "branch_name", "first_rank", "last_rank", " "},
+			want:   columnMap{"college_name": 0, "branch_code": 2, "branch_name": 3, "first_rank": 4, "last_rank": 5},
+		},
+		{
+			name:    "missing required column",
+			header:  []string{"college_name", "branch_code", "branch_name", "first_rank"},
+			wantErr: "invalid header:\nMissing column **last_rank**, also accepted as closing_rank, jee_close, closing",
+		},
+		{
+			name:    "unmappable required column",
+			header:  []string{"college_name", "branch_code", "Course", "first_rank", "last_rank"},
+			wantErr: "invalid header:\nUnknown column **Course**\nMissing column **branch_name**, also accepted as branch, program, program_name, academic_program_name",
+		},
+		{
+			name:    "duplicate under another name",
+			header:  []string{"college_name", "institute", "branch_code", "branch_name", "opening_rank", "jee_open", "last_rank"},
+			wantErr: "invalid header:\nColumn **college_name** is given more than once\nColumn **first_rank** is given more than once",
+		},
+		{
+			name:    "missing optional column is fine but unknown is not",
+			header:  []string{"college_name", "branch_code", "branch_name", "first_rank", "last_rank", "notes"},
+			wantErr: "invalid header:\nUnknown column **notes**",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, err := mapHeader(tt.header)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("got %v, want error %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(columns, tt.want) {
+				t.Fatalf("got %v, want %v", columns, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseReorderedHeader(t *testing.T) {
+	store := newTestStore()
+	c := &InsertCommand{Data: loadData(t, store), PbAdmin: store}
+	file := "Closing Rank,Opening Rank,Quota,Academic Program Name,Program Code,Institute\n" +
+		"400,300,DASA,Electronics and Communication Engineering,EC,National Institute of Technology Calicut\n"
+
+	plan, err := c.parseRecords(readAll(csv.NewReader(strings.NewReader(file))), 2024, 2, modeInsert, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Ranks) != 1 || len(plan.Errors) != 0 {
+		t.Fatalf("got %d ranks and %d errors, want 1 and 0", len(plan.Ranks), len(plan.Errors))
+	}
+	rank := plan.Ranks[0].Rank
+	if rank.College != "nitc" || rank.Branch != "ece" || rank.JeeOpen != 300 || rank.JeeClose != 400 {
+		t.Fatalf("got %+v, want nitc ece from 300 to 400", rank)
+	}
+}
@@ -0,0 +1,285 @@
+package insert
+
+import (
+	"crypto/rand"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arinji2/dasa-bot/pb"
+)
+
+// planTTL is how long a dry run preview can be confirmed for
+const planTTL = 15 * time.Minute
+
+// Modes decide what happens to rows for ranks that already exist
+const (
+	// modeInsert skips them
+	modeInsert = "insert"
+	// modeUpsert updates them when the opening or closing rank differs
+	modeUpsert = "upsert"
+	// modeReplace deletes the stored ranks of the round before inserting, only
+	// those of the categories the file has rows for, CIWG or not
+	modeReplace = "replace"
+)
+
+// importPlan is everything an insert would write, worked out from the file
+// without touching Pocketbase. Executing a plan writes exactly this.
+type importPlan struct {
+	// Year and Round are the defaults for rows without their own, zero if not given
+	Year     int
+	Round    int
+	Mode     string
+	DryRun   bool
+	FileName string
+	// FileHash is the hex encoded SHA-256 of the file, recorded with the import
+	FileHash string
+	UserName string
+	UserID   string
+	// Columns maps the file's header
+	Columns columnMap
+	// Rounds are the distinct years and rounds in the file, in the order they appear
+	Rounds []planRound
+	// Categories are the distinct rounds and CIWG categories in the file, replace mode only deletes ranks of these
+	Categories []planCategory
+
+	// Ranks are the rows that will be created
+	Ranks []plannedRank
+	// Duplicates already exist, either in Pocketbase or earlier in the file
+	Duplicates []plannedRank
+	// Updates correct the opening and closing ranks of stored ranks, in upsert mode
+	Updates []plannedRank
+	// Replaced are the stored ranks of the file's categories deleted first, in replace mode
+	Replaced []pb.RankCollection
+	// NewColleges and NewBranches are created before any rank that refers to them
+	NewColleges []plannedCollege
+	NewBranches []plannedBranch
+	// Matches are the names that were only found by fuzzy matching, once each
+	Matches []nameMatch
+	Errors  []RankParseError
+
+	// Unresolved are the names that matched nothing, their rows are left out
+	// until every one of them is resolved
+	Unresolved []unresolvedName
+	// Resolutions are the decisions made for unresolved names so far, by
+	// unresolvedName.key, either an id or createNew
+	Resolutions map[string]string
+	// PendingAliases are the names a dry run resolved, their aliases are only
+	// saved once the plan is confirmed
+	PendingAliases []unresolvedName
+	// records are the rows of the file, kept to parse it again once resolved
+	records *sliceReader
+
+	createdAt time.Time
+}
+
+type planRound struct {
+	Year  int
+	Round int
+}
+
+// planCategory is the CIWG or non CIWG half of a round
+type planCategory struct {
+	planRound
+	Ciwg bool
+}
+
+func (p *importPlan) addRound(year, round int, ciwg bool) {
+	if !p.hasRound(year, round) {
+		p.Rounds = append(p.Rounds, planRound{Year: year, Round: round})
+	}
+	if !p.hasCategory(year, round, ciwg) {
+		p.Categories = append(p.Categories, planCategory{planRound{Year: year, Round: round}, ciwg})
+	}
+}
+
+func (p *importPlan) hasRound(year, round int) bool {
+	return slices.Contains(p.Rounds, planRound{Year: year, Round: round})
+}
+
+func (p *importPlan) hasCategory(year, round int, ciwg bool) bool {
+	return slices.Contains(p.Categories, planCategory{planRound{Year: year, Round: round}, ciwg})
+}
+
+// describeCategories names the categories in the file for messages
+func (p *importPlan) describeCategories() string {
+	categories := make([]string, len(p.Categories))
+	for idx, category := range p.Categories {
+		name := "Non CIWG"
+		if category.Ciwg {
+			name = "CIWG"
+		}
+		categories[idx] = fmt.Sprintf("%d Round %d %s", category.Year, category.Round, name)
+	}
+	return strings.Join(categories, ", ")
+}
+
+// describeRounds names the rounds in the file for messages
+func (p *importPlan) describeRounds() string {
+	if len(p.Rounds) == 0 {
+		return fmt.Sprintf("Year: %d and Round %d", p.Year, p.Round)
+	}
+	if len(p.Rounds) == 1 {
+		return fmt.Sprintf("Year: %d and Round %d", p.Rounds[0].Year, p.Rounds[0].Round)
+	}
+	rounds := make([]string, len(p.Rounds))
+	for idx, round := range p.Rounds {
+		rounds[idx] = fmt.Sprintf("%d Round %d", round.Year, round.Round)
+	}
+	return "Years and Rounds: " + strings.Join(rounds, ", ")
+}
+
+type plannedRank struct {
+	Line   int
+	Record []string
+	Rank   pb.RankCollection
+	// NewCollege and NewBranch are the keys of the planned college and branch
+	// this rank belongs to, the rank has no id for them until they are created
+	NewCollege string
+	NewBranch  string
+	// Existing is the stored rank a duplicate matched, if it came from Pocketbase
+	Existing *pb.RankCollection
+	// Note explains how names were matched, for the report
+	Note string
+}
+
+// nameMatch is a college or branch name in the file that only matched fuzzily
+type nameMatch struct {
+	Kind  string
+	Input string
+	Name  string
+	ID    string
+}
+
+func (p *importPlan) addMatch(match nameMatch) {
+	if !slices.Contains(p.Matches, match) {
+		p.Matches = append(p.Matches, match)
+	}
+}
+
+type plannedCollege struct {
+	Key     string
+	Request pb.CollegeCreateRequest
+}
+
+type plannedBranch struct {
+	Key     string
+	Request pb.BranchCreateRequest
+}
+
+const (
+	kindCollege = "college"
+	kindBranch  = "branch"
+	// createNew is the resolution to create a name rather than match it to an existing one
+	createNew = "new"
+)
+
+// unresolvedName is a college or branch of the file that matched nothing
+type unresolvedName struct {
+	Kind string
+	Name string
+	// Code and Ciwg are only set for branches
+	Code string
+	Ciwg bool
+	// Rows counts the rows using the name
+	Rows int
+}
+
+func (n unresolvedName) key() string {
+	if n.Kind == kindCollege {
+		return collegeResolutionKey(n.Name)
+	}
+	return branchResolutionKey(n.Name, n.Code, n.Ciwg)
+}
+
+func collegeResolutionKey(name string) string {
+	return kindCollege + "|" + strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+func branchResolutionKey(name, code string, ciwg bool) string {
+	return kindBranch + "|" + branchKey(name, code, ciwg)
+}
+
+// addUnresolved records a row using name
+func (p *importPlan) addUnresolved(name unresolvedName) {
+	for idx := range p.Unresolved {
+		if p.Unresolved[idx].key() == name.key() {
+			p.Unresolved[idx].Rows++
+			return
+		}
+	}
+	name.Rows = 1
+	p.Unresolved = append(p.Unresolved, name)
+}
+
+// nextUnresolved returns the first name without a resolution
+func (p *importPlan) nextUnresolved() (unresolvedName, int, bool) {
+	for idx, name := range p.Unresolved {
+		if _, ok := p.Resolutions[name.key()]; !ok {
+			return name, idx, true
+		}
+	}
+	return unresolvedName{}, 0, false
+}
+
+// planStore holds plans waiting on a moderator, dry runs to be confirmed and
+// imports with names to resolve, until they are acted on or expire
+type planStore struct {
+	mu    sync.Mutex
+	plans map[string]*importPlan
+}
+
+var pendingPlans = &planStore{plans: make(map[string]*importPlan)}
+
+// put stores plan and returns the id its buttons refer to
+func (ps *planStore) put(plan *importPlan) string {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	for id, pending := range ps.plans {
+		if time.Since(pending.createdAt) > planTTL {
+			delete(ps.plans, id)
+		}
+	}
+
+	id := rand.Text()
+	plan.createdAt = time.Now()
+	ps.plans[id] = plan
+	return id
+}
+
+// restore puts back a plan taken to be worked on in steps under the same id
+func (ps *planStore) restore(id string, plan *importPlan) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	plan.createdAt = time.Now()
+	ps.plans[id] = plan
+}
+
+// take removes and returns a plan, so it can only be acted on once
+func (ps *planStore) take(id string) (*importPlan, bool) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	plan, ok := ps.plans[id]
+	if !ok {
+		return nil, false
+	}
+	delete(ps.plans, id)
+	if time.Since(plan.createdAt) > planTTL {
+		return nil, false
+	}
+	return plan, true
+}
+
+// rankKey identifies a rank by round, college and branch
+func rankKey(year, round int, college, branch string) string {
+	return fmt.Sprintf("%d|%d|%s|%s", year, round, college, branch)
+}
+
+// branchKey identifies a branch by name, code and category, ignoring case
+func branchKey(name, code string, ciwg bool) string {
+	return fmt.Sprintf("%s-%s-%t", strings.ToLower(name), strings.ToLower(code), ciwg)
+}
@@ -0,0 +1,237 @@
+package insert
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/arinji2/dasa-bot/network"
+	"github.com/arinji2/dasa-bot/pb"
+	"github.com/bwmarrin/discordgo"
+)
+
+var reportHeader = []string{
+	"line", "action", "year", "round", "college", "branch_code", "branch_name", "is_ciwg",
+	"jee_open", "jee_close", "existing_open", "existing_close", "message",
+}
+
+// report lists every row of the plan with what would happen to it, in file order
+func (p *importPlan) report() ([]byte, error) {
+	type reportRow struct {
+		line   int
+		fields []string
+	}
+	var rows []reportRow
+
+	addRank := func(action string, planned plannedRank, message string) {
+		rank := planned.Rank
+		fields := []string{
+			strconv.Itoa(planned.Line + 1), action, strconv.Itoa(rank.Year), strconv.Itoa(rank.Round), rank.Expand.College.Name,
+			rank.Expand.Branch.Code, rank.Expand.Branch.Name, strconv.FormatBool(rank.Expand.Branch.Ciwg),
+			strconv.Itoa(rank.JeeOpen), strconv.Itoa(rank.JeeClose), "", "", message,
+		}
+		if planned.Existing != nil {
+			fields[10] = strconv.Itoa(planned.Existing.JeeOpen)
+			fields[11] = strconv.Itoa(planned.Existing.JeeClose)
+		}
+		rows = append(rows, reportRow{line: planned.Line, fields: fields})
+	}
+
+	for _, planned := range p.Ranks {
+		message := planned.Note
+		if planned.NewBranch != "" {
+			message = joinMessages("new branch", message)
+		}
+		if planned.NewCollege != "" {
+			message = joinMessages("new college", message)
+		}
+		addRank("create", planned, message)
+	}
+	for _, planned := range p.Duplicates {
+		message := "already in this file"
+		if planned.Existing != nil {
+			message = "already exists"
+		}
+		addRank("skip", planned, joinMessages(message, planned.Note))
+	}
+	for _, planned := range p.Updates {
+		addRank("update", planned, joinMessages(describeChange(*planned.Existing, planned.Rank), planned.Note))
+	}
+	for _, parseErr := range p.Errors {
+		// error rows keep the raw values, they may not have parsed
+		fields := []string{strconv.Itoa(parseErr.Line + 1), "error"}
+		for _, column := range []string{"year", "round", "college_name", "branch_code", "branch_name", "is_ciwg", "first_rank", "last_rank"} {
+			fields = append(fields, p.Columns.get(parseErr.Record, column))
+		}
+		fields = append(fields, "", "", parseErr.Message)
+		rows = append(rows, reportRow{line: parseErr.Line, fields: fields})
+	}
+	slices.SortStableFunc(rows, func(a, b reportRow) int {
+		return a.line - b.line
+	})
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write(reportHeader)
+	for _, college := range p.NewColleges {
+		writer.Write([]string{"", "new_college", "", "", college.Request.Name, "", "", "", "", "", "", "", ""})
+	}
+	for _, branch := range p.NewBranches {
+		writer.Write([]string{
+			"", "new_branch", "", "", "", branch.Request.Code, branch.Request.Name,
+			strconv.FormatBool(branch.Request.Ciwg), "", "", "", "", "",
+		})
+	}
+	for _, rank := range p.Replaced {
+		writer.Write([]string{
+			"", "delete", strconv.Itoa(rank.Year), strconv.Itoa(rank.Round), rank.Expand.College.Name, rank.Expand.Branch.Code, rank.Expand.Branch.Name,
+			strconv.FormatBool(rank.Expand.Branch.Ciwg), "", "",
+			strconv.Itoa(rank.JeeOpen), strconv.Itoa(rank.JeeClose), "replaced",
+		})
+	}
+	for _, row := range rows {
+		writer.Write(row.fields)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// describeChange lists the ranks that differ as old → new
+func describeChange(existing, updated pb.RankCollection) string {
+	var changes []string
+	if existing.JeeOpen != updated.JeeOpen {
+		changes = append(changes, fmt.Sprintf("jee_open %d → %d", existing.JeeOpen, updated.JeeOpen))
+	}
+	if existing.JeeClose != updated.JeeClose {
+		changes = append(changes, fmt.Sprintf("jee_close %d → %d", existing.JeeClose, updated.JeeClose))
+	}
+	return strings.Join(changes, ", ")
+}
+
+// joinMessages joins the non empty messages of a report row
+func joinMessages(messages ...string) string {
+	var parts []string
+	for _, message := range messages {
+		if message != "" {
+			parts = append(parts, message)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+var errorReportHeader = []string{"line", "record", "message", "fix"}
+
+// importError is one row of the error report
+type importError struct {
+	// Line is the line of the file, zero when the error is not about a row
+	Line    int      `json:"line,omitempty"`
+	Record  []string `json:"record,omitempty"`
+	Message string   `json:"message"`
+	Fix     string   `json:"fix"`
+}
+
+func parseErrorReport(parseErrs []RankParseError) []importError {
+	report := make([]importError, len(parseErrs))
+	for idx, parseErr := range parseErrs {
+		report[idx] = importError{
+			// add 1 since we remove the header
+			Line:    parseErr.Line + 1,
+			Record:  parseErr.Record,
+			Message: parseErr.Message,
+			Fix:     parseErr.Fix,
+		}
+	}
+	return report
+}
+
+// failureErrorReport lists the rejected write of a failed insert and, when
+// undoing it failed as well, that error
+func failureErrorReport(failure *insertFailure) []importError {
+	rejected := importError{
+		Record:  failure.Record,
+		Message: strings.ReplaceAll(describeError(failure.Err), "\n", " "),
+		Fix:     suggestFix(failure.Err),
+	}
+	if failure.Line > 0 {
+		rejected.Line = failure.Line + 1
+	}
+	report := []importError{rejected}
+	if failure.RollbackErr != nil {
+		report = append(report, importError{
+			Message: strings.ReplaceAll(describeError(failure.RollbackErr), "\n", " "),
+			Fix:     fmt.Sprintf("Restore the backup %s taken before the insert", failure.BackupKey),
+		})
+	}
+	return report
+}
+
+// suggestFix guesses how to get past an error returned while writing
+func suggestFix(err error) string {
+	if errors.Is(err, errCancelled) {
+		return "Run /insert again to insert the file"
+	}
+	var apiErr *network.APIError
+	if !errors.As(err, &apiErr) {
+		return "Check that Pocketbase is reachable, then run /insert again"
+	}
+	for _, field := range apiErr.Fields() {
+		switch apiErr.Data[field].Code {
+		case "validation_not_unique":
+			return "The record already exists, run /insert in upsert mode to update it or in replace mode to start the round over"
+		case "validation_missing_rel_records":
+			return "The college or branch was deleted while inserting, run /insert again"
+		}
+	}
+	if len(apiErr.Data) > 0 {
+		return "Correct the fields named in the message"
+	}
+	return "Check that batch requests are enabled in the Pocketbase settings, then run /insert again"
+}
+
+// errorReportFiles writes report as a CSV and a JSON file to attach to a reply
+func errorReportFiles(report []importError) ([]*discordgo.File, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write(errorReportHeader)
+	for _, row := range report {
+		line := ""
+		if row.Line > 0 {
+			line = strconv.Itoa(row.Line)
+		}
+		writer.Write([]string{line, encodeRecord(row.Record), row.Message, row.Fix})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+
+	jsonReport, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return []*discordgo.File{
+		{Name: "insert-errors.csv", ContentType: "text/csv", Reader: bytes.NewReader(buf.Bytes())},
+		{Name: "insert-errors.json", ContentType: "application/json", Reader: bytes.NewReader(jsonReport)},
+	}, nil
+}
+
+// encodeRecord writes record back as the CSV line it was read from
+func encodeRecord(record []string) string {
+	if len(record) == 0 {
+		return ""
+	}
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write(record)
+	writer.Flush()
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,231 @@
+package insert
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/arinji2/dasa-bot/pb"
+	responses "github.com/arinji2/dasa-bot/responses"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	resolveSelectPrefix = "insert_resolve_"
+	// resolveOptionLimit leaves room for the create option within Discord's 25
+	resolveOptionLimit = 24
+	// selectTextLimit is the longest label or description Discord accepts for an option
+	selectTextLimit = 100
+)
+
+// resolutionPrompt asks which college or branch the next unresolved name of
+// plan is. The menu's id carries the name's position, so a choice made on an
+// outdated message cannot be applied to a different name.
+func (c *InsertCommand) resolutionPrompt(planID string, plan *importPlan) reply {
+	name, position, _ := plan.nextUnresolved()
+	data := c.Data.Load()
+
+	var description string
+	var options []discordgo.SelectMenuOption
+	if name.Kind == kindCollege {
+		description = fmt.Sprintf("College **%s** is used on %d rows and matches no college.", name.Name, name.Rows)
+		index := newFuzzyIndex(data.Colleges, collegeNames)
+		if match := index.match(name.Name); len(match.Candidates) > 0 {
+			description += "\nClosest matches: " + describeCollegeCandidates(match.Candidates)
+		}
+		options = append(options, discordgo.SelectMenuOption{
+			Label:       truncateText("Create new college "+name.Name, selectTextLimit),
+			Value:       createNew,
+			Description: "Adds a college with this name",
+		})
+		for _, candidate := range index.ranked(name.Name, resolveOptionLimit) {
+			options = append(options, discordgo.SelectMenuOption{
+				Label:       truncateText(candidate.Item.Name, selectTextLimit),
+				Value:       candidate.Item.ID,
+				Description: "c-" + candidate.Item.ID,
+			})
+		}
+	} else {
+		branch := pb.BranchCollection{Name: name.Name, Code: name.Code, Ciwg: name.Ciwg}
+		description = fmt.Sprintf("Branch **%s** %s is used on %d rows and matches no branch.", name.Name, describeBranch(branch), name.Rows)
+		var sameCategory []pb.BranchCollection
+		for _, existing := range data.Branches {
+			if existing.Ciwg == name.Ciwg {
+				sameCategory = append(sameCategory, existing)
+			}
+		}
+		index := newFuzzyIndex(sameCategory, branchNames)
+		if match := index.match(name.Name); len(match.Candidates) > 0 {
+			description += "\nClosest matches: " + describeBranchCandidates(match.Candidates)
+		}
+		options = append(options, discordgo.SelectMenuOption{
+			Label:       truncateText(fmt.Sprintf("Create new branch %s %s", name.Name, describeBranch(branch)), selectTextLimit),
+			Value:       createNew,
+			Description: "Adds a branch with this name and code",
+		})
+		for _, candidate := range index.ranked(name.Name, resolveOptionLimit) {
+			options = append(options, discordgo.SelectMenuOption{
+				Label:       truncateText(fmt.Sprintf("%s %s", candidate.Item.Name, describeBranch(candidate.Item)), selectTextLimit),
+				Value:       candidate.Item.ID,
+				Description: "b-" + candidate.Item.ID,
+			})
+		}
+	}
+	description += "\n\nPick the one it refers to or create it. Picks are remembered as aliases for future imports."
+
+	fields := []*discordgo.MessageEmbedField{
+		{Name: "Name", Value: fmt.Sprintf("%d of %d", position+1, len(plan.Unresolved)), Inline: true},
+		{Name: "File", Value: plan.FileName, Inline: true},
+	}
+	components := []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.SelectMenu{
+					MenuType:    discordgo.StringSelectMenu,
+					CustomID:    fmt.Sprintf("%s%s_%d", resolveSelectPrefix, planID, position),
+					Placeholder: "Pick a " + name.Kind,
+					Options:     options,
+				},
+			},
+		},
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Cancel",
+					Style:    discordgo.DangerButton,
+					CustomID: cancelButtonPrefix + planID,
+				},
+			},
+		},
+	}
+	return reply{
+		embed:      responses.CreateBaseEmbed("Unknown names in import", description, c.BotEnv, fields),
+		components: components,
+	}
+}
+
+// handleResolve records the choice made for an unresolved name. Once every
+// name is resolved the picks are saved as aliases and the file is parsed again
+// with them, carrying on the same as /insert would have. A dry run keeps the
+// picks on the plan and saves them only when it is confirmed.
+func (c *InsertCommand) handleResolve(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, resolveID string) {
+	planID, positionText, _ := strings.Cut(resolveID, "_")
+	position, err := strconv.Atoi(positionText)
+	if err != nil {
+		responses.RespondWithEphemeralError(s, i, "Invalid menu")
+		return
+	}
+
+	plan, ok := pendingPlans.take(planID)
+	if !ok {
+		responses.RespondWithEphemeralError(s, i, "This insert is no longer available, run /insert again")
+		return
+	}
+	name, current, ok := plan.nextUnresolved()
+	values := i.MessageComponentData().Values
+	if !ok || current != position || len(values) == 0 {
+		pendingPlans.restore(planID, plan)
+		responses.RespondWithEphemeralError(s, i, "This name was already resolved")
+		return
+	}
+	plan.Resolutions[name.key()] = values[0]
+
+	if _, _, more := plan.nextUnresolved(); more {
+		pendingPlans.restore(planID, plan)
+		prompt := c.resolutionPrompt(planID, plan)
+		err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Embeds:     []*discordgo.MessageEmbed{prompt.embed},
+				Components: prompt.components,
+			},
+		})
+		if err != nil {
+			log.Printf("Error updating insert resolution: %v", err)
+		}
+		return
+	}
+
+	// saving aliases and inserting takes longer than an interaction may go unanswered
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		log.Printf("Error deferring insert resolution: %v", err)
+		return
+	}
+
+	userName, userID := i.Member.User.Username, i.Member.User.ID
+	c.runJob(ctx, s, i, func(ctx context.Context, p *progress) reply {
+		resolved, err := c.resolvePlan(ctx, plan, p)
+		if err != nil {
+			return reply{embed: responses.CreateBaseEmbed("Error: Could not read header from file", err.Error(), c.BotEnv, nil)}
+		}
+		resolved.UserName = userName
+		resolved.UserID = userID
+
+		if ctx.Err() != nil {
+			return c.cancelled(resolved.describeRounds())
+		}
+		if len(resolved.Unresolved) > 0 {
+			return c.resolutionPrompt(pendingPlans.put(resolved), resolved)
+		}
+		return c.proceed(ctx, resolved, p)
+	})
+}
+
+// resolvePlan parses the file of plan again with its resolutions. The picks
+// are saved as aliases first, unless plan is a dry run that may never be confirmed.
+func (c *InsertCommand) resolvePlan(ctx context.Context, plan *importPlan, p *progress) (*importPlan, error) {
+	var pending []unresolvedName
+	if plan.DryRun {
+		pending = append(slices.Clone(plan.PendingAliases), plan.Unresolved...)
+	} else {
+		p.stage("Saving aliases")
+		c.saveAliases(ctx, plan.Unresolved, plan.Resolutions)
+	}
+
+	resolved, err := c.parseRecords(plan.records, plan.Year, plan.Round, plan.Mode, plan.Resolutions, p)
+	if err != nil {
+		return nil, err
+	}
+	resolved.FileName = plan.FileName
+	resolved.FileHash = plan.FileHash
+	resolved.DryRun = plan.DryRun
+	resolved.PendingAliases = pending
+	return resolved, nil
+}
+
+// saveAliases records every name resolved to an existing college or branch,
+// so later imports find them without asking. A failed alias only costs asking
+// again next time, so it is logged and the import carries on.
+func (c *InsertCommand) saveAliases(ctx context.Context, names []unresolvedName, resolutions map[string]string) {
+	for _, name := range names {
+		resolution := resolutions[name.key()]
+		if resolution == "" || resolution == createNew {
+			continue
+		}
+
+		var err error
+		if name.Kind == kindCollege {
+			_, err = c.PbAdmin.CreateCollegeAlias(ctx, pb.CollegeAliasCreateRequest{Name: name.Name, College: resolution})
+		} else {
+			_, err = c.PbAdmin.CreateBranchAlias(ctx, pb.BranchAliasCreateRequest{Name: name.Name, Code: name.Code, Branch: resolution})
+		}
+		if err != nil {
+			log.Printf("Error saving %s alias %s: %v", name.Kind, name.Name, err)
+		}
+	}
+}
+
+// truncateText cuts text to at most limit characters
+func truncateText(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-1]) + "…"
+}
@@ -0,0 +1,76 @@
+package insert
+
+import (
+	"context"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/arinji2/dasa-bot/pb"
+)
+
+func TestResolvePlanAliases(t *testing.T) {
+	tests := []struct {
+		name   string
+		dryRun bool
+		// wantResolved and wantConfirmed are how many aliases are stored after resolving and after confirming
+		wantResolved, wantConfirmed int
+	}{
+		{name: "insert saves the alias right away", wantResolved: 1, wantConfirmed: 1},
+		{name: "dry run saves the alias once confirmed", dryRun: true, wantResolved: 0, wantConfirmed: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newTestStore()
+			c := &InsertCommand{Data: loadData(t, store), PbAdmin: store}
+			file := testHeader + "Regional Engineering College Kozhikode,EC,Electronics and Communication Engineering,false,300,400\n"
+
+			plan, err := c.parseRecords(readAll(csv.NewReader(strings.NewReader(file))), 2024, 2, modeInsert, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(plan.Unresolved) != 1 {
+				t.Fatalf("got %d unresolved names, want 1", len(plan.Unresolved))
+			}
+			plan.DryRun = tt.dryRun
+			plan.Resolutions[plan.Unresolved[0].key()] = "nitc"
+
+			resolved, err := c.resolvePlan(ctx, plan, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(resolved.Ranks) != 1 || len(resolved.Unresolved) != 0 {
+				t.Fatalf("got %d ranks and %d unresolved names, want 1 and 0", len(resolved.Ranks), len(resolved.Unresolved))
+			}
+			assertAliases(t, store, tt.wantResolved)
+			if !tt.dryRun {
+				return
+			}
+
+			// a preview that is cancelled or expires leaves no aliases behind
+			if len(resolved.PendingAliases) != 1 {
+				t.Fatalf("got %d pending aliases, want 1", len(resolved.PendingAliases))
+			}
+			c.confirm(ctx, resolved, nil)
+			assertAliases(t, store, tt.wantConfirmed)
+			if _, ok := storedRank(t, store, 2024, 2, "nitc", "ece"); !ok {
+				t.Fatal("confirmed plan did not store its rank")
+			}
+		})
+	}
+}
+
+func assertAliases(t *testing.T, store pb.Store, want int) {
+	t.Helper()
+	aliases, err := store.GetAllCollegeAliases(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aliases) != want {
+		t.Fatalf("got %d college aliases, want %d", len(aliases), want)
+	}
+	if want > 0 && (aliases[0].Name != "Regional Engineering College Kozhikode" || aliases[0].College != "nitc") {
+		t.Fatalf("got alias %+v, want Regional Engineering College Kozhikode for nitc", aliases[0])
+	}
+}
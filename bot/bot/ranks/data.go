@@ -3,53 +3,44 @@ package rank
 import (
 	"errors"
 	"regexp"
-	"sort"
 	"strings"
 
 	"github.com/arinji2/dasa-bot/convert"
 	"github.com/arinji2/dasa-bot/pb"
 )
 
-// Handles both id and name.
+// Handles id, name and recorded aliases.
 func (r *RankCommand) getCollegeData(collegeID string) (*pb.CollegeCollection, error) {
 	if collegeID == "" {
 		return nil, errors.New("no college ID provided")
 	}
+	data := r.Data.Load()
 	isIDSearch := !strings.Contains(collegeID, " ")
 	if isIDSearch {
-		for _, v := range r.CollegeData {
-			if v.ID == collegeID {
-				return &v, nil
-			}
-		}
-		return nil, errors.New("no college found with that ID")
-	}
-	for _, v := range r.CollegeData {
-		if strings.EqualFold(v.Name, collegeID) {
-			return &v, nil
+		if college, ok := data.College(collegeID); ok {
+			return &college, nil
 		}
 	}
 
+	if college, ok := data.CollegeByName(collegeID); ok {
+		return &college, nil
+	}
+	// names typed out instead of picked may be one a college was merged from
+	if college, ok := data.CollegeByAlias(collegeID); ok {
+		return &college, nil
+	}
+	if isIDSearch {
+		return nil, errors.New("no college found with that ID")
+	}
 	return nil, errors.New("no college found with that name")
 }
 
 func (r *RankCommand) branchesForCollege(collegeID string, ciwg bool, year, round int) []pb.BranchCollection {
-	branches := []pb.BranchCollection{}
-	for _, v := range r.RankData {
-		if v.College == collegeID && v.Expand.Branch.Ciwg == ciwg && v.Year == year && v.Round == round {
-			branches = append(branches, v.Expand.Branch)
-		}
-	}
-	return branches
+	return r.Data.Load().BranchesForCollege(collegeID, ciwg, year, round)
 }
 
 func (r *RankCommand) ranksForCollege(collegeID string, ciwg bool, year, round int) ([]pb.RankCollection, error) {
-	rank := []pb.RankCollection{}
-	for _, v := range r.RankData {
-		if v.College == collegeID && v.Expand.Branch.Ciwg == ciwg && v.Year == year && v.Round == round {
-			rank = append(rank, v)
-		}
-	}
+	rank := r.Data.Load().RanksForCollege(collegeID, ciwg, year, round)
 	if len(rank) == 0 {
 		return rank, errors.New("no ranks found for the selected criteria")
 	}
@@ -57,13 +48,8 @@ func (r *RankCommand) ranksForCollege(collegeID string, ciwg bool, year, round i
 }
 
 func (r *RankCommand) specificRank(collegeID, branchCode string, ciwg bool, year, round int) (pb.RankCollection, error) {
-	rank := pb.RankCollection{}
-	for _, v := range r.RankData {
-		if v.College == collegeID && v.Expand.Branch.Code == branchCode && v.Expand.Branch.Ciwg == ciwg && v.Year == year && v.Round == round {
-			rank = v
-		}
-	}
-	if rank.ID == "" {
+	rank, ok := r.Data.Load().SpecificRank(collegeID, branchCode, ciwg, year, round)
+	if !ok {
 		return rank, errors.New("no rank found for the selected criteria")
 	}
 	return rank, nil
@@ -111,22 +97,14 @@ func (r *RankCommand) findMatchingRanks(rankStr, deviationStr, branchData string
 		})
 	}
 
-	latestYear := r.RankData[0].Year
-	latestRound := r.RankData[0].Round
-
-	for _, v := range r.RankData {
-		if v.Expand.Branch.Ciwg != ciwg {
-			continue
-		}
-
-		if v.Year != latestYear {
-			continue
-		}
-
-		if v.Round != latestRound {
-			continue
-		}
+	data := r.Data.Load()
+	latestYear, latestRound, ok := data.Latest()
+	if !ok {
+		return nil, errors.New("no ranks loaded")
+	}
 
+	// already ordered by closing rank and limited to closeRank >= lowerBound
+	for _, v := range data.RanksClosingFrom(latestYear, latestRound, ciwg, lowerBound) {
 		match := false
 		branchName := strings.ToLower(v.Expand.Branch.Name)
 		branchCode := strings.ToLower(v.Expand.Branch.Code)
@@ -148,19 +126,13 @@ func (r *RankCommand) findMatchingRanks(rankStr, deviationStr, branchData string
 			continue
 		}
 
-		if closeRank >= lowerBound {
-			collegeToRank = append(collegeToRank, v)
-		}
+		collegeToRank = append(collegeToRank, v)
 	}
 
 	if len(collegeToRank) == 0 {
 		return nil, errors.New("no ranks matched the given criteria")
 	}
 
-	sort.Slice(collegeToRank, func(i, j int) bool {
-		return collegeToRank[i].JeeClose < collegeToRank[j].JeeClose
-	})
-
 	var chunks [][]pb.RankCollection
 	currentChunk := []pb.RankCollection{}
 
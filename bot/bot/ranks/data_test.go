@@ -0,0 +1,248 @@
+package rank
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/arinji2/dasa-bot/bot/dataset"
+	"github.com/arinji2/dasa-bot/pb"
+)
+
+// newTestCommand serves the ranks of a MemoryStore the way the background sync does
+func newTestCommand(t *testing.T) *RankCommand {
+	t.Helper()
+	store := pb.NewMemoryStore(
+		[]pb.CollegeCollection{
+			{ID: "nitc", Name: "National Institute of Technology Calicut", Alias: "NITC"},
+			{ID: "nitt", Name: "National Institute of Technology Tiruchirappalli", Alias: "NITT"},
+		},
+		[]pb.BranchCollection{
+			{ID: "cse", Name: "Computer Science and Engineering", Code: "CS"},
+			{ID: "cseciwg", Name: "Computer Science and Engineering", Code: "CS", Ciwg: true},
+			{ID: "ece", Name: "Electronics and Communication Engineering", Code: "EC"},
+			{ID: "me", Name: "Mechanical Engineering", Code: "ME"},
+		},
+		[]pb.RankCollection{
+			{ID: "r1", Year: 2024, Round: 2, College: "nitc", Branch: "cse", JeeOpen: 1000, JeeClose: 5000},
+			{ID: "r2", Year: 2024, Round: 2, College: "nitc", Branch: "ece", JeeOpen: 5000, JeeClose: 9000},
+			{ID: "r3", Year: 2024, Round: 2, College: "nitt", Branch: "cse", JeeOpen: 500, JeeClose: 3000},
+			{ID: "r4", Year: 2024, Round: 2, College: "nitt", Branch: "me", JeeOpen: 8000, JeeClose: 20000},
+			{ID: "r5", Year: 2024, Round: 2, College: "nitc", Branch: "cseciwg", JeeOpen: 100, JeeClose: 800},
+			{ID: "r6", Year: 2024, Round: 1, College: "nitc", Branch: "cse", JeeOpen: 900, JeeClose: 4000},
+			{ID: "r7", Year: 2024, Round: 2, College: "nitt", Branch: "ece", JeeOpen: 0, JeeClose: 0},
+		},
+	)
+	if _, err := store.CreateCollegeAlias(context.Background(), pb.CollegeAliasCreateRequest{Name: "REC Calicut", College: "nitc"}); err != nil {
+		t.Fatal(err)
+	}
+	return newCommand(t, store)
+}
+
+func newCommand(t *testing.T, store pb.Store) *RankCommand {
+	t.Helper()
+	ctx := context.Background()
+	colleges, err := store.GetAllColleges(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	branches, err := store.GetAllBranches(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranks, err := store.GetAllRanks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collegeAliases, err := store.GetAllCollegeAliases(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := dataset.New()
+	d.Publish(dataset.NewSnapshot(colleges, branches, ranks, dataset.Aliases{Colleges: collegeAliases}))
+	return &RankCommand{Data: d, PbAdmin: store}
+}
+
+func TestGetCollegeData(t *testing.T) {
+	r := newTestCommand(t)
+	tests := []struct {
+		name    string
+		search  string
+		want    string
+		wantErr string
+	}{
+		{name: "id", search: "nitt", want: "nitt"},
+		{name: "full name", search: "National Institute of Technology Calicut", want: "nitc"},
+		{name: "name ignores case", search: "national institute of technology calicut", want: "nitc"},
+		{name: "recorded alias", search: "REC Calicut", want: "nitc"},
+		{name: "unknown id", search: "missing", wantErr: "no college found with that ID"},
+		{name: "unknown name", search: "Indian Institute of Science", wantErr: "no college found with that name"},
+		{name: "empty", search: "", wantErr: "no college ID provided"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			college, err := r.getCollegeData(tt.search)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("got %v, want error %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if college.ID != tt.want {
+				t.Fatalf("got college %s, want %s", college.ID, tt.want)
+			}
+		})
+	}
+}
+
+func TestCutoffRanks(t *testing.T) {
+	r := newTestCommand(t)
+	tests := []struct {
+		name        string
+		college     string
+		ciwg        bool
+		year, round int
+		wantIDs     []string
+	}{
+		{name: "every branch of a round", college: "nitc", year: 2024, round: 2, wantIDs: []string{"r1", "r2"}},
+		{name: "ciwg ranks are separate", college: "nitc", ciwg: true, year: 2024, round: 2, wantIDs: []string{"r5"}},
+		{name: "earlier round", college: "nitc", year: 2024, round: 1, wantIDs: []string{"r6"}},
+		{name: "round without ranks", college: "nitc", year: 2023, round: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranks, err := r.ranksForCollege(tt.college, tt.ciwg, tt.year, tt.round)
+			if len(tt.wantIDs) == 0 {
+				if err == nil {
+					t.Fatalf("got %d ranks, want an error", len(ranks))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertIDs(t, ranks, tt.wantIDs)
+			if branches := r.branchesForCollege(tt.college, tt.ciwg, tt.year, tt.round); len(branches) != len(tt.wantIDs) {
+				t.Fatalf("got %d branches, want %d", len(branches), len(tt.wantIDs))
+			}
+		})
+	}
+}
+
+func TestSpecificRank(t *testing.T) {
+	r := newTestCommand(t)
+	tests := []struct {
+		name   string
+		code   string
+		ciwg   bool
+		round  int
+		wantID string
+	}{
+		{name: "branch code", code: "EC", round: 2, wantID: "r2"},
+		{name: "ciwg branch", code: "CS", ciwg: true, round: 2, wantID: "r5"},
+		{name: "other round", code: "CS", round: 1, wantID: "r6"},
+		{name: "code of another college", code: "ME", round: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rank, err := r.specificRank("nitc", tt.code, tt.ciwg, 2024, tt.round)
+			if tt.wantID == "" {
+				if err == nil {
+					t.Fatalf("got rank %s, want an error", rank.ID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rank.ID != tt.wantID {
+				t.Fatalf("got rank %s, want %s", rank.ID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestFindMatchingRanks(t *testing.T) {
+	r := newTestCommand(t)
+	tests := []struct {
+		name      string
+		rank      string
+		deviation string
+		branch    string
+		ciwg      bool
+		wantIDs   []string
+		wantErr   bool
+	}{
+		// only the latest round counts, ordered by closing rank
+		{name: "branch code", rank: "2000", deviation: "0", branch: "CS", wantIDs: []string{"r3", "r1"}},
+		{name: "deviation lowers the bound", rank: "6000", deviation: "50", branch: "CS", wantIDs: []string{"r3", "r1"}},
+		{name: "closing rank below the bound", rank: "6000", deviation: "0", branch: "CS", wantErr: true},
+		{name: "keyword list", rank: "4000", deviation: "0", branch: "Circuit:electronics, mechanical", wantIDs: []string{"r2", "r4"}},
+		{name: "keywords match whole words", rank: "1", deviation: "0", branch: "Engineer", wantErr: true},
+		{name: "ciwg", rank: "1", deviation: "0", branch: "CS", ciwg: true, wantIDs: []string{"r5"}},
+		// r7 has no opening or closing rank published
+		{name: "empty ranks are left out", rank: "1", deviation: "0", branch: "EC", wantIDs: []string{"r2"}},
+		{name: "invalid rank", rank: "first", deviation: "0", branch: "CS", wantErr: true},
+		{name: "invalid deviation", rank: "1", deviation: "ten", branch: "CS", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := r.findMatchingRanks(tt.rank, tt.deviation, tt.branch, tt.ciwg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %d pages, want an error", len(chunks))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ranks []pb.RankCollection
+			for _, chunk := range chunks {
+				ranks = append(ranks, chunk...)
+			}
+			assertIDs(t, ranks, tt.wantIDs)
+		})
+	}
+}
+
+func TestFindMatchingRanksPages(t *testing.T) {
+	ctx := context.Background()
+	store := pb.NewMemoryStore([]pb.CollegeCollection{{ID: "nitc", Name: "NIT Calicut"}}, nil, nil)
+	for idx := range 23 {
+		branch, err := store.CreateBranch(ctx, pb.BranchCreateRequest{Name: "Engineering", Code: "E"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, err = store.CreateRank(ctx, pb.RankCreateRequest{Year: 2024, Round: 1, College: "nitc", Branch: branch.ID, JeeOpen: 1, JeeClose: 100 + idx}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := newCommand(t, store)
+
+	chunks, err := r.findMatchingRanks("1", "0", "engineering", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 3 || len(chunks[0]) != 10 || len(chunks[2]) != 3 {
+		t.Fatalf("got %d pages, want pages of 10, 10 and 3", len(chunks))
+	}
+	if chunks[0][0].JeeClose != 100 || chunks[2][2].JeeClose != 122 {
+		t.Fatalf("pages are not ordered by closing rank")
+	}
+}
+
+func assertIDs(t *testing.T, ranks []pb.RankCollection, want []string) {
+	t.Helper()
+	got := make([]string, len(ranks))
+	for idx, rank := range ranks {
+		got[idx] = rank.ID
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got ranks %v, want %v", got, want)
+	}
+}
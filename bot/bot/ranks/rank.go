@@ -3,11 +3,12 @@ package rank
 
 import (
 	"log"
-	"sort"
+	"slices"
 	"strconv"
 	"strings"
 
 	buttons "github.com/arinji2/dasa-bot/bot/buttons"
+	"github.com/arinji2/dasa-bot/bot/dataset"
 	"github.com/arinji2/dasa-bot/env"
 	"github.com/arinji2/dasa-bot/pb"
 	"github.com/bwmarrin/discordgo"
@@ -17,11 +18,10 @@ import (
 const maxSelectOptions = 25
 
 type RankCommand struct {
-	RankData    []pb.RankCollection
-	CollegeData []pb.CollegeCollection
-	PbAdmin     pb.PocketbaseAdmin
-	BotEnv      env.Bot
-	BotChannel  string
+	Data       *dataset.Dataset
+	PbAdmin    pb.Store
+	BotEnv     env.Bot
+	BotChannel string
 }
 
 func (r *RankCommand) HandleRankCutoffResponse(s *discordgo.Session, i *discordgo.InteractionCreate) {
@@ -58,12 +58,16 @@ func (r *RankCommand) HandleRankCutoffAutocomplete(s *discordgo.Session, i *disc
 		searchTerm := strings.ToLower(data.Options[0].StringValue())
 		count := 0
 
-		for _, v := range r.CollegeData {
+		collegeData := r.Data.Load()
+		for _, v := range collegeData.Colleges {
 			if count >= 25 {
 				break
 			}
 			if searchTerm == "" || strings.Contains(strings.ToLower(v.Alias), searchTerm) ||
-				strings.Contains(strings.ToLower(v.Name), searchTerm) {
+				strings.Contains(strings.ToLower(v.Name), searchTerm) ||
+				slices.ContainsFunc(collegeData.CollegeAliasNames(v.ID), func(alias string) bool {
+					return strings.Contains(strings.ToLower(alias), searchTerm)
+				}) {
 				name := v.Name
 				if len(name) > 100 {
 					name = name[:97] + "..."
@@ -80,18 +84,7 @@ func (r *RankCommand) HandleRankCutoffAutocomplete(s *discordgo.Session, i *disc
 		searchTerm := strings.ToLower(data.Options[1].StringValue())
 		count := 0
 
-		yearSet := make(map[int]struct{})
-		for _, v := range r.RankData {
-			yearSet[v.Year] = struct{}{}
-		}
-
-		var years []int
-		for y := range yearSet {
-			years = append(years, y)
-		}
-		sort.Ints(years)
-
-		for _, v := range years {
+		for _, v := range r.Data.Load().Years() {
 			stringYear := strconv.Itoa(v)
 			if count >= 25 {
 				break
@@ -397,8 +397,7 @@ func (r *RankCommand) displayAnalyzePage(s *discordgo.Session, i *discordgo.Inte
 		return
 	}
 
-	latestYear := r.RankData[0].Year
-	latestRound := r.RankData[0].Round
+	latestYear, latestRound, _ := r.Data.Load().Latest()
 	title := "Chances based off of your JEE(Main) CRL-Rank"
 
 	matchingRanks := matchingRankChunks[currentPage]
@@ -0,0 +1,436 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arinji2/dasa-bot/bot/dataset"
+	"github.com/arinji2/dasa-bot/pb"
+)
+
+const (
+	// syncInterval is how often changed records are pulled in the background
+	syncInterval = 2 * time.Minute
+	// reconcileInterval is how often ids are compared to drop deleted records
+	reconcileInterval = 30 * time.Minute
+	// realtimePublishDelay batches realtime events, an import can push hundreds at once
+	realtimePublishDelay = time.Second
+	// realtimeResyncGap is how long the realtime stream may be down before deletions are reconciled
+	realtimeResyncGap = 30 * time.Second
+)
+
+var syncedCollections = []string{"colleges", "branches", "ranks", "college_aliases", "branch_aliases"}
+
+// dataSync keeps an in-memory copy of every college, branch and rank. After the
+// first full load only records updated since the previous sync are fetched,
+// deletions are picked up by periodically reconciling record ids.
+type dataSync struct {
+	mu       sync.Mutex
+	colleges map[string]pb.CollegeCollection
+	branches map[string]pb.BranchCollection
+	ranks    map[string]pb.RankCollection
+
+	collegeAliases map[string]pb.CollegeAliasCollection
+	branchAliases  map[string]pb.BranchAliasCollection
+	// lastSync holds the newest updated timestamp fetched per collection. Only
+	// fetches advance it, a realtime event past records missed while the
+	// stream was down would otherwise hide them from the next sync.
+	lastSync map[string]string
+}
+
+var dataSyncer = newDataSync()
+
+func newDataSync() *dataSync {
+	return &dataSync{
+		colleges: make(map[string]pb.CollegeCollection),
+		branches: make(map[string]pb.BranchCollection),
+		ranks:    make(map[string]pb.RankCollection),
+
+		collegeAliases: make(map[string]pb.CollegeAliasCollection),
+		branchAliases:  make(map[string]pb.BranchAliasCollection),
+		lastSync:       make(map[string]string),
+	}
+}
+
+// sync merges every record changed since the last sync and returns how many actually changed
+func (d *dataSync) sync(ctx context.Context, store pb.Store) (int, error) {
+	// fetch without the lock, realtime events keep being applied meanwhile
+	d.mu.Lock()
+	since := maps.Clone(d.lastSync)
+	d.mu.Unlock()
+
+	colleges, err := fetchSince(ctx, since["colleges"], store.GetAllColleges, store.GetCollegesUpdatedSince)
+	if err != nil {
+		return 0, err
+	}
+	branches, err := fetchSince(ctx, since["branches"], store.GetAllBranches, store.GetBranchesUpdatedSince)
+	if err != nil {
+		return 0, err
+	}
+	ranks, err := fetchSince(ctx, since["ranks"], store.GetAllRanks, store.GetRanksUpdatedSince)
+	if err != nil {
+		return 0, err
+	}
+	collegeAliases, err := fetchSince(ctx, since["college_aliases"], store.GetAllCollegeAliases, store.GetCollegeAliasesUpdatedSince)
+	if err != nil {
+		return 0, err
+	}
+	branchAliases, err := fetchSince(ctx, since["branch_aliases"], store.GetAllBranchAliases, store.GetBranchAliasesUpdatedSince)
+	if err != nil {
+		return 0, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	changed := mergeRecords(d.colleges, colleges, "colleges", d.lastSync,
+		func(c pb.CollegeCollection) (string, string) { return c.ID, c.Updated })
+	changed += mergeRecords(d.branches, branches, "branches", d.lastSync,
+		func(b pb.BranchCollection) (string, string) { return b.ID, b.Updated })
+	changed += mergeRecords(d.ranks, ranks, "ranks", d.lastSync,
+		func(r pb.RankCollection) (string, string) { return r.ID, r.Updated })
+	changed += mergeRecords(d.collegeAliases, collegeAliases, "college_aliases", d.lastSync,
+		func(a pb.CollegeAliasCollection) (string, string) { return a.ID, a.Updated })
+	changed += mergeRecords(d.branchAliases, branchAliases, "branch_aliases", d.lastSync,
+		func(a pb.BranchAliasCollection) (string, string) { return a.ID, a.Updated })
+
+	return changed, nil
+}
+
+// reload replaces every record with a full load. A restored backup brings back
+// records older than the last sync, which an incremental sync would skip.
+func (d *dataSync) reload(ctx context.Context, store pb.Store) (int, error) {
+	fresh := newDataSync()
+	loaded, err := fresh.sync(ctx, store)
+	if err != nil {
+		return 0, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.colleges = fresh.colleges
+	d.branches = fresh.branches
+	d.ranks = fresh.ranks
+	d.collegeAliases = fresh.collegeAliases
+	d.branchAliases = fresh.branchAliases
+	d.lastSync = fresh.lastSync
+	return loaded, nil
+}
+
+// reconcile drops records that no longer exist in Pocketbase and returns how many were dropped
+func (d *dataSync) reconcile(ctx context.Context, store pb.Store) (int, error) {
+	// only records known before the ids are listed can be missing from them,
+	// anything synced or pushed while listing is newer than the lists
+	d.mu.Lock()
+	known := map[string]map[string]struct{}{
+		"colleges":        recordIDs(d.colleges),
+		"branches":        recordIDs(d.branches),
+		"ranks":           recordIDs(d.ranks),
+		"college_aliases": recordIDs(d.collegeAliases),
+		"branch_aliases":  recordIDs(d.branchAliases),
+	}
+	d.mu.Unlock()
+
+	collegeIDs, err := store.ListRecordIDs(ctx, "colleges")
+	if err != nil {
+		return 0, err
+	}
+	branchIDs, err := store.ListRecordIDs(ctx, "branches")
+	if err != nil {
+		return 0, err
+	}
+	rankIDs, err := store.ListRecordIDs(ctx, "ranks")
+	if err != nil {
+		return 0, err
+	}
+	collegeAliasIDs, err := store.ListRecordIDs(ctx, "college_aliases")
+	if err != nil {
+		return 0, err
+	}
+	branchAliasIDs, err := store.ListRecordIDs(ctx, "branch_aliases")
+	if err != nil {
+		return 0, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	removed := removeMissing(d.colleges, known["colleges"], collegeIDs)
+	removed += removeMissing(d.branches, known["branches"], branchIDs)
+	removed += removeMissing(d.ranks, known["ranks"], rankIDs)
+	removed += removeMissing(d.collegeAliases, known["college_aliases"], collegeAliasIDs)
+	removed += removeMissing(d.branchAliases, known["branch_aliases"], branchAliasIDs)
+	return removed, nil
+}
+
+// publish swaps in a new snapshot built from the current records
+func (d *dataSync) publish() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	collegeData := make([]pb.CollegeCollection, 0, len(d.colleges))
+	for _, college := range d.colleges {
+		collegeData = append(collegeData, college)
+	}
+	slices.SortFunc(collegeData, func(a, b pb.CollegeCollection) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	branchData := make([]pb.BranchCollection, 0, len(d.branches))
+	for _, branch := range d.branches {
+		branchData = append(branchData, branch)
+	}
+
+	rankData := make([]pb.RankCollection, 0, len(d.ranks))
+	for _, rank := range d.ranks {
+		// relations may have changed after the rank was fetched, so expand from the latest copies
+		if college, ok := d.colleges[rank.College]; ok {
+			rank.Expand.College = college
+		}
+		if branch, ok := d.branches[rank.Branch]; ok {
+			rank.Expand.Branch = branch
+		}
+		rankData = append(rankData, rank)
+	}
+	aliases := dataset.Aliases{
+		Colleges: make([]pb.CollegeAliasCollection, 0, len(d.collegeAliases)),
+		Branches: make([]pb.BranchAliasCollection, 0, len(d.branchAliases)),
+	}
+	for _, alias := range d.collegeAliases {
+		aliases.Colleges = append(aliases.Colleges, alias)
+	}
+	for _, alias := range d.branchAliases {
+		aliases.Branches = append(aliases.Branches, alias)
+	}
+
+	log.Printf("Found %d colleges", len(collegeData))
+	log.Printf("Found %d ranks", len(rankData))
+	log.Printf("Found %d branches", len(branchData))
+
+	log.Printf("Found %d college and %d branch aliases", len(aliases.Colleges), len(aliases.Branches))
+
+	Data.Publish(dataset.NewSnapshot(collegeData, branchData, rankData, aliases))
+}
+
+// runSync keeps the dataset fresh in the background until ctx is cancelled
+func (d *dataSync) runSync(ctx context.Context) {
+	syncTicker := time.NewTicker(syncInterval)
+	defer syncTicker.Stop()
+	reconcileTicker := time.NewTicker(reconcileInterval)
+	defer reconcileTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-syncTicker.C:
+			changed, err := d.sync(ctx, PbAdmin)
+			if err != nil {
+				log.Printf("Error syncing data: %v", err)
+				continue
+			}
+			if changed > 0 {
+				d.publish()
+			}
+		case <-reconcileTicker.C:
+			removed, err := d.reconcile(ctx, PbAdmin)
+			if err != nil {
+				log.Printf("Error reconciling data: %v", err)
+				continue
+			}
+			if removed > 0 {
+				log.Printf("Removed %d deleted records", removed)
+				d.publish()
+			}
+		}
+	}
+}
+
+// apply merges a single realtime event and reports whether anything changed
+func (d *dataSync) apply(event pb.RealtimeEvent) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch event.Collection {
+	case "colleges":
+		return applyEvent(d.colleges, event,
+			func(c pb.CollegeCollection) (string, string) { return c.ID, c.Updated })
+	case "branches":
+		return applyEvent(d.branches, event,
+			func(b pb.BranchCollection) (string, string) { return b.ID, b.Updated })
+	case "ranks":
+		return applyEvent(d.ranks, event,
+			func(r pb.RankCollection) (string, string) { return r.ID, r.Updated })
+	case "college_aliases":
+		return applyEvent(d.collegeAliases, event,
+			func(a pb.CollegeAliasCollection) (string, string) { return a.ID, a.Updated })
+	case "branch_aliases":
+		return applyEvent(d.branchAliases, event,
+			func(a pb.BranchAliasCollection) (string, string) { return a.ID, a.Updated })
+	}
+	return false, nil
+}
+
+// runRealtime applies record changes pushed by Pocketbase as they happen
+func (d *dataSync) runRealtime(ctx context.Context, subscriber pb.Subscriber) {
+	dirty := make(chan struct{}, 1)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-dirty:
+				time.Sleep(realtimePublishDelay)
+				d.publish()
+			}
+		}
+	}()
+	markDirty := func() {
+		select {
+		case dirty <- struct{}{}:
+		default:
+		}
+	}
+
+	// resyncs run off the stream so events keep flowing while records are fetched
+	resync := make(chan time.Duration, 1)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case gap := <-resync:
+				if d.resync(ctx, gap) > 0 {
+					markDirty()
+				}
+			}
+		}
+	}()
+
+	subscriber.Subscribe(ctx, syncedCollections, pb.RealtimeHandler{
+		OnEvent: func(event pb.RealtimeEvent) {
+			changed, err := d.apply(event)
+			if err != nil {
+				log.Printf("Error applying realtime %s event for %s: %v", event.Action, event.Collection, err)
+				return
+			}
+			if changed {
+				markDirty()
+			}
+		},
+		OnReconnect: func(gap time.Duration) {
+			log.Printf("Realtime reconnected after %v, resyncing", gap)
+			// fold into a resync that has not started yet, keeping the longest gap
+			select {
+			case queued := <-resync:
+				gap = max(gap, queued)
+			default:
+			}
+			resync <- gap
+		},
+	})
+}
+
+// resync catches up on changes missed while the realtime stream was down gap
+// long and returns how many records changed
+func (d *dataSync) resync(ctx context.Context, gap time.Duration) int {
+	changed, err := d.sync(ctx, PbAdmin)
+	if err != nil {
+		log.Printf("Error syncing data: %v", err)
+	}
+	if gap > realtimeResyncGap {
+		removed, err := d.reconcile(ctx, PbAdmin)
+		if err != nil {
+			log.Printf("Error reconciling data: %v", err)
+		}
+		changed += removed
+	}
+	return changed
+}
+
+// applyEvent stores or deletes the record of event. lastSync is left alone,
+// the next sync fetches the record again and finds it unchanged.
+func applyEvent[T any](records map[string]T, event pb.RealtimeEvent, key func(T) (id, updated string)) (bool, error) {
+	var record T
+	err := json.Unmarshal(event.Record, &record)
+	if err != nil {
+		return false, err
+	}
+	id, _ := key(record)
+
+	if event.Action == pb.RealtimeDelete {
+		if _, ok := records[id]; !ok {
+			return false, nil
+		}
+		delete(records, id)
+		return true, nil
+	}
+	return storeRecord(records, record, key), nil
+}
+
+// fetchSince does a full load the first time and only fetches changes after that
+func fetchSince[T any](ctx context.Context, since string, all func(context.Context) ([]T, error), updated func(context.Context, string) ([]T, error)) ([]T, error) {
+	if since == "" {
+		return all(ctx)
+	}
+	return updated(ctx, since)
+}
+
+// mergeRecords stores items and advances lastSync for collection. Records that
+// are refetched with an unchanged timestamp are not counted as changes.
+func mergeRecords[T any](records map[string]T, items []T, collection string, lastSync map[string]string, key func(T) (id, updated string)) int {
+	changed := 0
+	for _, item := range items {
+		_, updated := key(item)
+		lastSync[collection] = max(lastSync[collection], updated)
+		if storeRecord(records, item, key) {
+			changed++
+		}
+	}
+	return changed
+}
+
+// storeRecord keeps item unless the stored copy is as new. A fetch that
+// started before a realtime event can return an older copy than the event's.
+func storeRecord[T any](records map[string]T, item T, key func(T) (id, updated string)) bool {
+	id, updated := key(item)
+	if existing, ok := records[id]; ok {
+		if _, existingUpdated := key(existing); existingUpdated >= updated {
+			return false
+		}
+	}
+	records[id] = item
+	return true
+}
+
+func recordIDs[T any](records map[string]T) map[string]struct{} {
+	ids := make(map[string]struct{}, len(records))
+	for id := range records {
+		ids[id] = struct{}{}
+	}
+	return ids
+}
+
+// removeMissing drops the records of known that are not in ids
+func removeMissing[T any](records map[string]T, known map[string]struct{}, ids []string) int {
+	existing := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		existing[id] = struct{}{}
+	}
+
+	removed := 0
+	for id := range known {
+		if _, ok := existing[id]; ok {
+			continue
+		}
+		if _, ok := records[id]; ok {
+			delete(records, id)
+			removed++
+		}
+	}
+	return removed
+}
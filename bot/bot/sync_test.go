@@ -0,0 +1,267 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/arinji2/dasa-bot/pb"
+)
+
+func collegeEvent(t *testing.T, action string, college pb.CollegeCollection) pb.RealtimeEvent {
+	t.Helper()
+	record, err := json.Marshal(college)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pb.RealtimeEvent{Collection: "colleges", Action: action, Record: record}
+}
+
+func TestRealtimeEventKeepsWatermark(t *testing.T) {
+	ctx := context.Background()
+	store := pb.NewMemoryStore([]pb.CollegeCollection{{ID: "nitc", Name: "NIT Calicut", Updated: "2024-01-01 00:00:00.000Z"}}, nil, nil)
+	d := newDataSync()
+	if _, err := d.sync(ctx, store); err != nil {
+		t.Fatal(err)
+	}
+
+	// edited while the stream was down, so no event arrives for it
+	missed, err := store.CreateColleges(ctx, []pb.CollegeCreateRequest{{Name: "NIT Trichy"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the first event after reconnecting is newer than the missed edit
+	changed, err := d.apply(collegeEvent(t, pb.RealtimeCreate, pb.CollegeCollection{ID: "iiita", Name: "IIIT Allahabad", Updated: "2999-01-01 00:00:00.000Z"}))
+	if err != nil || !changed {
+		t.Fatalf("got changed %t and %v, want the event applied", changed, err)
+	}
+	if d.lastSync["colleges"] != "2024-01-01 00:00:00.000Z" {
+		t.Fatalf("realtime event moved the watermark to %s", d.lastSync["colleges"])
+	}
+
+	if _, err := d.sync(ctx, store); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.colleges[missed[0].ID]; !ok {
+		t.Fatal("resync skipped the college edited while the stream was down")
+	}
+}
+
+func TestSyncKeepsNewerRealtimeCopy(t *testing.T) {
+	d := newDataSync()
+	newer := pb.CollegeCollection{ID: "nitc", Name: "NIT Calicut", Updated: "2024-01-02 00:00:00.000Z"}
+	if _, err := d.apply(collegeEvent(t, pb.RealtimeUpdate, newer)); err != nil {
+		t.Fatal(err)
+	}
+
+	// fetched before the event arrived
+	older := pb.CollegeCollection{ID: "nitc", Name: "REC Calicut", Updated: "2024-01-01 00:00:00.000Z"}
+	if changed := mergeRecords(d.colleges, []pb.CollegeCollection{older}, "colleges", d.lastSync, collegeKey); changed != 0 {
+		t.Fatalf("got %d changes, want the older copy ignored", changed)
+	}
+	if d.colleges["nitc"].Name != "NIT Calicut" {
+		t.Fatalf("got %s, want the realtime copy kept", d.colleges["nitc"].Name)
+	}
+}
+
+func TestMergeRecords(t *testing.T) {
+	stored := pb.CollegeCollection{ID: "nitc", Name: "NIT Calicut", Updated: "2024-01-02 00:00:00.000Z"}
+	tests := []struct {
+		name        string
+		items       []pb.CollegeCollection
+		wantChanged int
+		wantName    string
+		wantSync    string
+	}{
+		{
+			name:        "new record",
+			items:       []pb.CollegeCollection{{ID: "iiita", Name: "IIIT Allahabad", Updated: "2024-01-01 00:00:00.000Z"}},
+			wantChanged: 1,
+			wantName:    "NIT Calicut",
+			wantSync:    "2024-01-05 00:00:00.000Z",
+		},
+		{
+			name:        "newer copy replaces the stored one",
+			items:       []pb.CollegeCollection{{ID: "nitc", Name: "NIT Kozhikode", Updated: "2024-01-03 00:00:00.000Z"}},
+			wantChanged: 1,
+			wantName:    "NIT Kozhikode",
+			wantSync:    "2024-01-05 00:00:00.000Z",
+		},
+		{
+			name:     "same copy fetched again",
+			items:    []pb.CollegeCollection{stored},
+			wantName: "NIT Calicut",
+			wantSync: "2024-01-05 00:00:00.000Z",
+		},
+		{
+			name:        "newest fetch moves the watermark",
+			items:       []pb.CollegeCollection{stored, {ID: "iiita", Name: "IIIT Allahabad", Updated: "2024-02-01 00:00:00.000Z"}},
+			wantChanged: 1,
+			wantName:    "NIT Calicut",
+			wantSync:    "2024-02-01 00:00:00.000Z",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := map[string]pb.CollegeCollection{"nitc": stored}
+			lastSync := map[string]string{"colleges": "2024-01-05 00:00:00.000Z"}
+			if changed := mergeRecords(records, tt.items, "colleges", lastSync, collegeKey); changed != tt.wantChanged {
+				t.Fatalf("got %d changes, want %d", changed, tt.wantChanged)
+			}
+			if records["nitc"].Name != tt.wantName {
+				t.Fatalf("got %s, want %s", records["nitc"].Name, tt.wantName)
+			}
+			if lastSync["colleges"] != tt.wantSync {
+				t.Fatalf("got watermark %s, want %s", lastSync["colleges"], tt.wantSync)
+			}
+		})
+	}
+}
+
+func TestRemoveMissing(t *testing.T) {
+	tests := []struct {
+		name        string
+		known       []string
+		ids         []string
+		wantRemoved int
+		wantIDs     []string
+	}{
+		{name: "nothing deleted", known: []string{"a", "b", "c"}, ids: []string{"a", "b", "c"}, wantIDs: []string{"a", "b", "c"}},
+		{name: "deleted record dropped", known: []string{"a", "b"}, ids: []string{"a"}, wantRemoved: 1, wantIDs: []string{"a", "c"}},
+		// c arrived after the ids were snapshotted, so the list may predate it
+		{name: "record newer than the list kept", known: []string{"a", "b"}, ids: []string{"a", "b"}, wantIDs: []string{"a", "b", "c"}},
+		{name: "record already gone", known: []string{"a", "b", "d"}, ids: []string{"a", "b"}, wantIDs: []string{"a", "b", "c"}},
+		{name: "everything deleted", known: []string{"a", "b", "c"}, wantRemoved: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := map[string]pb.CollegeCollection{"a": {ID: "a"}, "b": {ID: "b"}, "c": {ID: "c"}}
+			known := make(map[string]struct{})
+			for _, id := range tt.known {
+				known[id] = struct{}{}
+			}
+			if removed := removeMissing(records, known, tt.ids); removed != tt.wantRemoved {
+				t.Fatalf("got %d removed, want %d", removed, tt.wantRemoved)
+			}
+			if got := slices.Sorted(maps.Keys(records)); !slices.Equal(got, tt.wantIDs) {
+				t.Fatalf("got %v, want %v", got, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestSyncAndReconcile(t *testing.T) {
+	ctx := context.Background()
+	store := pb.NewMemoryStore([]pb.CollegeCollection{
+		{ID: "nitc", Name: "NIT Calicut", Updated: "2024-01-01 00:00:00.000Z"},
+		{ID: "iiita", Name: "IIIT Allahabad", Updated: "2024-01-01 00:00:00.000Z"},
+	}, nil, nil)
+	d := newDataSync()
+	if changed, err := d.sync(ctx, store); err != nil || changed != 2 {
+		t.Fatalf("got %d changes and %v, want both colleges", changed, err)
+	}
+
+	if _, err := store.UpdateCollege(ctx, "nitc", pb.CollegeCreateRequest{Name: "NIT Kozhikode"}); err != nil {
+		t.Fatal(err)
+	}
+	// only the edited college is fetched again
+	if changed, err := d.sync(ctx, store); err != nil || changed != 1 {
+		t.Fatalf("got %d changes and %v, want the edited college", changed, err)
+	}
+	if d.colleges["nitc"].Name != "NIT Kozhikode" {
+		t.Fatalf("got %s, want the edit merged", d.colleges["nitc"].Name)
+	}
+
+	// deletions never show up as updates, only reconciling finds them
+	if err := store.DeleteRecords(ctx, "colleges", []string{"iiita"}); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := d.sync(ctx, store); err != nil || changed != 0 {
+		t.Fatalf("got %d changes and %v, want none", changed, err)
+	}
+	if removed, err := d.reconcile(ctx, store); err != nil || removed != 1 {
+		t.Fatalf("got %d removed and %v, want the deleted college", removed, err)
+	}
+	if _, ok := d.colleges["iiita"]; ok {
+		t.Fatal("deleted college is still there")
+	}
+}
+
+// blockingStore holds fetches and id lists until release is closed
+type blockingStore struct {
+	*pb.MemoryStore
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStore) GetAllColleges(ctx context.Context) ([]pb.CollegeCollection, error) {
+	close(s.started)
+	<-s.release
+	return s.MemoryStore.GetAllColleges(ctx)
+}
+
+func (s *blockingStore) ListRecordIDs(ctx context.Context, collection string) ([]string, error) {
+	if collection == "colleges" {
+		close(s.started)
+		<-s.release
+	}
+	return s.MemoryStore.ListRecordIDs(ctx, collection)
+}
+
+func TestFetchesDoNotBlockRealtime(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(d *dataSync, store pb.Store) error
+	}{
+		{name: "sync", run: func(d *dataSync, store pb.Store) error {
+			_, err := d.sync(context.Background(), store)
+			return err
+		}},
+		{name: "reconcile", run: func(d *dataSync, store pb.Store) error {
+			_, err := d.reconcile(context.Background(), store)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &blockingStore{
+				MemoryStore: pb.NewMemoryStore([]pb.CollegeCollection{{ID: "nitc", Name: "NIT Calicut"}}, nil, nil),
+				started:     make(chan struct{}),
+				release:     make(chan struct{}),
+			}
+			d := newDataSync()
+			done := make(chan error, 1)
+			go func() { done <- tt.run(d, store) }()
+			<-store.started
+
+			// pushed while the request is in flight
+			event := collegeEvent(t, pb.RealtimeCreate, pb.CollegeCollection{ID: "iiita", Name: "IIIT Allahabad", Updated: "2024-01-01 00:00:00.000Z"})
+			applied := make(chan struct{})
+			go func() {
+				d.apply(event)
+				close(applied)
+			}()
+			select {
+			case <-applied:
+			case <-time.After(time.Second):
+				t.Fatal("realtime event waited for the request")
+			}
+			close(store.release)
+			if err := <-done; err != nil {
+				t.Fatal(err)
+			}
+
+			// the id list predates the event, it must not drop the new college
+			if _, ok := d.colleges["iiita"]; !ok {
+				t.Fatal("college pushed during the request was dropped")
+			}
+		})
+	}
+}
+
+func collegeKey(c pb.CollegeCollection) (string, string) {
+	return c.ID, c.Updated
+}
@@ -1,14 +1,13 @@
 package bot
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"slices"
 	"strings"
 
 	buttons "github.com/arinji2/dasa-bot/bot/buttons"
-	"github.com/arinji2/dasa-bot/env"
-	"github.com/arinji2/dasa-bot/pb"
 	"github.com/bwmarrin/discordgo"
 )
 
@@ -57,46 +56,34 @@ func checkChannel(s *discordgo.Session, i *discordgo.InteractionCreate, isAdminC
 	return nil
 }
 
-func refreshData(botEnv *env.Bot) {
+// refreshData pulls in every record changed since the last refresh and hands
+// the result to the commands. The first call loads everything.
+func refreshData(ctx context.Context) error {
 	log.Println("Refreshing data...")
 
-	locCollegeData, err := PbAdmin.GetAllColleges()
+	changed, err := dataSyncer.sync(ctx, PbAdmin)
 	if err != nil {
-		log.Panicf("Cannot get colleges: %v", err)
-		locCollegeData = make([]pb.CollegeCollection, 0)
+		return fmt.Errorf("cannot sync data: %w", err)
 	}
-	log.Printf("Found %d colleges", len(locCollegeData))
+	log.Printf("Synced %d changed records", changed)
 
-	locRankData, err := PbAdmin.GetAllRanks()
-	if err != nil {
-		log.Panicf("Cannot get ranks: %v", err)
-		locRankData = make([]pb.RankCollection, 0)
-	}
-	log.Printf("Found %d ranks", len(locRankData))
+	dataSyncer.publish()
+	return nil
+}
+
+// reloadData throws away every record and loads them all again, for after a
+// backup was restored
+func reloadData(ctx context.Context) error {
+	log.Println("Reloading data...")
 
-	locBranchData, err := PbAdmin.GetAllBranches()
+	loaded, err := dataSyncer.reload(ctx, PbAdmin)
 	if err != nil {
-		log.Panicf("Cannot get branches: %v", err)
-		locBranchData = make([]pb.BranchCollection, 0)
+		return fmt.Errorf("cannot reload data: %w", err)
 	}
-	log.Printf("Found %d branches", len(locBranchData))
-
-	InsertCommand.BranchData = locBranchData
+	log.Printf("Loaded %d records", loaded)
 
-	RankCommand.CollegeData = locCollegeData
-	InsertCommand.CollegeData = locCollegeData
-
-	RankCommand.RankData = locRankData
-	InsertCommand.RankData = locRankData
-
-	RankCommand.PbAdmin = *PbAdmin
-	InsertCommand.PbAdmin = *PbAdmin
-
-	RankCommand.BotChannel = BotChannel
-	if botEnv != nil {
-		RankCommand.BotEnv = *botEnv
-		InsertCommand.BotEnv = *botEnv
-	}
+	dataSyncer.publish()
+	return nil
 }
 
 func (b *Bot) registerCommands() []*discordgo.ApplicationCommand {
@@ -104,7 +91,7 @@ func (b *Bot) registerCommands() []*discordgo.ApplicationCommand {
 		switch i.Type {
 		case discordgo.InteractionApplicationCommand:
 			if h, ok := commandHandlers[i.ApplicationCommandData().Name]; ok {
-				h(s, i)
+				h(b.ctx, s, i)
 			}
 		case discordgo.InteractionMessageComponent:
 			if i.MessageComponentData().CustomID == "college_send_dm" {
@@ -115,10 +102,20 @@ func (b *Bot) registerCommands() []*discordgo.ApplicationCommand {
 				RankCommand.HandleAnalyzeResponse(s, i)
 			} else if strings.HasPrefix(i.MessageComponentData().CustomID, "anext_") || strings.HasPrefix(i.MessageComponentData().CustomID, "aprev_") {
 				RankCommand.HandleAnalyzePagination(s, i)
+			} else if strings.HasPrefix(i.MessageComponentData().CustomID, "insert_") {
+				if checkPermissions(s, i) != nil {
+					return
+				}
+				InsertCommand.HandleInsertButton(b.ctx, s, i)
+			} else if strings.HasPrefix(i.MessageComponentData().CustomID, "backup_") {
+				if checkPermissions(s, i) != nil {
+					return
+				}
+				BackupCommand.HandleBackupButton(b.ctx, s, i)
 			}
 		case discordgo.InteractionApplicationCommandAutocomplete:
 			if h, ok := commandHandlers[i.ApplicationCommandData().Name]; ok {
-				h(s, i)
+				h(b.ctx, s, i)
 			}
 		}
 	})
@@ -0,0 +1,119 @@
+// Command dasa-export dumps the colleges, branches, ranks and aliases in
+// Pocketbase to an archive, and restores such an archive into an empty
+// Pocketbase. It reads the same Pocketbase variables from .env as the bot.
+//
+//	dasa-export export [-format json|csv] [-o file]
+//	dasa-export import -i file
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/arinji2/dasa-bot/archive"
+	"github.com/arinji2/dasa-bot/env"
+	"github.com/arinji2/dasa-bot/network"
+	"github.com/arinji2/dasa-bot/pb"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = runExport(ctx, os.Args[2:])
+	case "import":
+		err = runImport(ctx, os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: dasa-export export [-format json|csv] [-o file]")
+	fmt.Fprintln(os.Stderr, "       dasa-export import -i file")
+	os.Exit(2)
+}
+
+func runExport(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	formatFlag := flags.String("format", "json", "format of the files in the archive, json or csv")
+	output := flags.String("o", "", "file to write the archive to, dasa-export-<date>.zip by default")
+	flags.Parse(args)
+
+	format, err := archive.ParseFormat(*formatFlag)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if *output == "" {
+		*output = archive.FileName(now)
+	}
+
+	d, err := archive.Collect(ctx, connect(ctx))
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := archive.Write(&buf, d, format, now); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := os.WriteFile(*output, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	log.Printf("Exported %s to %s", d.Counts(), *output)
+	return nil
+}
+
+func runImport(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	input := flags.String("i", "", "archive to restore")
+	flags.Parse(args)
+	if *input == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	data, err := os.ReadFile(*input)
+	if err != nil {
+		return err
+	}
+	manifest, d, err := archive.Read(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	log.Printf("Restoring %s exported at %s", manifest.Counts, manifest.Exported)
+
+	restored, err := archive.Restore(ctx, connect(ctx), d)
+	if err != nil {
+		return fmt.Errorf("restored %s before failing: %w", restored, err)
+	}
+	log.Printf("Restored %s", restored)
+	return nil
+}
+
+func connect(ctx context.Context) *pb.PocketbaseAdmin {
+	e := env.SetupPBEnv()
+	network.Configure(network.Config{
+		RequestTimeout: e.RequestTimeout,
+		MaxRetries:     e.MaxRetries,
+	})
+	return pb.SetupPocketbase(ctx, e)
+}
@@ -0,0 +1,213 @@
+// Package cron parses cron expressions and works out when they next fire
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression. Each field holds a bit per value it matches.
+type Schedule struct {
+	spec   string
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	// anyDay is set when either day field is *, so only the other one restricts the day
+	anyDay bool
+}
+
+type bounds struct {
+	name     string
+	min, max int
+}
+
+var (
+	minuteBounds = bounds{"minute", 0, 59}
+	hourBounds   = bounds{"hour", 0, 23}
+	domBounds    = bounds{"day of month", 1, 31}
+	monthBounds  = bounds{"month", 1, 12}
+	// 7 is accepted for Sunday as well as 0
+	dowBounds = bounds{"day of week", 0, 7}
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse reads a standard five field cron expression, minute hour day-of-month
+// month day-of-week, or one of the @daily style descriptors. Fields accept *,
+// values, ranges like 1-5, steps like */15 and lists like 1,15.
+func Parse(spec string) (*Schedule, error) {
+	expr := strings.TrimSpace(spec)
+	if descriptor, ok := descriptors[strings.ToLower(expr)]; ok {
+		expr = descriptor
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields in %q, got %d", spec, len(fields))
+	}
+
+	s := &Schedule{spec: spec}
+	var err error
+	if s.minute, err = parseField(fields[0], minuteBounds); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseField(fields[1], hourBounds); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseField(fields[2], domBounds); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseField(fields[3], monthBounds); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseField(fields[4], dowBounds); err != nil {
+		return nil, err
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.anyDay = strings.HasPrefix(fields[2], "*") || strings.HasPrefix(fields[4], "*")
+	return s, nil
+}
+
+func (s *Schedule) String() string {
+	return s.spec
+}
+
+// Next returns the first time after t the schedule fires, in the location of t.
+// It is the zero time if the schedule never fires, such as on the 30th of February.
+// Times skipped by a daylight saving change are skipped too, and times it
+// repeats only fire the first time.
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case !has(s.month, int(t.Month())):
+			t = advance(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc))
+		case !s.matchesDay(t):
+			t = advance(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc))
+		case !has(s.hour, t.Hour()):
+			t = advance(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc))
+		case !has(s.minute, t.Minute()) || earliest(t).Before(t):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// matchesDay follows cron in firing on either day field when both are restricted
+func (s *Schedule) matchesDay(t time.Time) bool {
+	dom := has(s.dom, t.Day())
+	dow := has(s.dow, int(t.Weekday()))
+	if s.anyDay {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// maxZoneShift is more than any daylight saving change moves the clock by
+const maxZoneShift = 3 * time.Hour
+
+// earliest returns the first time the clock read the time of t, which is an
+// hour or so before t during the hour repeated when daylight saving time ends
+func earliest(t time.Time) time.Time {
+	_, offset := t.Zone()
+	_, earlierOffset := t.Add(-maxZoneShift).Zone()
+	if earlierOffset > offset {
+		earlier := t.Add(-time.Duration(earlierOffset-offset) * time.Second)
+		if earlier.Format(time.DateTime) == t.Format(time.DateTime) {
+			return earlier
+		}
+	}
+	return t
+}
+
+// advance moves t on to next, a time built from wall clock fields. Around a
+// daylight saving change next may be repeated, when its first occurrence is
+// taken, or may not exist and come out no later than t, when t only moves on
+// by a minute.
+func advance(t, next time.Time) time.Time {
+	if first := earliest(next); first.After(t) {
+		return first
+	}
+	if next.After(t) {
+		return next
+	}
+	return t.Add(time.Minute)
+}
+
+func has(bits uint64, value int) bool {
+	return bits&(1<<value) != 0
+}
+
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepPart, b.name)
+			}
+		}
+
+		start, end := b.min, b.max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			from, to, _ := strings.Cut(rangePart, "-")
+			var err error
+			if start, err = parseValue(from, b); err != nil {
+				return 0, err
+			}
+			if end, err = parseValue(to, b); err != nil {
+				return 0, err
+			}
+			if start > end {
+				return 0, fmt.Errorf("invalid range %q in %s field", rangePart, b.name)
+			}
+		default:
+			value, err := parseValue(rangePart, b)
+			if err != nil {
+				return 0, err
+			}
+			start = value
+			// a single value with a step runs to the end, 5/15 means 5,20,35,50
+			end = value
+			if hasStep {
+				end = b.max
+			}
+		}
+
+		for value := start; value <= end; value += step {
+			bits |= 1 << value
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(value string, b bounds) (int, error) {
+	number, err := strconv.Atoi(value)
+	if err != nil || number < b.min || number > b.max {
+		return 0, fmt.Errorf("invalid value %q in %s field, expected %d-%d", value, b.name, b.min, b.max)
+	}
+	return number, nil
+}
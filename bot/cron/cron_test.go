@@ -0,0 +1,113 @@
+package cron
+
+import (
+	"testing"
+	"time"
+	// the daylight saving cases need zone data wherever the tests run
+	_ "time/tzdata"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{spec: "0 3 * * *"},
+		{spec: " @Daily "},
+		{spec: "*/15 0-6,18-23 1,15 */2 1-5"},
+		{spec: "5/15 * * * 7"},
+		{spec: "0 3 * *", wantErr: true},
+		{spec: "0 3 * * * *", wantErr: true},
+		{spec: "60 * * * *", wantErr: true},
+		{spec: "* 24 * * *", wantErr: true},
+		{spec: "* * 0 * *", wantErr: true},
+		{spec: "* * 32 * *", wantErr: true},
+		{spec: "* * * 13 *", wantErr: true},
+		{spec: "* * * * 8", wantErr: true},
+		{spec: "*/0 * * * *", wantErr: true},
+		{spec: "*/x * * * *", wantErr: true},
+		{spec: "5-1 * * * *", wantErr: true},
+		{spec: "mon * * * *", wantErr: true},
+		{spec: "@weekdays", wantErr: true},
+		{spec: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		schedule, err := Parse(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: got error %v, want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if err == nil && schedule.String() != tt.spec {
+			t.Errorf("%q: got spec %q", tt.spec, schedule.String())
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utc := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		want []time.Time
+	}{
+		{name: "strictly after", spec: "@hourly", from: utc(2024, 1, 1, 10, 0), want: []time.Time{utc(2024, 1, 1, 11, 0), utc(2024, 1, 1, 12, 0)}},
+		{name: "seconds are ignored", spec: "* * * * *", from: time.Date(2024, 1, 1, 10, 0, 59, 999, time.UTC), want: []time.Time{utc(2024, 1, 1, 10, 1)}},
+		{name: "step", spec: "*/15 * * * *", from: utc(2024, 1, 1, 10, 7), want: []time.Time{utc(2024, 1, 1, 10, 15), utc(2024, 1, 1, 10, 30)}},
+		{name: "step from a value", spec: "5/20 * * * *", from: utc(2024, 1, 1, 10, 46), want: []time.Time{utc(2024, 1, 1, 11, 5), utc(2024, 1, 1, 11, 25)}},
+		{name: "step over a range", spec: "0 9-17/4 * * *", from: utc(2024, 1, 1, 0, 0), want: []time.Time{utc(2024, 1, 1, 9, 0), utc(2024, 1, 1, 13, 0), utc(2024, 1, 1, 17, 0), utc(2024, 1, 2, 9, 0)}},
+		{name: "list", spec: "0 0 1,15 * *", from: utc(2024, 1, 10, 0, 0), want: []time.Time{utc(2024, 1, 15, 0, 0), utc(2024, 2, 1, 0, 0)}},
+		{name: "year end", spec: "@monthly", from: utc(2024, 12, 15, 0, 0), want: []time.Time{utc(2025, 1, 1, 0, 0)}},
+		{name: "last minute of the year", spec: "59 23 31 12 *", from: utc(2024, 12, 31, 23, 59), want: []time.Time{utc(2025, 12, 31, 23, 59)}},
+		// months without a 31st are skipped
+		{name: "31st", spec: "0 0 31 * *", from: utc(2024, 1, 31, 0, 0), want: []time.Time{utc(2024, 3, 31, 0, 0), utc(2024, 5, 31, 0, 0), utc(2024, 7, 31, 0, 0), utc(2024, 8, 31, 0, 0)}},
+		{name: "leap day", spec: "0 0 29 2 *", from: utc(2024, 3, 1, 0, 0), want: []time.Time{utc(2028, 2, 29, 0, 0)}},
+		{name: "30th of february", spec: "0 0 30 2 *", from: utc(2024, 1, 1, 0, 0), want: []time.Time{{}}},
+		// 2024-06-05 is a Wednesday
+		{name: "7 is sunday", spec: "0 12 * * 7", from: utc(2024, 6, 5, 0, 0), want: []time.Time{utc(2024, 6, 9, 12, 0), utc(2024, 6, 16, 12, 0)}},
+		{name: "0 is sunday", spec: "0 12 * * 0", from: utc(2024, 6, 5, 0, 0), want: []time.Time{utc(2024, 6, 9, 12, 0)}},
+		{name: "weekdays", spec: "0 0 * * 1-5", from: utc(2024, 6, 7, 0, 0), want: []time.Time{utc(2024, 6, 10, 0, 0)}},
+		// both day fields restricted fire on either, 2024-09-01 is a Sunday
+		{name: "day of month or friday", spec: "0 0 13 * 5", from: utc(2024, 9, 1, 0, 0), want: []time.Time{utc(2024, 9, 6, 0, 0), utc(2024, 9, 13, 0, 0), utc(2024, 9, 20, 0, 0), utc(2024, 9, 27, 0, 0), utc(2024, 10, 4, 0, 0), utc(2024, 10, 11, 0, 0), utc(2024, 10, 13, 0, 0)}},
+		// a day field starting with * does not count as restricted, so both have to match
+		{name: "stepped day of month and monday", spec: "0 0 */10 * 1", from: utc(2024, 1, 1, 0, 0), want: []time.Time{utc(2024, 3, 11, 0, 0)}},
+		{name: "day of month and any weekday", spec: "0 0 13 * *", from: utc(2024, 9, 1, 0, 0), want: []time.Time{utc(2024, 9, 13, 0, 0)}},
+		{name: "location of from", spec: "0 9 * * *", from: time.Date(2024, 6, 1, 10, 0, 0, 0, newYork), want: []time.Time{time.Date(2024, 6, 2, 9, 0, 0, 0, newYork)}},
+		// 2:30 does not exist on 2024-03-10 in New York, the clocks go from 2:00 to 3:00
+		{name: "skipped by daylight saving", spec: "30 2 * * *", from: time.Date(2024, 3, 9, 3, 0, 0, 0, newYork), want: []time.Time{time.Date(2024, 3, 11, 2, 30, 0, 0, newYork)}},
+		{name: "hourly over the skipped hour", spec: "0 * * * *", from: time.Date(2024, 3, 10, 1, 0, 0, 0, newYork), want: []time.Time{time.Date(2024, 3, 10, 3, 0, 0, 0, newYork), time.Date(2024, 3, 10, 4, 0, 0, 0, newYork)}},
+		// 1:30 happens twice on 2024-11-03 in New York and on 2024-10-27 in London
+		{name: "repeated by daylight saving", spec: "30 1 * * *", from: time.Date(2024, 11, 3, 0, 0, 0, 0, newYork), want: []time.Time{time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC), time.Date(2024, 11, 4, 6, 30, 0, 0, time.UTC)}},
+		{name: "repeated in another zone", spec: "30 1 * * *", from: time.Date(2024, 10, 27, 0, 0, 0, 0, london), want: []time.Time{time.Date(2024, 10, 27, 0, 30, 0, 0, time.UTC), time.Date(2024, 10, 28, 1, 30, 0, 0, time.UTC)}},
+		{name: "hourly over the repeated hour", spec: "0 * * * *", from: time.Date(2024, 11, 3, 4, 30, 0, 0, time.UTC).In(newYork), want: []time.Time{time.Date(2024, 11, 3, 5, 0, 0, 0, time.UTC), time.Date(2024, 11, 3, 7, 0, 0, 0, time.UTC)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			next := tt.from
+			for _, want := range tt.want {
+				next = schedule.Next(next)
+				if !next.Equal(want) {
+					t.Fatalf("got %v, want %v", next, want)
+				}
+				if !next.IsZero() && next.Location() != tt.from.Location() {
+					t.Fatalf("got location %v, want %v", next.Location(), tt.from.Location())
+				}
+			}
+		})
+	}
+}
@@ -3,7 +3,10 @@ package env
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/arinji2/dasa-bot/cron"
 	_ "github.com/joho/godotenv/autoload"
 )
 
@@ -17,13 +20,30 @@ type Bot struct {
 }
 
 type PB struct {
-	Email      string
-	Password   string
-	BaseDomain string
+	Email          string
+	Password       string
+	BaseDomain     string
+	RequestTimeout time.Duration
+	MaxRetries     int
 }
+
+// Backup is when Pocketbase is backed up and how long the backups are kept
+type Backup struct {
+	// Keep and MaxAge limit the backups taken by hand, zero disables a limit
+	Keep   int
+	MaxAge time.Duration
+	// Schedule is when backups are taken automatically, nil when they are not
+	Schedule *cron.Schedule
+	// Daily, Weekly and Monthly are how many days, weeks and months keep a scheduled backup
+	Daily   int
+	Weekly  int
+	Monthly int
+}
+
 type Env struct {
-	Bot Bot
-	PB  PB
+	Bot    Bot
+	PB     PB
+	Backup Backup
 }
 
 func loadEnv(envName string) string {
@@ -34,18 +54,83 @@ func loadEnv(envName string) string {
 	return val
 }
 
+// loadOptionalEnv returns fallback when envName is not set
+func loadOptionalEnv(envName string, fallback string) string {
+	val := os.Getenv(envName)
+	if val == "" {
+		return fallback
+	}
+	return val
+}
+
+// loadTier reads how many scheduled backups a retention tier keeps
+func loadTier(envName string, fallback string) int {
+	val, err := strconv.Atoi(loadOptionalEnv(envName, fallback))
+	if err != nil {
+		log.Fatalf("Environment variable %s is invalid: %v", envName, err)
+	}
+	if val < 0 {
+		log.Fatalf("Environment variable %s cannot be negative", envName)
+	}
+	return val
+}
+
+// SetupPBEnv loads only the Pocketbase variables, for tools that talk to
+// Pocketbase without running the bot
+func SetupPBEnv() PB {
+	requestTimeout, err := time.ParseDuration(loadOptionalEnv("PB_REQUEST_TIMEOUT", "15s"))
+	if err != nil {
+		log.Fatalf("Environment variable PB_REQUEST_TIMEOUT is invalid: %v", err)
+	}
+	maxRetries, err := strconv.Atoi(loadOptionalEnv("PB_MAX_RETRIES", "3"))
+	if err != nil {
+		log.Fatalf("Environment variable PB_MAX_RETRIES is invalid: %v", err)
+	}
+
+	return PB{
+		Email:          loadEnv("ADMIN_EMAIL"),
+		Password:       loadEnv("ADMIN_PASSWORD"),
+		BaseDomain:     loadEnv("BASE_DOMAIN"),
+		RequestTimeout: requestTimeout,
+		MaxRetries:     maxRetries,
+	}
+}
+
 func SetupEnv() *Env {
 	log.Println("Loading environment variables...")
 	token := loadEnv("TOKEN")
 	guildID := loadEnv("GUILD_ID")
-	adminEmail := loadEnv("ADMIN_EMAIL")
-	adminPassword := loadEnv("ADMIN_PASSWORD")
-	baseDomain := loadEnv("BASE_DOMAIN")
+	pb := SetupPBEnv()
 	modRole := loadEnv("MOD_ROLE")
 	thumbnail := loadEnv("THUMBNAIL")
 	botChannel := loadEnv("BOT_CHANNEL")
 	adminChannel := loadEnv("ADMIN_CHANNEL")
 
+	backupKeep, err := strconv.Atoi(loadOptionalEnv("BACKUP_KEEP", "3"))
+	if err != nil {
+		log.Fatalf("Environment variable BACKUP_KEEP is invalid: %v", err)
+	}
+	if backupKeep < 0 {
+		log.Fatalf("Environment variable BACKUP_KEEP cannot be negative")
+	}
+	backupMaxAge, err := time.ParseDuration(loadOptionalEnv("BACKUP_MAX_AGE", "0"))
+	if err != nil {
+		log.Fatalf("Environment variable BACKUP_MAX_AGE is invalid: %v", err)
+	}
+	if backupMaxAge < 0 {
+		log.Fatalf("Environment variable BACKUP_MAX_AGE cannot be negative")
+	}
+	var backupSchedule *cron.Schedule
+	if spec := loadOptionalEnv("BACKUP_SCHEDULE", "0 3 * * *"); spec != "off" {
+		backupSchedule, err = cron.Parse(spec)
+		if err != nil {
+			log.Fatalf("Environment variable BACKUP_SCHEDULE is invalid: %v", err)
+		}
+	}
+	backupDaily := loadTier("BACKUP_KEEP_DAILY", "7")
+	backupWeekly := loadTier("BACKUP_KEEP_WEEKLY", "4")
+	backupMonthly := loadTier("BACKUP_KEEP_MONTHLY", "6")
+
 	log.Println("Environment variables loaded.")
 	return &Env{
 		Bot: Bot{
@@ -56,10 +141,14 @@ func SetupEnv() *Env {
 			BotChannel:   botChannel,
 			AdminChannel: adminChannel,
 		},
-		PB: PB{
-			Email:      adminEmail,
-			Password:   adminPassword,
-			BaseDomain: baseDomain,
+		PB: pb,
+		Backup: Backup{
+			Keep:     backupKeep,
+			MaxAge:   backupMaxAge,
+			Schedule: backupSchedule,
+			Daily:    backupDaily,
+			Weekly:   backupWeekly,
+			Monthly:  backupMonthly,
 		},
 	}
 }
@@ -1,32 +1,29 @@
 package main
 
 import (
+	"context"
 	"log"
-	"time"
 
 	"github.com/arinji2/dasa-bot/bot"
 	"github.com/arinji2/dasa-bot/env"
+	"github.com/arinji2/dasa-bot/network"
 	"github.com/arinji2/dasa-bot/pb"
 )
 
 func main() {
 	e := env.SetupEnv()
 
-	pbAdmin := pb.SetupPocketbase(e.PB)
+	network.Configure(network.Config{
+		RequestTimeout: e.PB.RequestTimeout,
+		MaxRetries:     e.PB.MaxRetries,
+	})
 
-	ticker := time.NewTicker(5 * time.Hour)
-	defer ticker.Stop()
-
-	// Refreshing Admin Token
-	go func() {
-		for range ticker.C {
-			pbAdmin = pb.SetupPocketbase(e.PB)
-		}
-	}()
+	// The client renews its own token, so a single instance is shared everywhere
+	pbAdmin := pb.SetupPocketbase(context.Background(), e.PB)
 
 	discordBot, err := bot.NewBot(e.Bot)
 	if err != nil {
 		log.Panicf("Cannot create bot: %v", err)
 	}
-	discordBot.Run(pbAdmin)
+	discordBot.Run(pbAdmin, e.Backup)
 }
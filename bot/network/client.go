@@ -0,0 +1,67 @@
+package network
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Config controls the client shared by every request in this package
+type Config struct {
+	// RequestTimeout is the deadline for a single attempt, retries get a fresh one
+	RequestTimeout time.Duration
+	// MaxRetries is how many times a failed request may be sent again
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+var DefaultConfig = Config{
+	RequestTimeout: 15 * time.Second,
+	MaxRetries:     3,
+	BaseBackoff:    250 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+}
+
+var (
+	// client has no overall timeout so long lived streams can share its
+	// connection pool, deadlines are set per request through the context instead
+	client = &http.Client{
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 20,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+
+	configMu sync.RWMutex
+	config   = DefaultConfig
+)
+
+// Configure replaces the shared client settings, zero values keep their defaults
+func Configure(cfg Config) {
+	if cfg.RequestTimeout <= 0 {
+		cfg.RequestTimeout = DefaultConfig.RequestTimeout
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = DefaultConfig.BaseBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultConfig.MaxBackoff
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+	config = cfg
+}
+
+func currentConfig() Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config
+}
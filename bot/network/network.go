@@ -3,63 +3,231 @@ package network
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"math/rand/v2"
+	"net"
 	"net/http"
 	"net/url"
+	"sort"
+	"strings"
+	"time"
 )
 
-func MakeRequest[T any](url *url.URL, method string, body T) ([]byte, error) {
+// APIError is returned for any response outside the 2xx range. It follows the
+// error shape Pocketbase uses, where Data holds per-field validation errors.
+type APIError struct {
+	Status  int                   `json:"status"`
+	Message string                `json:"message"`
+	Data    map[string]FieldError `json:"data"`
+	// Body is the raw response, for errors whose data does not fit FieldError
+	Body []byte `json:"-"`
+}
+
+type FieldError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	if len(e.Data) == 0 {
+		return fmt.Sprintf("request failed with status %d: %s", e.Status, e.Message)
+	}
+
+	var fields []string
+	for _, field := range e.Fields() {
+		fields = append(fields, fmt.Sprintf("%s: %s", field, e.Data[field].Message))
+	}
+	return fmt.Sprintf("request failed with status %d: %s (%s)", e.Status, e.Message, strings.Join(fields, ", "))
+}
+
+// Fields returns the names of the fields that failed validation, sorted
+func (e *APIError) Fields() []string {
+	fields := make([]string, 0, len(e.Data))
+	for field := range e.Data {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func newAPIError(status int, body []byte) *APIError {
+	apiErr := &APIError{}
+	// Data is not always a map of field errors, so fall back to only the message
+	if err := json.Unmarshal(body, apiErr); err != nil {
+		var message struct {
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(body, &message); err == nil {
+			apiErr.Message = message.Message
+		} else {
+			apiErr.Message = strings.TrimSpace(string(body))
+		}
+		apiErr.Data = nil
+	}
+	apiErr.Status = status
+	apiErr.Body = body
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(status)
+	}
+	return apiErr
+}
+
+func MakeRequest[T any](ctx context.Context, url *url.URL, method string, body T) ([]byte, error) {
 	jsonBody, err := json.Marshal(body)
 	if err != nil {
 		return nil, err
 	}
-	req, err := http.NewRequest(method, url.String(), bytes.NewReader(jsonBody))
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+
+	return doRequest(ctx, method, url.String(), jsonBody, header)
+}
+
+func MakeAuthenticatedRequest[T any](ctx context.Context, url *url.URL, method string, body T, authHeader string) ([]byte, error) {
+	jsonBody, err := json.Marshal(body)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("Authorization", authHeader)
+
+	return doRequest(ctx, method, url.String(), jsonBody, header)
+}
+
+// GetFile downloads the raw contents of fileURL
+func GetFile(ctx context.Context, fileURL string) ([]byte, error) {
+	return doRequest(ctx, http.MethodGet, fileURL, nil, http.Header{})
+}
+
+// OpenStream starts a long lived GET request, such as a server-sent events stream.
+// Only waiting for the response headers is bounded by the request timeout.
+// Closing the returned body ends the stream.
+func OpenStream(ctx context.Context, url string, header http.Header) (io.ReadCloser, error) {
+	cfg := currentConfig()
+	streamCtx, cancel := context.WithCancel(ctx)
+	connectTimer := time.AfterFunc(cfg.RequestTimeout, cancel)
+
+	req, err := http.NewRequestWithContext(streamCtx, http.MethodGet, url, nil)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
-	defer resp.Body.Close()
+	req.Header = header.Clone()
 
-	responseBody, err := io.ReadAll(resp.Body)
+	resp, err := client.Do(req)
+	if !connectTimer.Stop() && err == nil {
+		resp.Body.Close()
+		err = context.DeadlineExceeded
+	}
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
-	return responseBody, nil
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer cancel()
+		defer resp.Body.Close()
+		responseBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return nil, newAPIError(resp.StatusCode, responseBody)
+	}
+
+	return &stream{ReadCloser: resp.Body, cancel: cancel}, nil
 }
 
-func MakeAuthenticatedRequest[T any](url *url.URL, method string, body T, authHeader string) ([]byte, error) {
-	jsonBody, err := json.Marshal(body)
-	if err != nil {
-		return nil, err
+type stream struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (s *stream) Close() error {
+	s.cancel()
+	return s.ReadCloser.Close()
+}
+
+// Backoff returns the jittered delay to wait before retry number attempt
+func Backoff(attempt int) time.Duration {
+	return backoff(currentConfig(), attempt)
+}
+
+// doRequest sends the request through the shared client, retrying failures
+// that are safe to retry with jittered exponential backoff.
+func doRequest(ctx context.Context, method, url string, body []byte, header http.Header) ([]byte, error) {
+	cfg := currentConfig()
+	for attempt := 0; ; attempt++ {
+		responseBody, retryable, err := doAttempt(ctx, cfg, method, url, body, header)
+		if err == nil {
+			return responseBody, nil
+		}
+		if !retryable || attempt >= cfg.MaxRetries {
+			return nil, err
+		}
+
+		timer := time.NewTimer(backoff(cfg, attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
 	}
+}
 
-	req, err := http.NewRequest(method, url.String(), bytes.NewReader(jsonBody))
+func doAttempt(ctx context.Context, cfg Config, method, url string, body []byte, header http.Header) ([]byte, bool, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, cfg.RequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, method, url, bytes.NewReader(body))
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
+	req.Header = header.Clone()
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", authHeader)
-
-	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		// the caller gave up, so there is nobody left to retry for
+		if ctx.Err() != nil {
+			return nil, false, err
+		}
+		return nil, method == http.MethodGet || isConnectionError(err), err
 	}
 	defer resp.Body.Close()
 
 	responseBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, method == http.MethodGet && ctx.Err() == nil, err
 	}
 
-	return responseBody, nil
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		retryable := method == http.MethodGet &&
+			(resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500)
+		return nil, retryable, newAPIError(resp.StatusCode, responseBody)
+	}
+
+	return responseBody, false, nil
+}
+
+// isConnectionError reports whether err happened while connecting, meaning the
+// server never saw the request and it is safe to send again.
+func isConnectionError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func backoff(cfg Config, attempt int) time.Duration {
+	delay := cfg.BaseBackoff << attempt
+	if delay <= 0 || delay > cfg.MaxBackoff {
+		delay = cfg.MaxBackoff
+	}
+	// jitter somewhere between half and all of the delay
+	half := delay / 2
+	return half + rand.N(half+1)
 }
@@ -0,0 +1,99 @@
+package pb
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+)
+
+func (p *PocketbaseAdmin) GetAllCollegeAliases(ctx context.Context) ([]CollegeAliasCollection, error) {
+	return ListAll[CollegeAliasCollection](ctx, p, "college_aliases", ListOptions{})
+}
+
+// GetCollegeAliasesUpdatedSince returns college aliases created or edited at or after since
+func (p *PocketbaseAdmin) GetCollegeAliasesUpdatedSince(ctx context.Context, since string) ([]CollegeAliasCollection, error) {
+	return updatedSince[CollegeAliasCollection](ctx, p, "college_aliases", since, "")
+}
+
+// CreateCollegeAlias records name as another name of a college
+func (p *PocketbaseAdmin) CreateCollegeAlias(ctx context.Context, alias CollegeAliasCreateRequest) (CollegeAliasCollection, error) {
+	return createRecord[CollegeAliasCollection](ctx, p, "college_aliases", alias)
+}
+
+// UpdateCollegeAlias changes the name of an alias or the college it points to
+func (p *PocketbaseAdmin) UpdateCollegeAlias(ctx context.Context, id string, alias CollegeAliasCreateRequest) (CollegeAliasCollection, error) {
+	return updateRecord[CollegeAliasCollection](ctx, p, "college_aliases", id, alias)
+}
+
+func (p *PocketbaseAdmin) GetAllBranchAliases(ctx context.Context) ([]BranchAliasCollection, error) {
+	return ListAll[BranchAliasCollection](ctx, p, "branch_aliases", ListOptions{})
+}
+
+// GetBranchAliasesUpdatedSince returns branch aliases created or edited at or after since
+func (p *PocketbaseAdmin) GetBranchAliasesUpdatedSince(ctx context.Context, since string) ([]BranchAliasCollection, error) {
+	return updatedSince[BranchAliasCollection](ctx, p, "branch_aliases", since, "")
+}
+
+// CreateBranchAlias records a name and code as another name of a branch
+func (p *PocketbaseAdmin) CreateBranchAlias(ctx context.Context, alias BranchAliasCreateRequest) (BranchAliasCollection, error) {
+	return createRecord[BranchAliasCollection](ctx, p, "branch_aliases", alias)
+}
+
+// UpdateBranchAlias changes the name and code of an alias or the branch it points to
+func (p *PocketbaseAdmin) UpdateBranchAlias(ctx context.Context, id string, alias BranchAliasCreateRequest) (BranchAliasCollection, error) {
+	return updateRecord[BranchAliasCollection](ctx, p, "branch_aliases", id, alias)
+}
+
+func createRecord[T any](ctx context.Context, p *PocketbaseAdmin, collection string, body any) (T, error) {
+	var record T
+	parsedURL, err := url.Parse(p.BaseDomain)
+	if err != nil {
+		return record, err
+	}
+	parsedURL.Path = "/api/collections/" + collection + "/records"
+
+	responseBody, err := p.authenticatedRequest(ctx, parsedURL, "POST", body)
+	if err != nil {
+		return record, err
+	}
+
+	err = json.Unmarshal(responseBody, &record)
+	return record, err
+}
+
+func updateRecord[T any](ctx context.Context, p *PocketbaseAdmin, collection string, id string, body any) (T, error) {
+	var record T
+	parsedURL, err := url.Parse(p.BaseDomain)
+	if err != nil {
+		return record, err
+	}
+	setRecordPath(parsedURL, collection, id)
+
+	responseBody, err := p.authenticatedRequest(ctx, parsedURL, "PATCH", body)
+	if err != nil {
+		return record, err
+	}
+
+	err = json.Unmarshal(responseBody, &record)
+	return record, err
+}
+
+func deleteRecord(ctx context.Context, p *PocketbaseAdmin, collection string, id string) error {
+	parsedURL, err := url.Parse(p.BaseDomain)
+	if err != nil {
+		return err
+	}
+	setRecordPath(parsedURL, collection, id)
+
+	type request struct{}
+	_, err = p.authenticatedRequest(ctx, parsedURL, "DELETE", request{})
+	return err
+}
+
+// setRecordPath points u at a single record. The id is escaped, so it stays one
+// path segment whatever it contains, the same as in batch requests.
+func setRecordPath(u *url.URL, collection string, id string) {
+	path := "/api/collections/" + collection + "/records/"
+	u.Path = path + id
+	u.RawPath = path + url.PathEscape(id)
+}
@@ -0,0 +1,47 @@
+package pb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestRecordPathEscapesID(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       string
+		wantPath string
+	}{
+		{name: "plain id", id: "abc123", wantPath: "/api/collections/college_aliases/records/abc123"},
+		{name: "slash", id: "../colleges/records/abc", wantPath: "/api/collections/college_aliases/records/..%2Fcolleges%2Frecords%2Fabc"},
+		{name: "query and fragment", id: "abc?expand=college#x", wantPath: "/api/collections/college_aliases/records/abc%3Fexpand=college%23x"},
+		{name: "space", id: "a b", wantPath: "/api/collections/college_aliases/records/a%20b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			admin, requests := newTestAdmin(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{}`))
+			})
+			ctx := context.Background()
+			if _, err := updateRecord[CollegeAliasCollection](ctx, admin, "college_aliases", tt.id, CollegeAliasCreateRequest{Name: "REC Calicut"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := deleteRecord(ctx, admin, "college_aliases", tt.id); err != nil {
+				t.Fatal(err)
+			}
+
+			sent := requests()
+			if len(sent) != 2 {
+				t.Fatalf("got %d requests, want 2", len(sent))
+			}
+			for _, request := range sent {
+				if request.RawPath != tt.wantPath {
+					t.Errorf("%s went to %s, want %s", request.Method, request.RawPath, tt.wantPath)
+				}
+				if request.Path != "/api/collections/college_aliases/records/"+tt.id {
+					t.Errorf("%s went to record %s, want %s", request.Method, request.Path, tt.id)
+				}
+			}
+		})
+	}
+}
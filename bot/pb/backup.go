@@ -1,8 +1,11 @@
 package pb
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
@@ -15,7 +18,7 @@ type BackupCeateRequest struct {
 	Name string `json:"name"`
 }
 
-func (p *PocketbaseAdmin) ListBackups() ([]BackupCollection, error) {
+func (p *PocketbaseAdmin) ListBackups(ctx context.Context) ([]BackupCollection, error) {
 	parsedURL, err := url.Parse(p.BaseDomain)
 	if err != nil {
 		return nil, err
@@ -23,7 +26,7 @@ func (p *PocketbaseAdmin) ListBackups() ([]BackupCollection, error) {
 	parsedURL.Path = "/api/backups"
 
 	type request struct{}
-	responseBody, err := network.MakeAuthenticatedRequest(parsedURL, "GET", request{}, p.Token)
+	responseBody, err := p.authenticatedRequest(ctx, parsedURL, "GET", request{})
 	if err != nil {
 		return nil, err
 	}
@@ -36,7 +39,7 @@ func (p *PocketbaseAdmin) ListBackups() ([]BackupCollection, error) {
 	return response, nil
 }
 
-func (p *PocketbaseAdmin) DeleteBackup(key string) error {
+func (p *PocketbaseAdmin) DeleteBackup(ctx context.Context, key string) error {
 	parsedURL, err := url.Parse(p.BaseDomain)
 	if err != nil {
 		return err
@@ -44,7 +47,10 @@ func (p *PocketbaseAdmin) DeleteBackup(key string) error {
 	parsedURL.Path = fmt.Sprintf("/api/backups/%s", key)
 
 	type request struct{}
-	_, err = network.MakeAuthenticatedRequest(parsedURL, "DELETE", request{}, p.Token)
+	_, err = p.authenticatedRequest(ctx, parsedURL, "DELETE", request{})
+	if IsNotFound(err) {
+		return fmt.Errorf("no backup found for key: %s", key)
+	}
 	if err != nil {
 		return err
 	}
@@ -52,7 +58,7 @@ func (p *PocketbaseAdmin) DeleteBackup(key string) error {
 	return nil
 }
 
-func (p *PocketbaseAdmin) CreateBackup(userName string) (string, error) {
+func (p *PocketbaseAdmin) CreateBackup(ctx context.Context, userName string) (string, error) {
 	parsedURL, err := url.Parse(p.BaseDomain)
 	if err != nil {
 		return "", err
@@ -65,9 +71,9 @@ func (p *PocketbaseAdmin) CreateBackup(userName string) (string, error) {
 	type request struct {
 		Name string `json:"name"`
 	}
-	_, err = network.MakeAuthenticatedRequest(parsedURL, "POST", BackupCeateRequest{
+	_, err = p.authenticatedRequest(ctx, parsedURL, "POST", BackupCeateRequest{
 		Name: backupName,
-	}, p.Token)
+	})
 	if err != nil {
 		return "", err
 	}
@@ -83,3 +89,75 @@ func sanitizeUsername(input string) string {
 
 	return safe
 }
+
+// RestoreBackup replaces all data with the backup of key. Pocketbase restarts
+// once the restore is done, so requests fail for a short while afterwards.
+func (p *PocketbaseAdmin) RestoreBackup(ctx context.Context, key string) error {
+	parsedURL, err := url.Parse(p.BaseDomain)
+	if err != nil {
+		return err
+	}
+	parsedURL.Path = fmt.Sprintf("/api/backups/%s/restore", key)
+
+	type request struct{}
+	_, err = p.authenticatedRequest(ctx, parsedURL, "POST", request{})
+	if IsNotFound(err) {
+		return fmt.Errorf("no backup found for key: %s", key)
+	}
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DownloadBackup opens the archive of the backup of key. The archive is read
+// as it arrives, the caller has to close it.
+func (p *PocketbaseAdmin) DownloadBackup(ctx context.Context, key string) (io.ReadCloser, error) {
+	fileToken, err := p.fileToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file token: %w", err)
+	}
+
+	parsedURL, err := url.Parse(p.BaseDomain)
+	if err != nil {
+		return nil, err
+	}
+	parsedURL.Path = fmt.Sprintf("/api/backups/%s", key)
+	parsedURL.RawQuery = url.Values{"token": {fileToken}}.Encode()
+
+	body, err := network.OpenStream(ctx, parsedURL.String(), http.Header{})
+	if IsNotFound(err) {
+		return nil, fmt.Errorf("no backup found for key: %s", key)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+// fileToken gets the short lived token Pocketbase wants for protected files,
+// backups are downloaded with it instead of the auth header
+func (p *PocketbaseAdmin) fileToken(ctx context.Context) (string, error) {
+	parsedURL, err := url.Parse(p.BaseDomain)
+	if err != nil {
+		return "", err
+	}
+	parsedURL.Path = "/api/files/token"
+
+	type request struct{}
+	responseBody, err := p.authenticatedRequest(ctx, parsedURL, "POST", request{})
+	if err != nil {
+		return "", err
+	}
+	var response struct {
+		Token string `json:"token"`
+	}
+	err = json.Unmarshal(responseBody, &response)
+	if err != nil {
+		return "", err
+	}
+
+	return response.Token, nil
+}
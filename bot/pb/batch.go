@@ -0,0 +1,209 @@
+package pb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/arinji2/dasa-bot/network"
+)
+
+// MaxBatchSize is the default limit on requests in a single Pocketbase batch
+const MaxBatchSize = 50
+
+// BatchRequest is a single write inside a batch
+type BatchRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   any    `json:"body,omitempty"`
+}
+
+type batchResult struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body"`
+}
+
+// BatchError reports the request that made a batch fail. Batches run in a
+// single transaction, so nothing from the batch was written.
+type BatchError struct {
+	Index int
+	Err   *network.APIError
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch request %d failed: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// CreateColleges creates every college or none of them
+func (p *PocketbaseAdmin) CreateColleges(ctx context.Context, colleges []CollegeCreateRequest) ([]CollegeCollection, error) {
+	requests := make([]BatchRequest, len(colleges))
+	for idx, college := range colleges {
+		requests[idx] = BatchRequest{
+			Method: http.MethodPost,
+			URL:    "/api/collections/colleges/records",
+			Body:   college,
+		}
+	}
+	return batchRecords[CollegeCollection](ctx, p, requests)
+}
+
+// CreateBranches creates every branch or none of them
+func (p *PocketbaseAdmin) CreateBranches(ctx context.Context, branches []BranchCreateRequest) ([]BranchCollection, error) {
+	requests := make([]BatchRequest, len(branches))
+	for idx, branch := range branches {
+		requests[idx] = BatchRequest{
+			Method: http.MethodPost,
+			URL:    "/api/collections/branches/records",
+			Body:   branch,
+		}
+	}
+	return batchRecords[BranchCollection](ctx, p, requests)
+}
+
+// CreateRanks creates every rank or none of them. Unlike CreateRank it does
+// not check for existing ranks, callers are expected to have done so.
+func (p *PocketbaseAdmin) CreateRanks(ctx context.Context, ranks []RankCreateRequest) ([]RankCollection, error) {
+	requests := make([]BatchRequest, len(ranks))
+	for idx, rank := range ranks {
+		requests[idx] = BatchRequest{
+			Method: http.MethodPost,
+			URL:    "/api/collections/ranks/records?expand=college,branch",
+			Body:   rank,
+		}
+	}
+	return batchRecords[RankCollection](ctx, p, requests)
+}
+
+// UpdateRanks changes the opening and closing ranks of every rank or none of them
+func (p *PocketbaseAdmin) UpdateRanks(ctx context.Context, ranks []RankUpdateRequest) ([]RankCollection, error) {
+	requests := make([]BatchRequest, len(ranks))
+	for idx, rank := range ranks {
+		requests[idx] = BatchRequest{
+			Method: http.MethodPatch,
+			URL:    fmt.Sprintf("/api/collections/ranks/records/%s?expand=college,branch", url.PathEscape(rank.ID)),
+			Body:   rank,
+		}
+	}
+	return batchRecords[RankCollection](ctx, p, requests)
+}
+
+// MoveRanks points every rank at its new college or branch, or none of them
+func (p *PocketbaseAdmin) MoveRanks(ctx context.Context, ranks []RankMoveRequest) ([]RankCollection, error) {
+	requests := make([]BatchRequest, len(ranks))
+	for idx, rank := range ranks {
+		requests[idx] = BatchRequest{
+			Method: http.MethodPatch,
+			URL:    fmt.Sprintf("/api/collections/ranks/records/%s?expand=college,branch", url.PathEscape(rank.ID)),
+			Body:   rank,
+		}
+	}
+	return batchRecords[RankCollection](ctx, p, requests)
+}
+
+// DeleteRecords deletes every record of collection in ids or none of them
+func (p *PocketbaseAdmin) DeleteRecords(ctx context.Context, collection string, ids []string) error {
+	requests := make([]BatchRequest, len(ids))
+	for idx, id := range ids {
+		requests[idx] = BatchRequest{
+			Method: http.MethodDelete,
+			URL:    fmt.Sprintf("/api/collections/%s/records/%s", collection, url.PathEscape(id)),
+		}
+	}
+	_, err := p.batch(ctx, requests)
+	return err
+}
+
+func batchRecords[T any](ctx context.Context, p *PocketbaseAdmin, requests []BatchRequest) ([]T, error) {
+	results, err := p.batch(ctx, requests)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]T, len(results))
+	for idx, result := range results {
+		err = json.Unmarshal(result.Body, &records[idx])
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal batch result %d: %w", idx, err)
+		}
+	}
+	return records, nil
+}
+
+// batch sends requests to /api/batch, which runs them in one transaction
+func (p *PocketbaseAdmin) batch(ctx context.Context, requests []BatchRequest) ([]batchResult, error) {
+	if len(requests) == 0 {
+		return nil, nil
+	}
+	if len(requests) > MaxBatchSize {
+		return nil, fmt.Errorf("batch of %d requests is over the limit of %d", len(requests), MaxBatchSize)
+	}
+
+	parsedURL, err := url.Parse(p.BaseDomain)
+	if err != nil {
+		return nil, err
+	}
+	parsedURL.Path = "/api/batch"
+
+	type request struct {
+		Requests []BatchRequest `json:"requests"`
+	}
+	responseBody, err := p.authenticatedRequest(ctx, parsedURL, "POST", request{Requests: requests})
+	if err != nil {
+		return nil, newBatchError(err)
+	}
+
+	var results []batchResult
+	err = json.Unmarshal(responseBody, &results)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// newBatchError digs the failed request out of a batch error response,
+// returning err unchanged when it is not one
+func newBatchError(err error) error {
+	var apiErr *network.APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest {
+		return err
+	}
+
+	var failure struct {
+		Data struct {
+			Requests map[string]struct {
+				Code     string          `json:"code"`
+				Message  string          `json:"message"`
+				Response json.RawMessage `json:"response"`
+			} `json:"requests"`
+		} `json:"data"`
+	}
+	if json.Unmarshal(apiErr.Body, &failure) != nil {
+		return err
+	}
+
+	for key, request := range failure.Data.Requests {
+		index, convErr := strconv.Atoi(key)
+		if convErr != nil {
+			continue
+		}
+
+		requestErr := &network.APIError{}
+		if json.Unmarshal(request.Response, requestErr) != nil || requestErr.Message == "" {
+			requestErr = &network.APIError{Message: request.Message}
+		}
+		if requestErr.Status == 0 {
+			requestErr.Status = http.StatusBadRequest
+		}
+		requestErr.Body = request.Response
+		return &BatchError{Index: index, Err: requestErr}
+	}
+	return err
+}
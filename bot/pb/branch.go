@@ -1,41 +1,22 @@
 package pb
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
-
-	"github.com/arinji2/dasa-bot/network"
 )
 
-func (p *PocketbaseAdmin) GetAllBranches() ([]BranchCollection, error) {
-	parsedURL, err := url.Parse(p.BaseDomain)
-	if err != nil {
-		return nil, err
-	}
-	parsedURL.Path = "/api/collections/branches/records"
-
-	params := url.Values{}
-	params.Add("perPage", "10000")
-
-	parsedURL.RawQuery = params.Encode()
-
-	type request struct{}
-	responseBody, err := network.MakeAuthenticatedRequest(parsedURL, "GET", request{}, p.Token)
-	if err != nil {
-		return nil, err
-	}
-
-	var response PbResponse[BranchCollection]
-	err = json.Unmarshal(responseBody, &response)
-	if err != nil {
-		return nil, err
-	}
+func (p *PocketbaseAdmin) GetAllBranches(ctx context.Context) ([]BranchCollection, error) {
+	return ListAll[BranchCollection](ctx, p, "branches", ListOptions{})
+}
 
-	return response.Items, nil
+// GetBranchesUpdatedSince returns branches created or edited at or after since
+func (p *PocketbaseAdmin) GetBranchesUpdatedSince(ctx context.Context, since string) ([]BranchCollection, error) {
+	return updatedSince[BranchCollection](ctx, p, "branches", since, "")
 }
 
-func (p *PocketbaseAdmin) GetBranchByCode(code string) (BranchCollection, error) {
+func (p *PocketbaseAdmin) GetBranchByCode(ctx context.Context, code string) (BranchCollection, error) {
 	parsedURL, err := url.Parse(p.BaseDomain)
 	if err != nil {
 		return BranchCollection{}, err
@@ -43,12 +24,12 @@ func (p *PocketbaseAdmin) GetBranchByCode(code string) (BranchCollection, error)
 	parsedURL.Path = "/api/collections/branches/records"
 
 	params := url.Values{}
-	params.Add("filter", fmt.Sprintf("code='%s'", code))
+	params.Add("filter", Eq("code", code).String())
 
 	parsedURL.RawQuery = params.Encode()
 
 	type request struct{}
-	responseBody, err := network.MakeAuthenticatedRequest(parsedURL, "GET", request{}, p.Token)
+	responseBody, err := p.authenticatedRequest(ctx, parsedURL, "GET", request{})
 	if err != nil {
 		return BranchCollection{}, err
 	}
@@ -66,18 +47,14 @@ func (p *PocketbaseAdmin) GetBranchByCode(code string) (BranchCollection, error)
 	return response.Items[0], nil
 }
 
-func (p *PocketbaseAdmin) CreateBranch(branch BranchCreateRequest) (BranchCollection, error) {
+func (p *PocketbaseAdmin) CreateBranch(ctx context.Context, branch BranchCreateRequest) (BranchCollection, error) {
 	parsedURL, err := url.Parse(p.BaseDomain)
 	if err != nil {
 		return BranchCollection{}, err
 	}
 	parsedURL.Path = "/api/collections/branches/records"
 
-	responseBody, err := network.MakeAuthenticatedRequest(parsedURL, "POST", BranchCreateRequest{
-		Name: branch.Name,
-		Code: branch.Code,
-		Ciwg: branch.Ciwg,
-	}, p.Token)
+	responseBody, err := p.authenticatedRequest(ctx, parsedURL, "POST", branch)
 	if err != nil {
 		return BranchCollection{}, err
 	}
@@ -90,3 +67,31 @@ func (p *PocketbaseAdmin) CreateBranch(branch BranchCreateRequest) (BranchCollec
 
 	return response, nil
 }
+
+// UpdateBranch replaces the name and code of the branch with id
+func (p *PocketbaseAdmin) UpdateBranch(ctx context.Context, id string, branch BranchCreateRequest) (BranchCollection, error) {
+	updated, err := updateRecord[BranchCollection](ctx, p, "branches", id, branch)
+	if IsNotFound(err) {
+		return BranchCollection{}, fmt.Errorf("no branch found for id: %s", id)
+	}
+	return updated, err
+}
+
+// DeleteBranch deletes a branch along with its aliases. Pocketbase would
+// delete its ranks too, so a branch that still has ranks is refused with an
+// InUseError instead.
+func (p *PocketbaseAdmin) DeleteBranch(ctx context.Context, id string) error {
+	ranks, err := countRecords(ctx, p, "ranks", Eq("branch", id))
+	if err != nil {
+		return fmt.Errorf("failed to count ranks of branch %s: %w", id, err)
+	}
+	if ranks > 0 {
+		return &InUseError{Collection: "branches", ID: id, Ranks: ranks}
+	}
+
+	err = deleteRecord(ctx, p, "branches", id)
+	if IsNotFound(err) {
+		return fmt.Errorf("no branch found for id: %s", id)
+	}
+	return err
+}
@@ -1,40 +1,22 @@
 package pb
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
-
-	"github.com/arinji2/dasa-bot/network"
 )
 
-func (p *PocketbaseAdmin) GetAllColleges() ([]CollegeCollection, error) {
-	parsedURL, err := url.Parse(p.BaseDomain)
-	if err != nil {
-		return nil, err
-	}
-	parsedURL.Path = "/api/collections/colleges/records"
-
-	params := url.Values{}
-	params.Add("perPage", "10000")
-
-	parsedURL.RawQuery = params.Encode()
-
-	type request struct{}
-	responseBody, err := network.MakeAuthenticatedRequest(parsedURL, "GET", request{}, p.Token)
-	if err != nil {
-		return nil, err
-	}
+func (p *PocketbaseAdmin) GetAllColleges(ctx context.Context) ([]CollegeCollection, error) {
+	return ListAll[CollegeCollection](ctx, p, "colleges", ListOptions{})
+}
 
-	var response PbResponse[CollegeCollection]
-	err = json.Unmarshal(responseBody, &response)
-	if err != nil {
-		return nil, err
-	}
-	return response.Items, nil
+// GetCollegesUpdatedSince returns colleges created or edited at or after since
+func (p *PocketbaseAdmin) GetCollegesUpdatedSince(ctx context.Context, since string) ([]CollegeCollection, error) {
+	return updatedSince[CollegeCollection](ctx, p, "colleges", since, "")
 }
 
-func (p *PocketbaseAdmin) GetCollegeByID(id string) (CollegeCollection, error) {
+func (p *PocketbaseAdmin) GetCollegeByID(ctx context.Context, id string) (CollegeCollection, error) {
 	parsedURL, err := url.Parse(p.BaseDomain)
 	if err != nil {
 		return CollegeCollection{}, err
@@ -42,12 +24,12 @@ func (p *PocketbaseAdmin) GetCollegeByID(id string) (CollegeCollection, error) {
 	parsedURL.Path = "/api/collections/colleges/records"
 
 	params := url.Values{}
-	params.Add("filter", fmt.Sprintf("id='%s'", id))
+	params.Add("filter", Eq("id", id).String())
 
 	parsedURL.RawQuery = params.Encode()
 
 	type request struct{}
-	responseBody, err := network.MakeAuthenticatedRequest(parsedURL, "GET", request{}, p.Token)
+	responseBody, err := p.authenticatedRequest(ctx, parsedURL, "GET", request{})
 	if err != nil {
 		return CollegeCollection{}, err
 	}
@@ -71,3 +53,35 @@ func (p *PocketbaseAdmin) GetCollegeByID(id string) (CollegeCollection, error) {
 
 	return response.Items[0], nil
 }
+
+func (p *PocketbaseAdmin) CreateCollege(ctx context.Context, college CollegeCreateRequest) (CollegeCollection, error) {
+	return createRecord[CollegeCollection](ctx, p, "colleges", college)
+}
+
+// UpdateCollege replaces the name and alias of the college with id
+func (p *PocketbaseAdmin) UpdateCollege(ctx context.Context, id string, college CollegeCreateRequest) (CollegeCollection, error) {
+	updated, err := updateRecord[CollegeCollection](ctx, p, "colleges", id, college)
+	if IsNotFound(err) {
+		return CollegeCollection{}, fmt.Errorf("no college found for id: %s", id)
+	}
+	return updated, err
+}
+
+// DeleteCollege deletes a college along with its aliases. Pocketbase would
+// delete its ranks too, so a college that still has ranks is refused with an
+// InUseError instead.
+func (p *PocketbaseAdmin) DeleteCollege(ctx context.Context, id string) error {
+	ranks, err := countRecords(ctx, p, "ranks", Eq("college", id))
+	if err != nil {
+		return fmt.Errorf("failed to count ranks of college %s: %w", id, err)
+	}
+	if ranks > 0 {
+		return &InUseError{Collection: "colleges", ID: id, Ranks: ranks}
+	}
+
+	err = deleteRecord(ctx, p, "colleges", id)
+	if IsNotFound(err) {
+		return fmt.Errorf("no college found for id: %s", id)
+	}
+	return err
+}
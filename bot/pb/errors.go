@@ -0,0 +1,34 @@
+package pb
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/arinji2/dasa-bot/network"
+)
+
+// ErrRankNotFound is returned when no rank matches a lookup
+var ErrRankNotFound = errors.New("no rank found")
+
+// IsNotFound reports whether Pocketbase answered err with a 404
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+func hasStatus(err error, status int) bool {
+	var apiErr *network.APIError
+	return errors.As(err, &apiErr) && apiErr.Status == status
+}
+
+// InUseError refuses to delete a record ranks still point at, as Pocketbase
+// would delete the ranks along with it
+type InUseError struct {
+	Collection string
+	ID         string
+	Ranks      int
+}
+
+func (e *InUseError) Error() string {
+	return fmt.Sprintf("%s record %s is still used by %d ranks", e.Collection, e.ID, e.Ranks)
+}
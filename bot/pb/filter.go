@@ -0,0 +1,130 @@
+package pb
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter is a Pocketbase filter expression. Values are always escaped through
+// Literal, so user input can never change the shape of the expression.
+type Filter string
+
+// Params are the values bound to {:name} placeholders by Bind
+type Params map[string]any
+
+// pbDateLayout is the format Pocketbase stores and compares datetimes in
+const pbDateLayout = "2006-01-02 15:04:05.000Z"
+
+// ParseTime reads a datetime the way Pocketbase returns it
+func ParseTime(value string) (time.Time, error) {
+	return time.Parse(pbDateLayout, value)
+}
+
+var placeholderRegex = regexp.MustCompile(`\{:(\w+)\}`)
+
+// Eq matches records whose field equals value. Like every comparison it
+// formats value with Literal, so trailing backslashes of a string are dropped:
+// Eq("name", `ranks\`) matches the name ranks.
+func Eq(field string, value any) Filter  { return compare(field, "=", value) }
+func Neq(field string, value any) Filter { return compare(field, "!=", value) }
+func Gt(field string, value any) Filter  { return compare(field, ">", value) }
+func Gte(field string, value any) Filter { return compare(field, ">=", value) }
+func Lt(field string, value any) Filter  { return compare(field, "<", value) }
+func Lte(field string, value any) Filter { return compare(field, "<=", value) }
+
+// Like matches records whose field contains value, ignoring case. Trailing
+// backslashes of a string value are dropped the same as for Eq.
+func Like(field string, value any) Filter    { return compare(field, "~", value) }
+func NotLike(field string, value any) Filter { return compare(field, "!~", value) }
+
+// And joins filters with &&, skipping empty ones
+func And(filters ...Filter) Filter {
+	return join(" && ", filters)
+}
+
+// Or joins filters with ||, skipping empty ones
+func Or(filters ...Filter) Filter {
+	return join(" || ", filters)
+}
+
+// Bind replaces every {:name} placeholder in expr with the escaped value of
+// params[name], the same way the official SDKs build filters.
+// Placeholders without a matching param are left untouched.
+func Bind(expr string, params Params) Filter {
+	return Filter(placeholderRegex.ReplaceAllStringFunc(expr, func(match string) string {
+		name := match[2 : len(match)-1]
+		value, ok := params[name]
+		if !ok {
+			return match
+		}
+		return Literal(value)
+	}))
+}
+
+// Literal formats value as a Pocketbase filter operand. A filter string has no
+// way to end in a backslash, so trailing backslashes of a string are dropped
+// and the operand matches the value without them.
+func Literal(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return quote(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return quote(v.UTC().Format(pbDateLayout))
+	case fmt.Stringer:
+		return quote(v.String())
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return quote(fmt.Sprint(v))
+		}
+		return quote(string(encoded))
+	}
+}
+
+func (f Filter) String() string {
+	return string(f)
+}
+
+func compare(field, operator string, value any) Filter {
+	return Filter(fmt.Sprintf("%s %s %s", field, operator, Literal(value)))
+}
+
+func join(separator string, filters []Filter) Filter {
+	parts := make([]string, 0, len(filters))
+	for _, filter := range filters {
+		if filter == "" {
+			continue
+		}
+		parts = append(parts, string(filter))
+	}
+	if len(parts) == 1 {
+		return Filter(parts[0])
+	}
+	for idx, part := range parts {
+		parts[idx] = "(" + part + ")"
+	}
+	return Filter(strings.Join(parts, separator))
+}
+
+// quote wraps s in single quotes. Pocketbase only treats \' as an escape
+// inside a quoted string, so that is the only sequence that needs escaping.
+// A trailing backslash would escape the closing quote and has no escape of its
+// own, so it is dropped to keep the expression intact.
+func quote(s string) string {
+	s = strings.TrimRight(s, `\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
@@ -0,0 +1,55 @@
+package pb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   string
+	}{
+		{name: "string", filter: Eq("name", "NIT Calicut"), want: `name = 'NIT Calicut'`},
+		{name: "quote", filter: Eq("name", "St. Joseph's"), want: `name = 'St. Joseph\'s'`},
+		{name: "quote cannot close the string", filter: Eq("name", "x' || id != '"), want: `name = 'x\' || id != \''`},
+		{name: "backslash", filter: Eq("name", `C:\ranks`), want: `name = 'C:\ranks'`},
+		{name: "backslash before a quote", filter: Eq("name", `a\'b`), want: `name = 'a\\'b'`},
+		{name: "trailing backslash", filter: Eq("name", `ranks\\`), want: `name = 'ranks'`},
+		{name: "int", filter: Gte("year", 2024), want: `year >= 2024`},
+		{name: "bool", filter: Eq("branch.ciwg", true), want: `branch.ciwg = true`},
+		{name: "nil", filter: Neq("alias", nil), want: `alias != null`},
+		{name: "time", filter: Lt("created", time.Date(2024, 6, 1, 12, 0, 0, 0, time.FixedZone("IST", 19800))), want: `created < '2024-06-01 06:30:00.000Z'`},
+		{name: "like", filter: Like("name", "Calicut"), want: `name ~ 'Calicut'`},
+		// % is a wildcard in Pocketbase and is passed through as one
+		{name: "like with percent", filter: Like("name", "100% Engineering"), want: `name ~ '100% Engineering'`},
+		{name: "not like", filter: NotLike("name", "Institute's"), want: `name !~ 'Institute\'s'`},
+		{name: "and", filter: And(Eq("year", 2024), Eq("round", 1)), want: `(year = 2024) && (round = 1)`},
+		{name: "or", filter: Or(Eq("code", "CS"), Eq("code", "EC")), want: `(code = 'CS') || (code = 'EC')`},
+		{name: "single filter is not wrapped", filter: And(Eq("year", 2024)), want: `year = 2024`},
+		{name: "empty filters are skipped", filter: And("", Eq("year", 2024), ""), want: `year = 2024`},
+		{name: "no filters", filter: Or(), want: ``},
+		{
+			name:   "or inside and",
+			filter: And(Eq("year", 2024), Or(Eq("code", "CS"), Like("name", "O'Neil"))),
+			want:   `(year = 2024) && ((code = 'CS') || (name ~ 'O\'Neil'))`,
+		},
+		{
+			name:   "and inside or",
+			filter: Or(And(Eq("year", 2024), Eq("round", 1)), And(Eq("year", 2023), Eq("round", 6))),
+			want:   `((year = 2024) && (round = 1)) || ((year = 2023) && (round = 6))`,
+		},
+		{name: "bind", filter: Bind("name = {:name} && year = {:year}", Params{"name": "St. Joseph's", "year": 2024}), want: `name = 'St. Joseph\'s' && year = 2024`},
+		{name: "bind reuses a param", filter: Bind("name ~ {:q} || alias ~ {:q}", Params{"q": "50%"}), want: `name ~ '50%' || alias ~ '50%'`},
+		{name: "bind trailing backslash", filter: Bind("name = {:name}", Params{"name": `x\`}), want: `name = 'x'`},
+		{name: "bind leaves unknown placeholders", filter: Bind("name = {:name}", Params{}), want: `name = {:name}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.String(); got != tt.want {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package pb
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+)
+
+// CreateImport records an /insert in the imports collection
+func (p *PocketbaseAdmin) CreateImport(ctx context.Context, record ImportCreateRequest) (ImportCollection, error) {
+	return createRecord[ImportCollection](ctx, p, "imports", record)
+}
+
+// ListImports returns the latest imports, newest first
+func (p *PocketbaseAdmin) ListImports(ctx context.Context, limit int) ([]ImportCollection, error) {
+	response, err := listPage[ImportCollection](ctx, p, "imports", ListOptions{Sort: "-created", PerPage: min(limit, maxPerPage)}, 1)
+	if err != nil {
+		return nil, err
+	}
+	return response.Items, nil
+}
+
+func (p *PocketbaseAdmin) GetImport(ctx context.Context, id string) (ImportCollection, error) {
+	var record ImportCollection
+	parsedURL, err := url.Parse(p.BaseDomain)
+	if err != nil {
+		return record, err
+	}
+	setRecordPath(parsedURL, "imports", id)
+
+	type request struct{}
+	responseBody, err := p.authenticatedRequest(ctx, parsedURL, "GET", request{})
+	if err != nil {
+		return record, err
+	}
+
+	err = json.Unmarshal(responseBody, &record)
+	return record, err
+}
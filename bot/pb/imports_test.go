@@ -0,0 +1,23 @@
+package pb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestGetImportEscapesID(t *testing.T) {
+	admin, requests := newTestAdmin(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"imp/1"}`))
+	})
+	record, err := admin.GetImport(context.Background(), "imp/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.ID != "imp/1" {
+		t.Fatalf("got import %q, want imp/1", record.ID)
+	}
+	if sent := requests(); len(sent) != 1 || sent[0].RawPath != "/api/collections/imports/records/imp%2F1" {
+		t.Fatalf("got requests %+v, want one to /api/collections/imports/records/imp%%2F1", sent)
+	}
+}
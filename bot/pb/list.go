@@ -0,0 +1,173 @@
+package pb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+const (
+	// maxPerPage is the largest page Pocketbase serves by default, asking for more gets silently capped
+	maxPerPage = 1000
+	// listConcurrency bounds how many pages ListAll fetches at once
+	listConcurrency = 4
+)
+
+type ListOptions struct {
+	Filter Filter
+	Sort   string
+	Expand string
+	// Fields limits the returned fields, e.g. "id" when only ids are needed
+	Fields string
+	// PerPage defaults to, and is capped at, maxPerPage
+	PerPage int
+}
+
+// ListAll fetches every record of collection matching opts. The first page is
+// fetched to learn the total, the rest are fetched concurrently and stitched
+// back together in page order, so the result keeps the requested sort.
+func ListAll[T any](ctx context.Context, p *PocketbaseAdmin, collection string, opts ListOptions) ([]T, error) {
+	if opts.PerPage <= 0 || opts.PerPage > maxPerPage {
+		opts.PerPage = maxPerPage
+	}
+
+	first, err := listPage[T](ctx, p, collection, opts, 1)
+	if err != nil {
+		return nil, err
+	}
+	if first.TotalPages <= 1 {
+		return first.Items, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pages := make([][]T, first.TotalPages)
+	pages[0] = first.Items
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	semaphore := make(chan struct{}, listConcurrency)
+
+	for page := 2; page <= first.TotalPages; page++ {
+		wg.Add(1)
+		go func(page int) {
+			defer wg.Done()
+
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				return
+			}
+
+			response, err := listPage[T](ctx, p, collection, opts, page)
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = fmt.Errorf("failed to fetch page %d of %s: %w", page, collection, err)
+					cancel()
+				})
+				return
+			}
+			pages[page-1] = response.Items
+		}(page)
+	}
+
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	allItems := make([]T, 0, first.TotalItems)
+	for _, items := range pages {
+		allItems = append(allItems, items...)
+	}
+	return allItems, nil
+}
+
+// ListRecordIDs returns the id of every record in collection
+func (p *PocketbaseAdmin) ListRecordIDs(ctx context.Context, collection string) ([]string, error) {
+	type record struct {
+		ID string `json:"id"`
+	}
+	records, err := ListAll[record](ctx, p, collection, ListOptions{Fields: "id"})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(records))
+	for idx, r := range records {
+		ids[idx] = r.ID
+	}
+	return ids, nil
+}
+
+// countRecords returns how many records of collection match filter
+func countRecords(ctx context.Context, p *PocketbaseAdmin, collection string, filter Filter) (int, error) {
+	type record struct {
+		ID string `json:"id"`
+	}
+	response, err := listPage[record](ctx, p, collection, ListOptions{Filter: filter, Fields: "id", PerPage: 1}, 1)
+	if err != nil {
+		return 0, err
+	}
+	return response.TotalItems, nil
+}
+
+// updatedSince lists the records of collection changed at or after since, oldest first.
+// Records stamped with exactly since are included again as the timestamp is not unique.
+func updatedSince[T any](ctx context.Context, p *PocketbaseAdmin, collection string, since string, expand string) ([]T, error) {
+	return ListAll[T](ctx, p, collection, ListOptions{
+		Filter: Gte("updated", since),
+		Sort:   "updated",
+		Expand: expand,
+	})
+}
+
+func listPage[T any](ctx context.Context, p *PocketbaseAdmin, collection string, opts ListOptions, page int) (PbResponse[T], error) {
+	parsedURL, err := url.Parse(p.BaseDomain)
+	if err != nil {
+		return PbResponse[T]{}, err
+	}
+	parsedURL.Path = fmt.Sprintf("/api/collections/%s/records", collection)
+
+	params := url.Values{}
+	params.Add("page", strconv.Itoa(page))
+	params.Add("perPage", strconv.Itoa(opts.PerPage))
+	if opts.Filter != "" {
+		params.Add("filter", opts.Filter.String())
+	}
+	if opts.Sort != "" {
+		params.Add("sort", opts.Sort)
+	}
+	if opts.Expand != "" {
+		params.Add("expand", opts.Expand)
+	}
+	if opts.Fields != "" {
+		params.Add("fields", opts.Fields)
+	}
+
+	parsedURL.RawQuery = params.Encode()
+
+	type request struct{}
+	responseBody, err := p.authenticatedRequest(ctx, parsedURL, "GET", request{})
+	if err != nil {
+		return PbResponse[T]{}, err
+	}
+
+	var response PbResponse[T]
+	err = json.Unmarshal(responseBody, &response)
+	if err != nil {
+		return PbResponse[T]{}, err
+	}
+	return response, nil
+}
@@ -0,0 +1,83 @@
+package pb
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestListAll(t *testing.T) {
+	const totalPages = 8
+	tests := []struct {
+		name string
+		// failPage answers with an error, 0 for none
+		failPage int
+		perPage  int
+		wantErr  bool
+	}{
+		{name: "pages kept in order"},
+		{name: "page size capped", perPage: 5000},
+		{name: "failed page", failPage: 5, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			var inFlight, maxInFlight atomic.Int32
+			admin, requests := newTestAdmin(t, func(w http.ResponseWriter, r *http.Request) {
+				current := inFlight.Add(1)
+				defer inFlight.Add(-1)
+				for {
+					seen := maxInFlight.Load()
+					if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+						break
+					}
+				}
+
+				if perPage := r.URL.Query().Get("perPage"); perPage != strconv.Itoa(maxPerPage) {
+					t.Errorf("got perPage %s, want %d", perPage, maxPerPage)
+				}
+				page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+				if page == tt.failPage {
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte(`{"status":400,"message":"Something went wrong."}`))
+					return
+				}
+				// later pages answer first, so they finish out of order
+				time.Sleep(time.Duration(totalPages-page) * 5 * time.Millisecond)
+				fmt.Fprintf(w, `{"page":%d,"totalPages":%d,"totalItems":%d,"items":[{"id":"p%d-a"},{"id":"p%d-b"}]}`,
+					page, totalPages, totalPages*2, page, page)
+			})
+
+			type record struct {
+				ID string `json:"id"`
+			}
+			got, err := ListAll[record](ctx, admin, "ranks", ListOptions{Sort: "updated", PerPage: tt.perPage})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var want []record
+			for page := 1; page <= totalPages; page++ {
+				want = append(want, record{fmt.Sprintf("p%d-a", page)}, record{fmt.Sprintf("p%d-b", page)})
+			}
+			if !slices.Equal(got, want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			if len(requests()) != totalPages {
+				t.Fatalf("got %d requests, want one per page", len(requests()))
+			}
+			// the first page is fetched alone, the rest at most listConcurrency at a time
+			if peak := maxInFlight.Load(); peak < 2 || peak > listConcurrency {
+				t.Fatalf("got %d pages fetched at once, want between 2 and %d", peak, listConcurrency)
+			}
+		})
+	}
+}
@@ -0,0 +1,1123 @@
+package pb
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arinji2/dasa-bot/network"
+)
+
+const (
+	memoryIDAlphabet  = "abcdefghijklmnopqrstuvwxyz0123456789"
+	memoryIDLength    = 15
+	memoryDefaultPage = 1000
+)
+
+var (
+	missingRelation = network.FieldError{
+		Code:    "validation_missing_rel_records",
+		Message: "Failed to find all relation records with the provided ids.",
+	}
+	notUnique = network.FieldError{
+		Code:    "validation_not_unique",
+		Message: "Value must be unique.",
+	}
+)
+
+// MemoryStore is an in-memory Store. It mirrors the filtering, pagination and
+// uniqueness rules of the Pocketbase collections so that commands can be
+// exercised offline.
+type MemoryStore struct {
+	// PerPage is the page size used when listing whole collections.
+	PerPage int
+
+	mu       sync.RWMutex
+	colleges []CollegeCollection
+	branches []BranchCollection
+	ranks    []RankCollection
+	backups  []BackupCollection
+
+	collegeAliases []CollegeAliasCollection
+	branchAliases  []BranchAliasCollection
+	imports        []ImportCollection
+	// backupData holds the records each backup was taken of
+	backupData map[string]memoryBackup
+}
+
+type memoryBackup struct {
+	colleges       []CollegeCollection
+	branches       []BranchCollection
+	ranks          []RankCollection
+	collegeAliases []CollegeAliasCollection
+	branchAliases  []BranchAliasCollection
+	imports        []ImportCollection
+	// archive is what downloading the backup returns
+	archive []byte
+}
+
+func NewMemoryStore(colleges []CollegeCollection, branches []BranchCollection, ranks []RankCollection) *MemoryStore {
+	m := &MemoryStore{
+		PerPage:  memoryDefaultPage,
+		colleges: slices.Clone(colleges),
+		branches: slices.Clone(branches),
+	}
+	for _, rank := range ranks {
+		m.ranks = append(m.ranks, m.expandRank(rank))
+	}
+	return m
+}
+
+func (m *MemoryStore) GetAllColleges(_ context.Context) ([]CollegeCollection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return listAllPages(m.colleges, m.perPage()), nil
+}
+
+func (m *MemoryStore) GetCollegeByID(_ context.Context, id string) (CollegeCollection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, college := range m.colleges {
+		if college.ID == id {
+			return college, nil
+		}
+	}
+	return CollegeCollection{}, fmt.Errorf("no college found for id: %s", id)
+}
+
+func (m *MemoryStore) GetCollegesUpdatedSince(_ context.Context, since string) ([]CollegeCollection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return memoryUpdatedSince(m.colleges, since, func(c CollegeCollection) string { return c.Updated }), nil
+}
+
+func (m *MemoryStore) CreateColleges(_ context.Context, colleges []CollegeCreateRequest) ([]CollegeCollection, error) {
+	if err := checkBatchSize(len(colleges)); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ids := make([]string, len(colleges))
+	for idx, college := range colleges {
+		ids[idx] = college.ID
+	}
+	if err := m.checkNewIDs(ids); err != nil {
+		return nil, err
+	}
+	created := make([]CollegeCollection, len(colleges))
+	for idx, college := range colleges {
+		created[idx] = CollegeCollection{
+			ID:      m.idOrNew(college.ID),
+			Name:    college.Name,
+			Alias:   college.Alias,
+			Updated: memoryNow(),
+		}
+		m.colleges = append(m.colleges, created[idx])
+	}
+	return created, nil
+}
+
+func (m *MemoryStore) CreateCollege(_ context.Context, college CollegeCreateRequest) (CollegeCollection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if college.ID != "" && m.idExists(college.ID) {
+		return CollegeCollection{}, &network.APIError{
+			Status:  http.StatusBadRequest,
+			Message: "Failed to create record.",
+			Data:    map[string]network.FieldError{"id": notUnique},
+		}
+	}
+	created := CollegeCollection{
+		ID:      m.idOrNew(college.ID),
+		Name:    college.Name,
+		Alias:   college.Alias,
+		Updated: memoryNow(),
+	}
+	m.colleges = append(m.colleges, created)
+	return created, nil
+}
+
+func (m *MemoryStore) UpdateCollege(_ context.Context, id string, college CollegeCreateRequest) (CollegeCollection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	idx := slices.IndexFunc(m.colleges, func(c CollegeCollection) bool { return c.ID == id })
+	if idx < 0 {
+		return CollegeCollection{}, fmt.Errorf("no college found for id: %s", id)
+	}
+	stored := &m.colleges[idx]
+	stored.Name = college.Name
+	stored.Alias = college.Alias
+	stored.Updated = memoryNow()
+	// ranks carry an expanded copy of their college
+	for rankIdx := range m.ranks {
+		if m.ranks[rankIdx].College == id {
+			m.ranks[rankIdx].Expand.College = *stored
+		}
+	}
+	return *stored, nil
+}
+
+func (m *MemoryStore) DeleteCollege(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !slices.ContainsFunc(m.colleges, func(c CollegeCollection) bool { return c.ID == id }) {
+		return fmt.Errorf("no college found for id: %s", id)
+	}
+	ranks := 0
+	for _, rank := range m.ranks {
+		if rank.College == id {
+			ranks++
+		}
+	}
+	if ranks > 0 {
+		return &InUseError{Collection: "colleges", ID: id, Ranks: ranks}
+	}
+
+	m.colleges = slices.DeleteFunc(m.colleges, func(c CollegeCollection) bool { return c.ID == id })
+	m.collegeAliases = slices.DeleteFunc(m.collegeAliases, func(a CollegeAliasCollection) bool { return a.College == id })
+	return nil
+}
+
+func (m *MemoryStore) GetAllCollegeAliases(_ context.Context) ([]CollegeAliasCollection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return listAllPages(m.collegeAliases, m.perPage()), nil
+}
+
+func (m *MemoryStore) GetCollegeAliasesUpdatedSince(_ context.Context, since string) ([]CollegeAliasCollection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return memoryUpdatedSince(m.collegeAliases, since, func(a CollegeAliasCollection) string { return a.Updated }), nil
+}
+
+func (m *MemoryStore) CreateCollegeAlias(_ context.Context, alias CollegeAliasCreateRequest) (CollegeAliasCollection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	invalid := map[string]network.FieldError{}
+	if !slices.ContainsFunc(m.colleges, func(c CollegeCollection) bool { return c.ID == alias.College }) {
+		invalid["college"] = missingRelation
+	}
+	if slices.ContainsFunc(m.collegeAliases, func(a CollegeAliasCollection) bool { return strings.EqualFold(a.Name, alias.Name) }) {
+		invalid["name"] = notUnique
+	}
+	if alias.ID != "" && m.idExists(alias.ID) {
+		invalid["id"] = notUnique
+	}
+	if len(invalid) > 0 {
+		return CollegeAliasCollection{}, &network.APIError{
+			Status:  http.StatusBadRequest,
+			Message: "Failed to create record.",
+			Data:    invalid,
+		}
+	}
+
+	created := CollegeAliasCollection{
+		ID:      m.idOrNew(alias.ID),
+		Name:    alias.Name,
+		College: alias.College,
+		Updated: memoryNow(),
+	}
+	m.collegeAliases = append(m.collegeAliases, created)
+	return created, nil
+}
+
+func (m *MemoryStore) UpdateCollegeAlias(_ context.Context, id string, alias CollegeAliasCreateRequest) (CollegeAliasCollection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	idx := slices.IndexFunc(m.collegeAliases, func(a CollegeAliasCollection) bool { return a.ID == id })
+	if idx < 0 {
+		return CollegeAliasCollection{}, &network.APIError{Status: http.StatusNotFound, Message: "The requested resource wasn't found."}
+	}
+	invalid := map[string]network.FieldError{}
+	if !slices.ContainsFunc(m.colleges, func(c CollegeCollection) bool { return c.ID == alias.College }) {
+		invalid["college"] = missingRelation
+	}
+	if slices.ContainsFunc(m.collegeAliases, func(a CollegeAliasCollection) bool { return a.ID != id && strings.EqualFold(a.Name, alias.Name) }) {
+		invalid["name"] = notUnique
+	}
+	if len(invalid) > 0 {
+		return CollegeAliasCollection{}, &network.APIError{
+			Status:  http.StatusBadRequest,
+			Message: "Failed to update record.",
+			Data:    invalid,
+		}
+	}
+
+	stored := &m.collegeAliases[idx]
+	stored.Name = alias.Name
+	stored.College = alias.College
+	stored.Updated = memoryNow()
+	return *stored, nil
+}
+
+func (m *MemoryStore) GetAllBranches(_ context.Context) ([]BranchCollection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return listAllPages(m.branches, m.perPage()), nil
+}
+
+func (m *MemoryStore) GetBranchByCode(_ context.Context, code string) (BranchCollection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, branch := range m.branches {
+		if branch.Code == code {
+			return branch, nil
+		}
+	}
+	return BranchCollection{}, fmt.Errorf("no branch found for code: %s", code)
+}
+
+func (m *MemoryStore) GetBranchesUpdatedSince(_ context.Context, since string) ([]BranchCollection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return memoryUpdatedSince(m.branches, since, func(b BranchCollection) string { return b.Updated }), nil
+}
+
+func (m *MemoryStore) CreateBranch(_ context.Context, branch BranchCreateRequest) (BranchCollection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if branch.ID != "" && m.idExists(branch.ID) {
+		return BranchCollection{}, &network.APIError{
+			Status:  http.StatusBadRequest,
+			Message: "Failed to create record.",
+			Data:    map[string]network.FieldError{"id": notUnique},
+		}
+	}
+	created := BranchCollection{
+		ID:      m.idOrNew(branch.ID),
+		Name:    branch.Name,
+		Code:    branch.Code,
+		Ciwg:    branch.Ciwg,
+		Updated: memoryNow(),
+	}
+	m.branches = append(m.branches, created)
+	return created, nil
+}
+
+func (m *MemoryStore) CreateBranches(_ context.Context, branches []BranchCreateRequest) ([]BranchCollection, error) {
+	if err := checkBatchSize(len(branches)); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ids := make([]string, len(branches))
+	for idx, branch := range branches {
+		ids[idx] = branch.ID
+	}
+	if err := m.checkNewIDs(ids); err != nil {
+		return nil, err
+	}
+	created := make([]BranchCollection, len(branches))
+	for idx, branch := range branches {
+		created[idx] = BranchCollection{
+			ID:      m.idOrNew(branch.ID),
+			Name:    branch.Name,
+			Code:    branch.Code,
+			Ciwg:    branch.Ciwg,
+			Updated: memoryNow(),
+		}
+		m.branches = append(m.branches, created[idx])
+	}
+	return created, nil
+}
+
+func (m *MemoryStore) UpdateBranch(_ context.Context, id string, branch BranchCreateRequest) (BranchCollection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	idx := slices.IndexFunc(m.branches, func(b BranchCollection) bool { return b.ID == id })
+	if idx < 0 {
+		return BranchCollection{}, fmt.Errorf("no branch found for id: %s", id)
+	}
+	stored := &m.branches[idx]
+	stored.Name = branch.Name
+	stored.Code = branch.Code
+	stored.Ciwg = branch.Ciwg
+	stored.Updated = memoryNow()
+	// ranks carry an expanded copy of their branch
+	for rankIdx := range m.ranks {
+		if m.ranks[rankIdx].Branch == id {
+			m.ranks[rankIdx].Expand.Branch = *stored
+		}
+	}
+	return *stored, nil
+}
+
+func (m *MemoryStore) DeleteBranch(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !slices.ContainsFunc(m.branches, func(b BranchCollection) bool { return b.ID == id }) {
+		return fmt.Errorf("no branch found for id: %s", id)
+	}
+	ranks := 0
+	for _, rank := range m.ranks {
+		if rank.Branch == id {
+			ranks++
+		}
+	}
+	if ranks > 0 {
+		return &InUseError{Collection: "branches", ID: id, Ranks: ranks}
+	}
+
+	m.branches = slices.DeleteFunc(m.branches, func(b BranchCollection) bool { return b.ID == id })
+	m.branchAliases = slices.DeleteFunc(m.branchAliases, func(a BranchAliasCollection) bool { return a.Branch == id })
+	return nil
+}
+
+func (m *MemoryStore) GetAllBranchAliases(_ context.Context) ([]BranchAliasCollection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return listAllPages(m.branchAliases, m.perPage()), nil
+}
+
+func (m *MemoryStore) GetBranchAliasesUpdatedSince(_ context.Context, since string) ([]BranchAliasCollection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return memoryUpdatedSince(m.branchAliases, since, func(a BranchAliasCollection) string { return a.Updated }), nil
+}
+
+func (m *MemoryStore) CreateBranchAlias(_ context.Context, alias BranchAliasCreateRequest) (BranchAliasCollection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	invalid := map[string]network.FieldError{}
+	if !slices.ContainsFunc(m.branches, func(b BranchCollection) bool { return b.ID == alias.Branch }) {
+		invalid["branch"] = missingRelation
+	}
+	if slices.ContainsFunc(m.branchAliases, func(a BranchAliasCollection) bool {
+		return strings.EqualFold(a.Name, alias.Name) && strings.EqualFold(a.Code, alias.Code) && a.Branch == alias.Branch
+	}) {
+		invalid["name"] = notUnique
+	}
+	if alias.ID != "" && m.idExists(alias.ID) {
+		invalid["id"] = notUnique
+	}
+	if len(invalid) > 0 {
+		return BranchAliasCollection{}, &network.APIError{
+			Status:  http.StatusBadRequest,
+			Message: "Failed to create record.",
+			Data:    invalid,
+		}
+	}
+
+	created := BranchAliasCollection{
+		ID:      m.idOrNew(alias.ID),
+		Name:    alias.Name,
+		Code:    alias.Code,
+		Branch:  alias.Branch,
+		Updated: memoryNow(),
+	}
+	m.branchAliases = append(m.branchAliases, created)
+	return created, nil
+}
+
+func (m *MemoryStore) UpdateBranchAlias(_ context.Context, id string, alias BranchAliasCreateRequest) (BranchAliasCollection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	idx := slices.IndexFunc(m.branchAliases, func(a BranchAliasCollection) bool { return a.ID == id })
+	if idx < 0 {
+		return BranchAliasCollection{}, &network.APIError{Status: http.StatusNotFound, Message: "The requested resource wasn't found."}
+	}
+	invalid := map[string]network.FieldError{}
+	if !slices.ContainsFunc(m.branches, func(b BranchCollection) bool { return b.ID == alias.Branch }) {
+		invalid["branch"] = missingRelation
+	}
+	if slices.ContainsFunc(m.branchAliases, func(a BranchAliasCollection) bool {
+		return a.ID != id && strings.EqualFold(a.Name, alias.Name) && strings.EqualFold(a.Code, alias.Code) && a.Branch == alias.Branch
+	}) {
+		invalid["name"] = notUnique
+	}
+	if len(invalid) > 0 {
+		return BranchAliasCollection{}, &network.APIError{
+			Status:  http.StatusBadRequest,
+			Message: "Failed to update record.",
+			Data:    invalid,
+		}
+	}
+
+	stored := &m.branchAliases[idx]
+	stored.Name = alias.Name
+	stored.Code = alias.Code
+	stored.Branch = alias.Branch
+	stored.Updated = memoryNow()
+	return *stored, nil
+}
+
+func (m *MemoryStore) GetAllRanks(_ context.Context) ([]RankCollection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	sorted := slices.Clone(m.ranks)
+	slices.SortStableFunc(sorted, func(a, b RankCollection) int {
+		if a.Year != b.Year {
+			return b.Year - a.Year
+		}
+		return b.Round - a.Round
+	})
+	return listAllPages(sorted, m.perPage()), nil
+}
+
+func (m *MemoryStore) GetRanksUpdatedSince(_ context.Context, since string) ([]RankCollection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return memoryUpdatedSince(m.ranks, since, func(r RankCollection) string { return r.Updated }), nil
+}
+
+// GetSpecificRank by Year and Round for a College and Branch
+func (m *MemoryStore) GetSpecificRank(_ context.Context, college string, branch string, year int, round int, ciwg bool) (RankCollection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, rank := range m.ranks {
+		if rank.Year == year && rank.Round == round && rank.College == college &&
+			rank.Expand.Branch.Code == branch && rank.Expand.Branch.Ciwg == ciwg {
+			return rank, nil
+		}
+	}
+	return RankCollection{}, fmt.Errorf("%w for year: %d, round: %d, college: %s, branch: %s", ErrRankNotFound, year, round, college, branch)
+}
+
+func (m *MemoryStore) GetSpecificRankWithBranchID(_ context.Context, college string, branch string, year int, round int, ciwg bool) (RankCollection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	rank, found := m.findRank(college, branch, year, round, ciwg)
+	if !found {
+		return RankCollection{}, fmt.Errorf("%w for year: %d, round: %d, college: %s, branch: %s", ErrRankNotFound, year, round, college, branch)
+	}
+	return rank, nil
+}
+
+// GetRanksByCollegeBranch for a College and Branch
+func (m *MemoryStore) GetRanksByCollegeBranch(_ context.Context, college string, branch string) ([]RankCollection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var ranks []RankCollection
+	for _, rank := range m.ranks {
+		// Pocketbase's ~ operator is a case-insensitive contains
+		if strings.Contains(strings.ToLower(rank.Expand.College.Alias), strings.ToLower(college)) &&
+			rank.Expand.Branch.Code == branch {
+			ranks = append(ranks, rank)
+		}
+	}
+	return ranks, nil
+}
+
+// GetRanksByYearAndRound for a Year and Round
+func (m *MemoryStore) GetRanksByYearAndRound(_ context.Context, year int, round int) ([]RankCollection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var ranks []RankCollection
+	for _, rank := range m.ranks {
+		if rank.Year == year && rank.Round == round {
+			ranks = append(ranks, rank)
+		}
+	}
+	return ranks, nil
+}
+
+func (m *MemoryStore) CreateRank(_ context.Context, rank RankCreateRequest, ciwg bool) (RankCollection, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, found := m.findRank(rank.College, rank.Branch, rank.Year, rank.Round, ciwg); found {
+		return RankCollection{}, true, fmt.Errorf("rank already exists")
+	}
+
+	created := m.expandRank(RankCollection{
+		ID:       m.newID(),
+		Year:     rank.Year,
+		Round:    rank.Round,
+		JeeOpen:  rank.JeeOpen,
+		JeeClose: rank.JeeClose,
+		College:  rank.College,
+		Branch:   rank.Branch,
+		Updated:  memoryNow(),
+	})
+	// mirror the validation error Pocketbase returns for a missing relation
+	invalid := map[string]network.FieldError{}
+	if created.Expand.College.ID == "" {
+		invalid["college"] = missingRelation
+	}
+	if created.Expand.Branch.ID == "" {
+		invalid["branch"] = missingRelation
+	}
+	if len(invalid) > 0 {
+		return RankCollection{}, false, &network.APIError{
+			Status:  http.StatusBadRequest,
+			Message: "Failed to create record.",
+			Data:    invalid,
+		}
+	}
+
+	m.ranks = append(m.ranks, created)
+	return created, false, nil
+}
+
+func (m *MemoryStore) CreateRanks(_ context.Context, ranks []RankCreateRequest) ([]RankCollection, error) {
+	if err := checkBatchSize(len(ranks)); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// validate everything first, a failed batch writes nothing
+	ids := make([]string, len(ranks))
+	for idx, rank := range ranks {
+		ids[idx] = rank.ID
+	}
+	if err := m.checkNewIDs(ids); err != nil {
+		return nil, err
+	}
+	for idx, rank := range ranks {
+		expanded := m.expandRank(RankCollection{College: rank.College, Branch: rank.Branch})
+		invalid := map[string]network.FieldError{}
+		if expanded.Expand.College.ID == "" {
+			invalid["college"] = missingRelation
+		}
+		if expanded.Expand.Branch.ID == "" {
+			invalid["branch"] = missingRelation
+		}
+		if len(invalid) > 0 {
+			return nil, &BatchError{Index: idx, Err: &network.APIError{
+				Status:  http.StatusBadRequest,
+				Message: "Failed to create record.",
+				Data:    invalid,
+			}}
+		}
+	}
+
+	created := make([]RankCollection, len(ranks))
+	for idx, rank := range ranks {
+		id := rank.ID
+		if id == "" {
+			id = m.newID()
+		}
+		created[idx] = m.expandRank(RankCollection{
+			ID:       id,
+			Year:     rank.Year,
+			Round:    rank.Round,
+			JeeOpen:  rank.JeeOpen,
+			JeeClose: rank.JeeClose,
+			College:  rank.College,
+			Branch:   rank.Branch,
+			Updated:  memoryNow(),
+		})
+		m.ranks = append(m.ranks, created[idx])
+	}
+	return created, nil
+}
+
+func (m *MemoryStore) UpdateRanks(_ context.Context, ranks []RankUpdateRequest) ([]RankCollection, error) {
+	if err := checkBatchSize(len(ranks)); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	positions := make([]int, len(ranks))
+	for idx, rank := range ranks {
+		positions[idx] = slices.IndexFunc(m.ranks, func(r RankCollection) bool { return r.ID == rank.ID })
+		if positions[idx] < 0 {
+			return nil, &BatchError{Index: idx, Err: &network.APIError{
+				Status:  http.StatusNotFound,
+				Message: "The requested resource wasn't found.",
+			}}
+		}
+	}
+
+	updated := make([]RankCollection, len(ranks))
+	for idx, rank := range ranks {
+		stored := &m.ranks[positions[idx]]
+		stored.JeeOpen = rank.JeeOpen
+		stored.JeeClose = rank.JeeClose
+		stored.Updated = memoryNow()
+		updated[idx] = *stored
+	}
+	return updated, nil
+}
+
+func (m *MemoryStore) MoveRanks(_ context.Context, ranks []RankMoveRequest) ([]RankCollection, error) {
+	if err := checkBatchSize(len(ranks)); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	positions := make([]int, len(ranks))
+	for idx, rank := range ranks {
+		positions[idx] = slices.IndexFunc(m.ranks, func(r RankCollection) bool { return r.ID == rank.ID })
+		if positions[idx] < 0 {
+			return nil, &BatchError{Index: idx, Err: &network.APIError{
+				Status:  http.StatusNotFound,
+				Message: "The requested resource wasn't found.",
+			}}
+		}
+		moved := m.ranks[positions[idx]]
+		if rank.College != "" {
+			moved.College = rank.College
+		}
+		if rank.Branch != "" {
+			moved.Branch = rank.Branch
+		}
+		moved.Expand.College, moved.Expand.Branch = CollegeCollection{}, BranchCollection{}
+		moved = m.expandRank(moved)
+		invalid := map[string]network.FieldError{}
+		if moved.Expand.College.ID == "" {
+			invalid["college"] = missingRelation
+		}
+		if moved.Expand.Branch.ID == "" {
+			invalid["branch"] = missingRelation
+		}
+		if len(invalid) > 0 {
+			return nil, &BatchError{Index: idx, Err: &network.APIError{
+				Status:  http.StatusBadRequest,
+				Message: "Failed to update record.",
+				Data:    invalid,
+			}}
+		}
+	}
+
+	moved := make([]RankCollection, len(ranks))
+	for idx, rank := range ranks {
+		stored := &m.ranks[positions[idx]]
+		if rank.College != "" {
+			stored.College = rank.College
+		}
+		if rank.Branch != "" {
+			stored.Branch = rank.Branch
+		}
+		stored.Updated = memoryNow()
+		stored.Expand.College, stored.Expand.Branch = CollegeCollection{}, BranchCollection{}
+		*stored = m.expandRank(*stored)
+		moved[idx] = *stored
+	}
+	return moved, nil
+}
+
+func (m *MemoryStore) CreateImport(_ context.Context, record ImportCreateRequest) (ImportCollection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if record.User == "" {
+		return ImportCollection{}, &network.APIError{
+			Status:  http.StatusBadRequest,
+			Message: "Failed to create record.",
+			Data: map[string]network.FieldError{
+				"user": {Code: "validation_required", Message: "Cannot be blank."},
+			},
+		}
+	}
+
+	created := ImportCollection{
+		ID:           m.newID(),
+		User:         record.User,
+		UserID:       record.UserID,
+		FileName:     record.FileName,
+		FileHash:     record.FileHash,
+		Year:         record.Year,
+		Round:        record.Round,
+		Rounds:       record.Rounds,
+		Mode:         record.Mode,
+		Status:       record.Status,
+		RanksCreated: record.RanksCreated,
+		RanksUpdated: record.RanksUpdated,
+		RanksDeleted: record.RanksDeleted,
+		RanksSkipped: record.RanksSkipped,
+		RanksFailed:  record.RanksFailed,
+		NewBranches:  slices.Clone(record.NewBranches),
+		NewColleges:  slices.Clone(record.NewColleges),
+		BackupKey:    record.BackupKey,
+		DurationMs:   record.DurationMs,
+		Error:        record.Error,
+		Created:      memoryNow(),
+	}
+	m.imports = append(m.imports, created)
+	return created, nil
+}
+
+func (m *MemoryStore) ListImports(_ context.Context, limit int) ([]ImportCollection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	// records are appended in the order they were created
+	latest := slices.Clone(m.imports)
+	slices.Reverse(latest)
+	return latest[:min(limit, len(latest))], nil
+}
+
+func (m *MemoryStore) GetImport(_ context.Context, id string) (ImportCollection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, record := range m.imports {
+		if record.ID == id {
+			return record, nil
+		}
+	}
+	return ImportCollection{}, &network.APIError{Status: http.StatusNotFound, Message: "The requested resource wasn't found."}
+}
+
+func (m *MemoryStore) ListRecordIDs(_ context.Context, collection string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var ids []string
+	switch collection {
+	case "colleges":
+		for _, college := range m.colleges {
+			ids = append(ids, college.ID)
+		}
+	case "branches":
+		for _, branch := range m.branches {
+			ids = append(ids, branch.ID)
+		}
+	case "ranks":
+		for _, rank := range m.ranks {
+			ids = append(ids, rank.ID)
+		}
+	case "college_aliases":
+		for _, alias := range m.collegeAliases {
+			ids = append(ids, alias.ID)
+		}
+	case "branch_aliases":
+		for _, alias := range m.branchAliases {
+			ids = append(ids, alias.ID)
+		}
+	case "imports":
+		for _, record := range m.imports {
+			ids = append(ids, record.ID)
+		}
+	default:
+		return nil, fmt.Errorf("unknown collection: %s", collection)
+	}
+	return ids, nil
+}
+
+func (m *MemoryStore) DeleteRecords(_ context.Context, collection string, ids []string) error {
+	if err := checkBatchSize(len(ids)); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var err error
+	switch collection {
+	case "colleges":
+		m.colleges, err = deleteByID(m.colleges, ids, func(c CollegeCollection) string { return c.ID })
+	case "branches":
+		m.branches, err = deleteByID(m.branches, ids, func(b BranchCollection) string { return b.ID })
+	case "ranks":
+		m.ranks, err = deleteByID(m.ranks, ids, func(r RankCollection) string { return r.ID })
+	case "college_aliases":
+		m.collegeAliases, err = deleteByID(m.collegeAliases, ids, func(a CollegeAliasCollection) string { return a.ID })
+	case "branch_aliases":
+		m.branchAliases, err = deleteByID(m.branchAliases, ids, func(a BranchAliasCollection) string { return a.ID })
+	case "imports":
+		m.imports, err = deleteByID(m.imports, ids, func(i ImportCollection) string { return i.ID })
+	default:
+		return fmt.Errorf("unknown collection: %s", collection)
+	}
+	return err
+}
+
+func (m *MemoryStore) ListBackups(_ context.Context) ([]BackupCollection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(m.backups), nil
+}
+
+func (m *MemoryStore) DeleteBackup(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for idx, backup := range m.backups {
+		if backup.Key == key {
+			m.backups = slices.Delete(m.backups, idx, idx+1)
+			delete(m.backupData, key)
+			return nil
+		}
+	}
+	return fmt.Errorf("no backup found for key: %s", key)
+}
+
+func (m *MemoryStore) CreateBackup(_ context.Context, userName string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	backupName := fmt.Sprintf("%s_%s.zip", sanitizeUsername(userName), now.Format("02_01_2006_15_04_05"))
+	for _, backup := range m.backups {
+		if backup.Key == backupName {
+			return "", fmt.Errorf("backup %s already exists", backupName)
+		}
+	}
+	data := memoryBackup{
+		colleges:       slices.Clone(m.colleges),
+		branches:       slices.Clone(m.branches),
+		ranks:          slices.Clone(m.ranks),
+		collegeAliases: slices.Clone(m.collegeAliases),
+		branchAliases:  slices.Clone(m.branchAliases),
+		imports:        slices.Clone(m.imports),
+	}
+	archive, err := data.zip()
+	if err != nil {
+		return "", fmt.Errorf("failed to archive backup: %w", err)
+	}
+	data.archive = archive
+
+	m.backups = append(m.backups, BackupCollection{
+		Key:      backupName,
+		Size:     int64(len(archive)),
+		Modified: now.UTC().Format(pbDateLayout),
+	})
+	if m.backupData == nil {
+		m.backupData = make(map[string]memoryBackup)
+	}
+	m.backupData[backupName] = data
+	return backupName, nil
+}
+
+func (m *MemoryStore) DownloadBackup(_ context.Context, key string) (io.ReadCloser, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	data, ok := m.backupData[key]
+	if !ok {
+		return nil, fmt.Errorf("no backup found for key: %s", key)
+	}
+	return io.NopCloser(bytes.NewReader(data.archive)), nil
+}
+
+// zip stores every collection of the backup as a JSON file, standing in for
+// the database Pocketbase would archive
+func (b memoryBackup) zip() ([]byte, error) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	collections := []struct {
+		name    string
+		records any
+	}{
+		{"colleges", b.colleges},
+		{"branches", b.branches},
+		{"ranks", b.ranks},
+		{"college_aliases", b.collegeAliases},
+		{"branch_aliases", b.branchAliases},
+		{"imports", b.imports},
+	}
+	for _, collection := range collections {
+		f, err := w.Create(collection.name + ".json")
+		if err != nil {
+			return nil, err
+		}
+		if err := json.NewEncoder(f).Encode(collection.records); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *MemoryStore) RestoreBackup(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.backupData[key]
+	if !ok {
+		return fmt.Errorf("no backup found for key: %s", key)
+	}
+	m.colleges = slices.Clone(data.colleges)
+	m.branches = slices.Clone(data.branches)
+	m.ranks = slices.Clone(data.ranks)
+	m.collegeAliases = slices.Clone(data.collegeAliases)
+	m.branchAliases = slices.Clone(data.branchAliases)
+	m.imports = slices.Clone(data.imports)
+	return nil
+}
+
+// findRank applies the same uniqueness key CreateRank enforces against Pocketbase.
+// Callers must hold m.mu.
+func (m *MemoryStore) findRank(college, branch string, year, round int, ciwg bool) (RankCollection, bool) {
+	for _, rank := range m.ranks {
+		if rank.Year == year && rank.Round == round && rank.College == college &&
+			rank.Branch == branch && rank.Expand.Branch.Ciwg == ciwg {
+			return rank, true
+		}
+	}
+	return RankCollection{}, false
+}
+
+// expandRank fills in the college and branch relations the same way
+// expand=college,branch does. Callers must hold m.mu.
+func (m *MemoryStore) expandRank(rank RankCollection) RankCollection {
+	for _, college := range m.colleges {
+		if college.ID == rank.College {
+			rank.Expand.College = college
+			break
+		}
+	}
+	for _, branch := range m.branches {
+		if branch.ID == rank.Branch {
+			rank.Expand.Branch = branch
+			break
+		}
+	}
+	return rank
+}
+
+func (m *MemoryStore) perPage() int {
+	if m.PerPage <= 0 {
+		return memoryDefaultPage
+	}
+	return m.PerPage
+}
+
+// newID generates a Pocketbase style record id. Callers must hold m.mu.
+func (m *MemoryStore) newID() string {
+	for {
+		id := make([]byte, memoryIDLength)
+		for idx := range id {
+			id[idx] = memoryIDAlphabet[rand.IntN(len(memoryIDAlphabet))]
+		}
+		if !m.idExists(string(id)) {
+			return string(id)
+		}
+	}
+}
+
+// idOrNew keeps an id the caller asked for and generates one otherwise. Callers must hold m.mu.
+func (m *MemoryStore) idOrNew(id string) string {
+	if id != "" {
+		return id
+	}
+	return m.newID()
+}
+
+// checkNewIDs rejects a batch with an id that is taken or given twice, as the
+// primary key would. Empty ids are generated later. Callers must hold m.mu.
+func (m *MemoryStore) checkNewIDs(ids []string) error {
+	seen := make(map[string]bool, len(ids))
+	for idx, id := range ids {
+		if id == "" {
+			continue
+		}
+		if seen[id] || m.idExists(id) {
+			return &BatchError{Index: idx, Err: &network.APIError{
+				Status:  http.StatusBadRequest,
+				Message: "Failed to create record.",
+				Data:    map[string]network.FieldError{"id": notUnique},
+			}}
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+func (m *MemoryStore) idExists(id string) bool {
+	for _, college := range m.colleges {
+		if college.ID == id {
+			return true
+		}
+	}
+	for _, branch := range m.branches {
+		if branch.ID == id {
+			return true
+		}
+	}
+	for _, rank := range m.ranks {
+		if rank.ID == id {
+			return true
+		}
+	}
+	for _, alias := range m.collegeAliases {
+		if alias.ID == id {
+			return true
+		}
+	}
+	for _, alias := range m.branchAliases {
+		if alias.ID == id {
+			return true
+		}
+	}
+	for _, record := range m.imports {
+		if record.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteByID removes every item in ids. Like a batch it fails with a 404 and
+// removes nothing if any id is missing.
+func deleteByID[T any](items []T, ids []string, id func(T) string) ([]T, error) {
+	remove := make(map[string]bool, len(ids))
+	for _, itemID := range ids {
+		remove[itemID] = true
+	}
+	kept := make([]T, 0, len(items))
+	for _, item := range items {
+		if remove[id(item)] {
+			delete(remove, id(item))
+			continue
+		}
+		kept = append(kept, item)
+	}
+	for idx, itemID := range ids {
+		if remove[itemID] {
+			return items, &BatchError{Index: idx, Err: &network.APIError{
+				Status:  http.StatusNotFound,
+				Message: "The requested resource wasn't found.",
+			}}
+		}
+	}
+	return kept, nil
+}
+
+func checkBatchSize(size int) error {
+	if size > MaxBatchSize {
+		return fmt.Errorf("batch of %d requests is over the limit of %d", size, MaxBatchSize)
+	}
+	return nil
+}
+
+// memoryUpdatedSince mirrors the updated >= since filter, oldest first
+func memoryUpdatedSince[T any](items []T, since string, updated func(T) string) []T {
+	var matched []T
+	for _, item := range items {
+		if updated(item) >= since {
+			matched = append(matched, item)
+		}
+	}
+	slices.SortStableFunc(matched, func(a, b T) int {
+		return strings.Compare(updated(a), updated(b))
+	})
+	return matched
+}
+
+func memoryNow() string {
+	return time.Now().UTC().Format(pbDateLayout)
+}
+
+// listAllPages walks items one page at a time, the same way the Pocketbase
+// records endpoint would be walked, and returns a copy of everything.
+func listAllPages[T any](items []T, perPage int) []T {
+	all := make([]T, 0, len(items))
+	for page := 1; ; page++ {
+		response := paginate(items, page, perPage)
+		all = append(all, response.Items...)
+		if page >= response.TotalPages {
+			break
+		}
+	}
+	return all
+}
+
+func paginate[T any](items []T, page, perPage int) PbResponse[T] {
+	total := len(items)
+	start := min((page-1)*perPage, total)
+	end := min(start+perPage, total)
+	return PbResponse[T]{
+		Items:      slices.Clone(items[start:end]),
+		Page:       page,
+		PerPage:    perPage,
+		TotalItems: total,
+		TotalPages: (total + perPage - 1) / perPage,
+	}
+}
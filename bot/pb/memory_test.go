@@ -0,0 +1,247 @@
+package pb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/arinji2/dasa-bot/network"
+)
+
+func newTestStore() *MemoryStore {
+	return NewMemoryStore(
+		[]CollegeCollection{{ID: "college1", Name: "National Institute of Technology Calicut", Alias: "NITC, REC Calicut"}},
+		[]BranchCollection{
+			{ID: "branch1", Name: "Computer Science and Engineering", Code: "CS"},
+			{ID: "branch2", Name: "Computer Science and Engineering", Code: "CS", Ciwg: true},
+		},
+		[]RankCollection{{ID: "rank1", Year: 2024, Round: 1, College: "college1", Branch: "branch1", JeeOpen: 100, JeeClose: 200}},
+	)
+}
+
+// batchIndex returns the index a batch failed at, or -1 when err is not a
+// not unique id error
+func batchIndex(err error) int {
+	var batchErr *BatchError
+	var apiErr *network.APIError
+	if !errors.As(err, &batchErr) || !errors.As(err, &apiErr) || apiErr.Data["id"] != notUnique {
+		return -1
+	}
+	return batchErr.Index
+}
+
+func TestMemoryStoreBatchIDs(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		ids    []string
+		failAt int
+	}{
+		{name: "generated ids", ids: []string{"", ""}, failAt: -1},
+		{name: "new ids", ids: []string{"new1", "new2"}, failAt: -1},
+		{name: "taken id", ids: []string{"new1", "college1"}, failAt: 1},
+		{name: "taken by another collection", ids: []string{"rank1"}, failAt: 0},
+		{name: "repeated in batch", ids: []string{"new1", "", "new1"}, failAt: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Run("colleges", func(t *testing.T) {
+				m := newTestStore()
+				requests := make([]CollegeCreateRequest, len(tt.ids))
+				for idx, id := range tt.ids {
+					requests[idx] = CollegeCreateRequest{ID: id, Name: "College " + id}
+				}
+				_, err := m.CreateColleges(ctx, requests)
+				checkBatch(t, m, err, tt.failAt, 1, len(m.colleges), len(tt.ids))
+			})
+			t.Run("branches", func(t *testing.T) {
+				m := newTestStore()
+				requests := make([]BranchCreateRequest, len(tt.ids))
+				for idx, id := range tt.ids {
+					requests[idx] = BranchCreateRequest{ID: id, Name: "Branch " + id, Code: "B"}
+				}
+				_, err := m.CreateBranches(ctx, requests)
+				checkBatch(t, m, err, tt.failAt, 2, len(m.branches), len(tt.ids))
+			})
+			t.Run("ranks", func(t *testing.T) {
+				m := newTestStore()
+				requests := make([]RankCreateRequest, len(tt.ids))
+				for idx, id := range tt.ids {
+					requests[idx] = RankCreateRequest{ID: id, Year: 2023, Round: idx + 1, College: "college1", Branch: "branch1"}
+				}
+				_, err := m.CreateRanks(ctx, requests)
+				checkBatch(t, m, err, tt.failAt, 1, len(m.ranks), len(tt.ids))
+			})
+		})
+	}
+}
+
+// checkBatch asserts a batch failed at failAt and wrote nothing, or wrote every record
+func checkBatch(t *testing.T, m *MemoryStore, err error, failAt, before, after, batch int) {
+	t.Helper()
+	if failAt < 0 {
+		if err != nil {
+			t.Fatalf("batch failed: %v", err)
+		}
+		if after != before+batch {
+			t.Fatalf("got %d records, want %d", after, before+batch)
+		}
+		return
+	}
+	if got := batchIndex(err); got != failAt {
+		t.Fatalf("batch failed at %d with %v, want a not unique id at %d", got, err, failAt)
+	}
+	if after != before {
+		t.Fatalf("failed batch wrote %d records", after-before)
+	}
+}
+
+func TestMemoryStoreCreateBranch(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		request BranchCreateRequest
+		wantErr bool
+	}{
+		{name: "generated id", request: BranchCreateRequest{Name: "Civil Engineering", Code: "CE"}},
+		{name: "given id", request: BranchCreateRequest{ID: "branch3", Name: "Civil Engineering", Code: "CE"}},
+		{name: "taken id", request: BranchCreateRequest{ID: "branch1", Name: "Civil Engineering", Code: "CE"}, wantErr: true},
+		{name: "id of a college", request: BranchCreateRequest{ID: "college1", Name: "Civil Engineering", Code: "CE"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestStore()
+			created, err := m.CreateBranch(ctx, tt.request)
+			if tt.wantErr {
+				var apiErr *network.APIError
+				if !errors.As(err, &apiErr) || apiErr.Data["id"] != notUnique {
+					t.Fatalf("got %v, want a not unique id", err)
+				}
+				if len(m.branches) != 2 {
+					t.Fatalf("failed create added a branch")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.request.ID != "" && created.ID != tt.request.ID {
+				t.Fatalf("got id %s, want %s", created.ID, tt.request.ID)
+			}
+			if created.ID == "" {
+				t.Fatal("created branch has no id")
+			}
+		})
+	}
+}
+
+func TestMemoryStoreCreateRank(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name       string
+		request    RankCreateRequest
+		ciwg       bool
+		wantExists bool
+		wantErr    bool
+	}{
+		{name: "new round", request: RankCreateRequest{Year: 2024, Round: 2, College: "college1", Branch: "branch1"}},
+		{name: "same round", request: RankCreateRequest{Year: 2024, Round: 1, College: "college1", Branch: "branch1"}, wantExists: true, wantErr: true},
+		{name: "other category", request: RankCreateRequest{Year: 2024, Round: 1, College: "college1", Branch: "branch2"}, ciwg: true},
+		{name: "unknown college", request: RankCreateRequest{Year: 2024, Round: 2, College: "college2", Branch: "branch1"}, wantErr: true},
+		{name: "unknown branch", request: RankCreateRequest{Year: 2024, Round: 2, College: "college1", Branch: "branch3"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestStore()
+			created, exists, err := m.CreateRank(ctx, tt.request, tt.ciwg)
+			if exists != tt.wantExists || (err != nil) != tt.wantErr {
+				t.Fatalf("got exists %t and error %v, want %t and error %t", exists, err, tt.wantExists, tt.wantErr)
+			}
+			ranks, _ := m.GetAllRanks(ctx)
+			if tt.wantErr {
+				if len(ranks) != 1 {
+					t.Fatalf("failed create stored a rank")
+				}
+				return
+			}
+			if len(ranks) != 2 {
+				t.Fatalf("got %d ranks, want 2", len(ranks))
+			}
+			if created.ID == "" || created.Expand.College.ID != tt.request.College || created.Expand.Branch.ID != tt.request.Branch {
+				t.Fatalf("got %+v, want an id and its college and branch expanded", created)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreFilters(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStore()
+	if _, _, err := m.CreateRank(ctx, RankCreateRequest{Year: 2024, Round: 1, College: "college1", Branch: "branch2"}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	rank, err := m.GetSpecificRank(ctx, "college1", "CS", 2024, 1, true)
+	if err != nil || rank.Branch != "branch2" {
+		t.Fatalf("got %+v and %v, want the rank of branch2", rank, err)
+	}
+	if _, err := m.GetSpecificRank(ctx, "college1", "cs", 2024, 1, false); err == nil {
+		t.Fatal("code matched regardless of case")
+	}
+	rank, err = m.GetSpecificRankWithBranchID(ctx, "college1", "branch1", 2024, 1, false)
+	if err != nil || rank.ID != "rank1" {
+		t.Fatalf("got %+v and %v, want rank1", rank, err)
+	}
+
+	// the alias filter is a case insensitive contains
+	ranks, _ := m.GetRanksByCollegeBranch(ctx, "rec calicut", "CS")
+	if len(ranks) != 2 {
+		t.Fatalf("got %d ranks by alias, want 2", len(ranks))
+	}
+	ranks, _ = m.GetRanksByYearAndRound(ctx, 2024, 2)
+	if len(ranks) != 0 {
+		t.Fatalf("got %d ranks of an empty round", len(ranks))
+	}
+}
+
+func TestMemoryStorePages(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStore()
+	m.PerPage = 2
+	for round := 2; round <= 6; round++ {
+		if _, _, err := m.CreateRank(ctx, RankCreateRequest{Year: 2023, Round: round, College: "college1", Branch: "branch1"}, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ranks, err := m.GetAllRanks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranks) != 6 {
+		t.Fatalf("got %d ranks over pages of 2, want 6", len(ranks))
+	}
+	// newest year and round first, the way the sort on the collection orders them
+	if ranks[0].ID != "rank1" || ranks[1].Round != 6 || ranks[5].Round != 2 {
+		t.Fatalf("ranks are not ordered newest first")
+	}
+}
+
+func TestMemoryStoreBackups(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStore()
+	key, err := m.CreateBackup(ctx, "some.user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backups, _ := m.ListBackups(ctx)
+	if len(backups) != 1 || backups[0].Key != key {
+		t.Fatalf("got %v, want %s", backups, key)
+	}
+	if err := m.DeleteBackup(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DeleteBackup(ctx, key); err == nil {
+		t.Fatal("deleted a missing backup")
+	}
+}
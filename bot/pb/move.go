@@ -0,0 +1,79 @@
+package pb
+
+import (
+	"context"
+	"fmt"
+)
+
+// RankMover moves and deletes ranks in chunks of MaxBatchSize and puts them
+// back the way they were when a chunk fails
+type RankMover struct {
+	Store Store
+	// Revert is the move that puts a moved rank back where it came from
+	Revert func(id string) RankMoveRequest
+
+	movedIDs []string
+	// deleted holds the deleted ranks so they can be recreated under the same ids
+	deleted []RankCreateRequest
+}
+
+func (m *RankMover) Move(ctx context.Context, moves []RankMoveRequest) error {
+	for start := 0; start < len(moves); start += MaxBatchSize {
+		chunk := moves[start:min(start+MaxBatchSize, len(moves))]
+		if _, err := m.Store.MoveRanks(ctx, chunk); err != nil {
+			return m.rollback(ctx, fmt.Errorf("failed to move ranks: %w", err))
+		}
+		for _, move := range chunk {
+			m.movedIDs = append(m.movedIDs, move.ID)
+		}
+	}
+	return nil
+}
+
+func (m *RankMover) Delete(ctx context.Context, ranks []RankCollection) error {
+	for start := 0; start < len(ranks); start += MaxBatchSize {
+		chunk := ranks[start:min(start+MaxBatchSize, len(ranks))]
+		ids := make([]string, len(chunk))
+		for idx, rank := range chunk {
+			ids[idx] = rank.ID
+		}
+		if err := m.Store.DeleteRecords(ctx, "ranks", ids); err != nil {
+			return m.rollback(ctx, fmt.Errorf("failed to delete duplicate ranks: %w", err))
+		}
+		for _, rank := range chunk {
+			m.deleted = append(m.deleted, RankCreateRequest{
+				ID:       rank.ID,
+				Year:     rank.Year,
+				Round:    rank.Round,
+				JeeOpen:  rank.JeeOpen,
+				JeeClose: rank.JeeClose,
+				College:  rank.College,
+				Branch:   rank.Branch,
+			})
+		}
+	}
+	return nil
+}
+
+// rollback recreates the deleted ranks and moves the moved ones back
+func (m *RankMover) rollback(ctx context.Context, err error) error {
+	// finish undoing the merge even if the bot is shutting down
+	ctx = context.WithoutCancel(ctx)
+
+	for start := 0; start < len(m.deleted); start += MaxBatchSize {
+		if _, rollbackErr := m.Store.CreateRanks(ctx, m.deleted[start:min(start+MaxBatchSize, len(m.deleted))]); rollbackErr != nil {
+			return fmt.Errorf("%w, and failed to recreate deleted ranks: %w", err, rollbackErr)
+		}
+	}
+	for start := 0; start < len(m.movedIDs); start += MaxBatchSize {
+		chunk := m.movedIDs[start:min(start+MaxBatchSize, len(m.movedIDs))]
+		moves := make([]RankMoveRequest, len(chunk))
+		for idx, id := range chunk {
+			moves[idx] = m.Revert(id)
+		}
+		if _, rollbackErr := m.Store.MoveRanks(ctx, moves); rollbackErr != nil {
+			return fmt.Errorf("%w, and failed to move ranks back: %w", err, rollbackErr)
+		}
+	}
+	return err
+}
@@ -3,19 +3,51 @@
 package pb
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/arinji2/dasa-bot/env"
 	"github.com/arinji2/dasa-bot/network"
 )
 
-func SetupPocketbase(pb env.PB) *PocketbaseAdmin {
-	parsedURL, err := url.Parse(pb.BaseDomain)
+// refreshBefore is how long before the superuser token expires that it gets renewed
+const refreshBefore = 30 * time.Minute
+
+type authResponse struct {
+	Token string `json:"token"`
+}
+
+// SetupPocketbase authenticates as a superuser. The returned client keeps the
+// credentials and renews its token on its own, so it can be shared for the
+// lifetime of the bot.
+func SetupPocketbase(ctx context.Context, pb env.PB) *PocketbaseAdmin {
+	p := &PocketbaseAdmin{
+		BaseDomain:  pb.BaseDomain,
+		credentials: pb,
+	}
+
+	err := p.authenticate(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
+	return p
+}
+
+// authenticate logs in with the superuser email and password. Callers must hold p.mu
+// or be the only user of p.
+func (p *PocketbaseAdmin) authenticate(ctx context.Context) error {
+	parsedURL, err := url.Parse(p.BaseDomain)
+	if err != nil {
+		return err
+	}
 	parsedURL.Path = "/api/collections/_superusers/auth-with-password"
 	type request struct {
 		Identity string `json:"identity"`
@@ -23,20 +55,142 @@ func SetupPocketbase(pb env.PB) *PocketbaseAdmin {
 	}
 
 	body := request{
-		Identity: pb.Email,
-		Password: pb.Password,
+		Identity: p.credentials.Email,
+		Password: p.credentials.Password,
 	}
 
-	responseBody, err := network.MakeRequest(parsedURL, "POST", body)
+	responseBody, err := network.MakeRequest(ctx, parsedURL, "POST", body)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	var response PocketbaseAdmin
-	err = json.Unmarshal(responseBody, &response)
+	return p.setToken(responseBody)
+}
+
+// refresh renews the current token through auth-refresh, falling back to a full
+// login if Pocketbase rejects it. Callers must hold p.mu.
+func (p *PocketbaseAdmin) refresh(ctx context.Context) error {
+	parsedURL, err := url.Parse(p.BaseDomain)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	parsedURL.Path = "/api/collections/_superusers/auth-refresh"
+
+	type request struct{}
+	responseBody, err := network.MakeAuthenticatedRequest(ctx, parsedURL, "POST", request{}, p.token)
+	if err == nil {
+		err = p.setToken(responseBody)
+	}
+	if err != nil {
+		log.Printf("Could not refresh Pocketbase token, logging in again: %v", err)
+		return p.authenticate(ctx)
+	}
+
+	return nil
+}
+
+// setToken stores the token from an auth response along with its expiry.
+// Callers must hold p.mu.
+func (p *PocketbaseAdmin) setToken(responseBody []byte) error {
+	var response authResponse
+	err := json.Unmarshal(responseBody, &response)
+	if err != nil {
+		return err
+	}
+	if response.Token == "" {
+		return errors.New("pocketbase auth response did not contain a token")
+	}
+
+	expiry, err := tokenExpiry(response.Token)
+	if err != nil {
+		return err
+	}
+
+	p.token = response.Token
+	p.expiry = expiry
+	return nil
+}
+
+// currentToken returns a token that is valid for at least refreshBefore,
+// renewing it first if needed.
+func (p *PocketbaseAdmin) currentToken(ctx context.Context) (string, error) {
+	p.mu.RLock()
+	token, expiry := p.token, p.expiry
+	p.mu.RUnlock()
+	if time.Until(expiry) > refreshBefore {
+		return token, nil
 	}
-	response.BaseDomain = pb.BaseDomain
-	return &response
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// another request may have renewed it while we waited for the lock
+	if time.Until(p.expiry) > refreshBefore {
+		return p.token, nil
+	}
+	err := p.refresh(ctx)
+	if err != nil {
+		return "", err
+	}
+	return p.token, nil
+}
+
+// reauthenticate logs in again after Pocketbase rejected staleToken, unless
+// another request has already replaced it.
+func (p *PocketbaseAdmin) reauthenticate(ctx context.Context, staleToken string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.token != staleToken {
+		return p.token, nil
+	}
+	err := p.authenticate(ctx)
+	if err != nil {
+		return "", err
+	}
+	return p.token, nil
+}
+
+// authenticatedRequest makes a request as the superuser, retrying once with a
+// fresh login if the token was rejected.
+func (p *PocketbaseAdmin) authenticatedRequest(ctx context.Context, url *url.URL, method string, body any) ([]byte, error) {
+	token, err := p.currentToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pocketbase token: %w", err)
+	}
+
+	responseBody, err := network.MakeAuthenticatedRequest(ctx, url, method, body, token)
+	if !hasStatus(err, http.StatusUnauthorized) {
+		return responseBody, err
+	}
+
+	token, err = p.reauthenticate(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reauthenticate with pocketbase: %w", err)
+	}
+	return network.MakeAuthenticatedRequest(ctx, url, method, body, token)
+}
+
+// tokenExpiry reads the exp claim out of a JWT without verifying it.
+func tokenExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("pocketbase token is not a JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode token payload: %w", err)
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to unmarshal token claims: %w", err)
+	}
+	if claims.Exp == 0 {
+		return time.Time{}, errors.New("pocketbase token has no expiry")
+	}
+
+	return time.Unix(claims.Exp, 0), nil
 }
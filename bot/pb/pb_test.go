@@ -0,0 +1,198 @@
+package pb
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/arinji2/dasa-bot/network"
+)
+
+// testToken is an unsigned JWT that expires at expiry
+func testToken(name string, expiry time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, `{"exp":%d}`, expiry.Unix()))
+	return "header." + payload + "." + name
+}
+
+func TestAuthenticatedRequestToken(t *testing.T) {
+	soon := time.Now().Add(10 * time.Minute)
+	later := time.Now().Add(24 * time.Hour)
+	fresh := testToken("fresh", later)
+
+	tests := []struct {
+		name string
+		// token is what the client holds before the request
+		token string
+		// accepted reports whether the records endpoint takes a token
+		accepted     func(token string) bool
+		refreshFails bool
+		wantPaths    []string
+		wantErr      bool
+	}{
+		{
+			name:      "valid token is used as is",
+			token:     testToken("valid", later),
+			accepted:  func(string) bool { return true },
+			wantPaths: []string{"GET /api/collections/colleges/records"},
+		},
+		{
+			name:     "token close to expiry is refreshed first",
+			token:    testToken("old", soon),
+			accepted: func(token string) bool { return token == fresh },
+			wantPaths: []string{
+				"POST /api/collections/_superusers/auth-refresh",
+				"GET /api/collections/colleges/records",
+			},
+		},
+		{
+			name:         "rejected refresh logs in again",
+			token:        testToken("old", soon),
+			accepted:     func(token string) bool { return token == fresh },
+			refreshFails: true,
+			wantPaths: []string{
+				"POST /api/collections/_superusers/auth-refresh",
+				"POST /api/collections/_superusers/auth-with-password",
+				"GET /api/collections/colleges/records",
+			},
+		},
+		{
+			name:     "rejected token is retried once after logging in",
+			token:    testToken("revoked", later),
+			accepted: func(token string) bool { return token == fresh },
+			wantPaths: []string{
+				"GET /api/collections/colleges/records",
+				"POST /api/collections/_superusers/auth-with-password",
+				"GET /api/collections/colleges/records",
+			},
+		},
+		{
+			name:     "rejected again after logging in",
+			token:    testToken("revoked", later),
+			accepted: func(string) bool { return false },
+			wantPaths: []string{
+				"GET /api/collections/colleges/records",
+				"POST /api/collections/_superusers/auth-with-password",
+				"GET /api/collections/colleges/records",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			admin, requests := newTestAdmin(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/api/collections/_superusers/auth-refresh":
+					if tt.refreshFails {
+						w.WriteHeader(http.StatusUnauthorized)
+						w.Write([]byte(`{"status":401,"message":"The request requires valid record authorization token."}`))
+						return
+					}
+					fmt.Fprintf(w, `{"token":%q}`, fresh)
+				case "/api/collections/_superusers/auth-with-password":
+					fmt.Fprintf(w, `{"token":%q}`, fresh)
+				default:
+					if !tt.accepted(r.Header.Get("Authorization")) {
+						w.WriteHeader(http.StatusUnauthorized)
+						w.Write([]byte(`{"status":401,"message":"The request requires valid record authorization token."}`))
+						return
+					}
+					w.Write([]byte(`{"items":[]}`))
+				}
+			})
+			expiry, err := tokenExpiry(tt.token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			admin.token, admin.expiry = tt.token, expiry
+
+			recordsURL, _ := url.Parse(admin.BaseDomain + "/api/collections/colleges/records")
+			_, err = admin.authenticatedRequest(ctx, recordsURL, http.MethodGet, struct{}{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+
+			var paths []string
+			for _, r := range requests() {
+				paths = append(paths, r.Method+" "+r.Path)
+			}
+			if !slices.Equal(paths, tt.wantPaths) {
+				t.Fatalf("got requests %q, want %q", paths, tt.wantPaths)
+			}
+			if len(tt.wantPaths) > 1 && (admin.token != fresh || !admin.expiry.Equal(later.Truncate(time.Second))) {
+				t.Fatalf("got token %s expiring %v, want the fresh one", admin.token, admin.expiry)
+			}
+		})
+	}
+}
+
+func TestRequestRetries(t *testing.T) {
+	network.Configure(network.Config{MaxRetries: 3, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+	t.Cleanup(func() { network.Configure(network.DefaultConfig) })
+
+	unavailable := func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"status":503,"message":"Service Unavailable."}`))
+	}
+	// dropped closes the connection once the request has been read
+	dropped := func(w http.ResponseWriter) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.Close()
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		// failures is how many attempts fail before one succeeds
+		failures     int
+		fail         func(w http.ResponseWriter)
+		wantAttempts int
+		wantErr      bool
+	}{
+		{name: "get retried after server errors", method: http.MethodGet, failures: 2, fail: unavailable, wantAttempts: 3},
+		{name: "get retried after rate limiting", method: http.MethodGet, failures: 1, fail: func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"status":429,"message":"Too Many Requests."}`))
+		}, wantAttempts: 2},
+		{name: "get retried after a dropped connection", method: http.MethodGet, failures: 1, fail: dropped, wantAttempts: 2},
+		{name: "get gives up after max retries", method: http.MethodGet, failures: 10, fail: unavailable, wantAttempts: 4, wantErr: true},
+		{name: "get not retried after a rejected request", method: http.MethodGet, failures: 1, fail: func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status":400,"message":"Invalid filter."}`))
+		}, wantAttempts: 1, wantErr: true},
+		{name: "post not retried after a server error", method: http.MethodPost, failures: 1, fail: unavailable, wantAttempts: 1, wantErr: true},
+		{name: "post not retried once the body was sent", method: http.MethodPost, failures: 1, fail: dropped, wantAttempts: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			var attempts atomic.Int32
+			admin, _ := newTestAdmin(t, func(w http.ResponseWriter, r *http.Request) {
+				if int(attempts.Add(1)) <= tt.failures {
+					tt.fail(w)
+					return
+				}
+				w.Write([]byte(`{}`))
+			})
+
+			recordsURL, _ := url.Parse(admin.BaseDomain + "/api/collections/colleges/records")
+			_, err := admin.authenticatedRequest(ctx, recordsURL, tt.method, struct{}{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if got := int(attempts.Load()); got != tt.wantAttempts {
+				t.Fatalf("got %d attempts, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
@@ -1,102 +1,27 @@
 package pb
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
-	"strconv"
-	"sync"
-
-	"github.com/arinji2/dasa-bot/network"
 )
 
-func (p *PocketbaseAdmin) GetAllRanks() ([]RankCollection, error) {
-	parsedURL, err := url.Parse(p.BaseDomain)
-	if err != nil {
-		return nil, err
-	}
-	parsedURL.Path = "/api/collections/ranks/records"
-
-	perPage := 1000
-	params := url.Values{}
-	params.Add("perPage", strconv.Itoa(perPage))
-	params.Add("expand", "college,branch")
-	params.Add("page", "1")
-	params.Add("sort", "-year,-round")
-
-	parsedURL.RawQuery = params.Encode()
-
-	type request struct{}
-	responseBody, err := network.MakeAuthenticatedRequest(parsedURL, "GET", request{}, p.Token)
-	if err != nil {
-		return nil, err
-	}
-
-	var initialResponse PbResponse[RankCollection]
-	err = json.Unmarshal(responseBody, &initialResponse)
-	if err != nil {
-		return nil, err
-	}
-
-	total := initialResponse.TotalItems
-	pages := (total + perPage - 1) / perPage // ceil division
-	allItems := initialResponse.Items
-
-	if pages <= 1 {
-		return allItems, nil
-	}
-
-	type result struct {
-		Items []RankCollection
-		Err   error
-	}
-
-	resultsChan := make(chan result, pages-1)
-	var wg sync.WaitGroup
-
-	for page := 2; page <= pages; page++ {
-		wg.Add(1)
-		go func(page int) {
-			defer wg.Done()
-			pageURL := *parsedURL
-			query := url.Values{}
-			query.Add("perPage", strconv.Itoa(perPage))
-			query.Add("page", strconv.Itoa(page))
-			query.Add("expand", "college,branch")
-			query.Add("sort", "-year,-round")
-			pageURL.RawQuery = query.Encode()
-
-			body, err := network.MakeAuthenticatedRequest(&pageURL, "GET", request{}, p.Token)
-			if err != nil {
-				resultsChan <- result{Err: err}
-				return
-			}
-
-			var resp PbResponse[RankCollection]
-			if err := json.Unmarshal(body, &resp); err != nil {
-				resultsChan <- result{Err: err}
-				return
-			}
-
-			resultsChan <- result{Items: resp.Items}
-		}(page)
-	}
-
-	wg.Wait()
-	close(resultsChan)
-
-	for res := range resultsChan {
-		if res.Err != nil {
-			return nil, res.Err
-		}
-		allItems = append(allItems, res.Items...)
-	}
+func (p *PocketbaseAdmin) GetAllRanks(ctx context.Context) ([]RankCollection, error) {
+	return ListAll[RankCollection](ctx, p, "ranks", ListOptions{
+		Sort:   "-year,-round",
+		Expand: "college,branch",
+	})
+}
 
-	return allItems, nil
+// GetRanksUpdatedSince returns ranks created or edited at or after since
+func (p *PocketbaseAdmin) GetRanksUpdatedSince(ctx context.Context, since string) ([]RankCollection, error) {
+	return updatedSince[RankCollection](ctx, p, "ranks", since, "college,branch")
 }
 
 // GetSpecificRank by Year and Round for a College and Branch
-func (p *PocketbaseAdmin) GetSpecificRank(college string, branch string, year int, round int, ciwg bool) (RankCollection, error) {
+func (p *PocketbaseAdmin) GetSpecificRank(ctx context.Context, college string, branch string, year int, round int, ciwg bool) (RankCollection, error) {
 	parsedURL, err := url.Parse(p.BaseDomain)
 	if err != nil {
 		return RankCollection{}, fmt.Errorf("failed to parse base domain: %w", err)
@@ -104,13 +29,19 @@ func (p *PocketbaseAdmin) GetSpecificRank(college string, branch string, year in
 	parsedURL.Path = "/api/collections/ranks/records"
 
 	params := url.Values{}
-	params.Add("filter", fmt.Sprintf("year='%d' && round='%d' && college.id='%s' && branch.code='%s' && branch.ciwg=%t", year, round, college, branch, ciwg))
+	params.Add("filter", And(
+		Eq("year", year),
+		Eq("round", round),
+		Eq("college.id", college),
+		Eq("branch.code", branch),
+		Eq("branch.ciwg", ciwg),
+	).String())
 	params.Add("expand", "college,branch")
 
 	parsedURL.RawQuery = params.Encode()
 
 	type request struct{}
-	responseBody, err := network.MakeAuthenticatedRequest(parsedURL, "GET", request{}, p.Token)
+	responseBody, err := p.authenticatedRequest(ctx, parsedURL, "GET", request{})
 	if err != nil {
 		return RankCollection{}, fmt.Errorf("failed to make authenticated request: %w", err)
 	}
@@ -122,13 +53,13 @@ func (p *PocketbaseAdmin) GetSpecificRank(college string, branch string, year in
 	}
 
 	if len(response.Items) == 0 {
-		return RankCollection{}, fmt.Errorf("no rank found for year: %d, round: %d, college: %s, branch: %s", year, round, college, branch)
+		return RankCollection{}, fmt.Errorf("%w for year: %d, round: %d, college: %s, branch: %s", ErrRankNotFound, year, round, college, branch)
 	}
 
 	return response.Items[0], nil
 }
 
-func (p *PocketbaseAdmin) GetSpecificRankWithBranchID(college string, branch string, year int, round int, ciwg bool) (RankCollection, error) {
+func (p *PocketbaseAdmin) GetSpecificRankWithBranchID(ctx context.Context, college string, branch string, year int, round int, ciwg bool) (RankCollection, error) {
 	parsedURL, err := url.Parse(p.BaseDomain)
 	if err != nil {
 		return RankCollection{}, fmt.Errorf("failed to parse base domain: %w", err)
@@ -136,12 +67,18 @@ func (p *PocketbaseAdmin) GetSpecificRankWithBranchID(college string, branch str
 	parsedURL.Path = "/api/collections/ranks/records"
 
 	params := url.Values{}
-	params.Add("filter", fmt.Sprintf("year='%d' && round='%d' && college.id='%s' && branch.id='%s' && branch.ciwg=%t", year, round, college, branch, ciwg))
+	params.Add("filter", And(
+		Eq("year", year),
+		Eq("round", round),
+		Eq("college.id", college),
+		Eq("branch.id", branch),
+		Eq("branch.ciwg", ciwg),
+	).String())
 
 	parsedURL.RawQuery = params.Encode()
 
 	type request struct{}
-	responseBody, err := network.MakeAuthenticatedRequest(parsedURL, "GET", request{}, p.Token)
+	responseBody, err := p.authenticatedRequest(ctx, parsedURL, "GET", request{})
 	if err != nil {
 		return RankCollection{}, fmt.Errorf("failed to make authenticated request: %w", err)
 	}
@@ -153,14 +90,14 @@ func (p *PocketbaseAdmin) GetSpecificRankWithBranchID(college string, branch str
 	}
 
 	if len(response.Items) == 0 {
-		return RankCollection{}, fmt.Errorf("no rank found for year: %d, round: %d, college: %s, branch: %s", year, round, college, branch)
+		return RankCollection{}, fmt.Errorf("%w for year: %d, round: %d, college: %s, branch: %s", ErrRankNotFound, year, round, college, branch)
 	}
 
 	return response.Items[0], nil
 }
 
 // GetRanksByCollegeBranch for a College and Branch
-func (p *PocketbaseAdmin) GetRanksByCollegeBranch(college string, branch string) ([]RankCollection, error) {
+func (p *PocketbaseAdmin) GetRanksByCollegeBranch(ctx context.Context, college string, branch string) ([]RankCollection, error) {
 	parsedURL, err := url.Parse(p.BaseDomain)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse base domain: %w", err)
@@ -168,13 +105,13 @@ func (p *PocketbaseAdmin) GetRanksByCollegeBranch(college string, branch string)
 	parsedURL.Path = "/api/collections/ranks/records"
 
 	params := url.Values{}
-	params.Add("filter", fmt.Sprintf("college.alias~'%s' && branch.code='%s'", college, branch))
+	params.Add("filter", And(Like("college.alias", college), Eq("branch.code", branch)).String())
 	params.Add("expand", "college,branch")
 
 	parsedURL.RawQuery = params.Encode()
 
 	type request struct{}
-	responseBody, err := network.MakeAuthenticatedRequest(parsedURL, "GET", request{}, p.Token)
+	responseBody, err := p.authenticatedRequest(ctx, parsedURL, "GET", request{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to make authenticated request: %w", err)
 	}
@@ -189,7 +126,7 @@ func (p *PocketbaseAdmin) GetRanksByCollegeBranch(college string, branch string)
 }
 
 // GetRanksByYearAndRound for a Year and Round
-func (p *PocketbaseAdmin) GetRanksByYearAndRound(year int, round int) ([]RankCollection, error) {
+func (p *PocketbaseAdmin) GetRanksByYearAndRound(ctx context.Context, year int, round int) ([]RankCollection, error) {
 	parsedURL, err := url.Parse(p.BaseDomain)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse base domain: %w", err)
@@ -197,13 +134,13 @@ func (p *PocketbaseAdmin) GetRanksByYearAndRound(year int, round int) ([]RankCol
 	parsedURL.Path = "/api/collections/ranks/records"
 
 	params := url.Values{}
-	params.Add("filter", fmt.Sprintf("year='%d' && round='%d'", year, round))
+	params.Add("filter", And(Eq("year", year), Eq("round", round)).String())
 	params.Add("expand", "college,branch")
 
 	parsedURL.RawQuery = params.Encode()
 
 	type request struct{}
-	responseBody, err := network.MakeAuthenticatedRequest(parsedURL, "GET", request{}, p.Token)
+	responseBody, err := p.authenticatedRequest(ctx, parsedURL, "GET", request{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to make authenticated request: %w", err)
 	}
@@ -217,11 +154,15 @@ func (p *PocketbaseAdmin) GetRanksByYearAndRound(year int, round int) ([]RankCol
 	return response.Items, nil
 }
 
-func (p *PocketbaseAdmin) CreateRank(rank RankCreateRequest, ciwg bool) (RankCollection, bool, error) {
-	_, err := p.GetSpecificRankWithBranchID(rank.College, rank.Branch, rank.Year, rank.Round, ciwg)
+func (p *PocketbaseAdmin) CreateRank(ctx context.Context, rank RankCreateRequest, ciwg bool) (RankCollection, bool, error) {
+	_, err := p.GetSpecificRankWithBranchID(ctx, rank.College, rank.Branch, rank.Year, rank.Round, ciwg)
 	if err == nil {
 		return RankCollection{}, true, fmt.Errorf("rank already exists")
 	}
+	// only a lookup that found nothing proves the rank is new, a failed one proves nothing
+	if !errors.Is(err, ErrRankNotFound) && !IsNotFound(err) {
+		return RankCollection{}, false, fmt.Errorf("failed to check for existing rank: %w", err)
+	}
 	parsedURL, err := url.Parse(p.BaseDomain)
 	if err != nil {
 		return RankCollection{}, false, fmt.Errorf("failed to parse base domain: %w", err)
@@ -233,7 +174,7 @@ func (p *PocketbaseAdmin) CreateRank(rank RankCreateRequest, ciwg bool) (RankCol
 
 	parsedURL.RawQuery = params.Encode()
 
-	responseBody, err := network.MakeAuthenticatedRequest(parsedURL, "POST", rank, p.Token)
+	responseBody, err := p.authenticatedRequest(ctx, parsedURL, "POST", rank)
 	if err != nil {
 		return RankCollection{}, false, err
 	}
@@ -0,0 +1,113 @@
+package pb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordedRequest is a request seen by a test Pocketbase server
+type recordedRequest struct {
+	Method string
+	Path   string
+	// RawPath keeps escapes, so path segments can be compared as sent
+	RawPath string
+}
+
+// newTestAdmin points a PocketbaseAdmin with a long lived token at handler and
+// records every request it makes
+func newTestAdmin(t *testing.T, handler http.HandlerFunc) (*PocketbaseAdmin, func() []recordedRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []recordedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, recordedRequest{Method: r.Method, Path: r.URL.Path, RawPath: r.URL.EscapedPath()})
+		mu.Unlock()
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	admin := &PocketbaseAdmin{BaseDomain: server.URL, token: "token", expiry: time.Now().Add(24 * time.Hour)}
+	return admin, func() []recordedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]recordedRequest(nil), requests...)
+	}
+}
+
+func TestCreateRankLookup(t *testing.T) {
+	tests := []struct {
+		name       string
+		lookup     func(w http.ResponseWriter)
+		wantExists bool
+		wantErr    bool
+		wantCreate bool
+	}{
+		{
+			name:       "no existing rank",
+			lookup:     func(w http.ResponseWriter) { w.Write([]byte(`{"items":[]}`)) },
+			wantCreate: true,
+		},
+		{
+			name:       "existing rank",
+			lookup:     func(w http.ResponseWriter) { w.Write([]byte(`{"items":[{"id":"rank1"}]}`)) },
+			wantExists: true,
+			wantErr:    true,
+		},
+		{
+			name: "collection not found",
+			lookup: func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"status":404,"message":"Missing collection context."}`))
+			},
+			wantCreate: true,
+		},
+		{
+			name: "rejected lookup",
+			lookup: func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"status":400,"message":"Invalid filter."}`))
+			},
+			wantErr: true,
+		},
+		{
+			name:    "unreadable lookup",
+			lookup:  func(w http.ResponseWriter) { w.Write([]byte(`<html>`)) },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			admin, requests := newTestAdmin(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					tt.lookup(w)
+					return
+				}
+				w.Write([]byte(`{"id":"created"}`))
+			})
+
+			created, exists, err := admin.CreateRank(context.Background(), RankCreateRequest{Year: 2024, Round: 1, College: "college1", Branch: "branch1"}, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if exists != tt.wantExists {
+				t.Fatalf("got exists %v, want %v", exists, tt.wantExists)
+			}
+
+			posted := false
+			for _, request := range requests() {
+				posted = posted || request.Method == http.MethodPost
+			}
+			if posted != tt.wantCreate {
+				t.Fatalf("got create %v, want %v", posted, tt.wantCreate)
+			}
+			if tt.wantCreate && created.ID != "created" {
+				t.Fatalf("got rank %q, want the created rank", created.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package pb
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/arinji2/dasa-bot/network"
+)
+
+const (
+	RealtimeCreate = "create"
+	RealtimeUpdate = "update"
+	RealtimeDelete = "delete"
+)
+
+// RealtimeEvent is a single record change pushed by Pocketbase
+type RealtimeEvent struct {
+	Collection string
+	Action     string
+	Record     json.RawMessage
+}
+
+// RealtimeHandler receives events from Subscribe. OnReconnect is called after
+// the stream comes back with how long it was down, events in that gap are lost.
+type RealtimeHandler struct {
+	OnEvent     func(RealtimeEvent)
+	OnReconnect func(gap time.Duration)
+}
+
+// Subscriber is implemented by stores that can push record changes
+type Subscriber interface {
+	Subscribe(ctx context.Context, collections []string, handler RealtimeHandler)
+}
+
+var _ Subscriber = (*PocketbaseAdmin)(nil)
+
+type sseMessage struct {
+	Event string
+	Data  string
+}
+
+// Subscribe listens on /api/realtime for changes to every record of
+// collections until ctx is cancelled, reconnecting whenever the stream drops.
+func (p *PocketbaseAdmin) Subscribe(ctx context.Context, collections []string, handler RealtimeHandler) {
+	var disconnectedAt time.Time
+	attempt := 0
+	for ctx.Err() == nil {
+		err := p.listen(ctx, collections, handler, func() {
+			attempt = 0
+			if !disconnectedAt.IsZero() && handler.OnReconnect != nil {
+				handler.OnReconnect(time.Since(disconnectedAt))
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if disconnectedAt.IsZero() || attempt == 0 {
+			disconnectedAt = time.Now()
+		}
+		log.Printf("Realtime connection lost, reconnecting: %v", err)
+
+		timer := time.NewTimer(network.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		attempt++
+	}
+}
+
+// listen holds a single realtime connection open until it fails
+func (p *PocketbaseAdmin) listen(ctx context.Context, collections []string, handler RealtimeHandler, onSubscribed func()) error {
+	parsedURL, err := url.Parse(p.BaseDomain)
+	if err != nil {
+		return err
+	}
+	parsedURL.Path = "/api/realtime"
+
+	header := http.Header{}
+	header.Set("Accept", "text/event-stream")
+	body, err := network.OpenStream(ctx, parsedURL.String(), header)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	scanner := bufio.NewScanner(body)
+	// record payloads can be larger than the default 64KB line limit
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	subscribed := false
+	for {
+		message, err := readSSEMessage(scanner)
+		if err != nil {
+			return err
+		}
+
+		if message.Event == "PB_CONNECT" {
+			var connect struct {
+				ClientID string `json:"clientId"`
+			}
+			err = json.Unmarshal([]byte(message.Data), &connect)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal realtime connect message: %w", err)
+			}
+			err = p.setSubscriptions(ctx, connect.ClientID, collections)
+			if err != nil {
+				return fmt.Errorf("failed to subscribe: %w", err)
+			}
+			if !subscribed {
+				subscribed = true
+				onSubscribed()
+			}
+			continue
+		}
+
+		collection, _, found := strings.Cut(message.Event, "/")
+		if !found || handler.OnEvent == nil {
+			continue
+		}
+
+		var event struct {
+			Action string          `json:"action"`
+			Record json.RawMessage `json:"record"`
+		}
+		err = json.Unmarshal([]byte(message.Data), &event)
+		if err != nil {
+			log.Printf("Error unmarshalling realtime event for %s: %v", collection, err)
+			continue
+		}
+		handler.OnEvent(RealtimeEvent{
+			Collection: collection,
+			Action:     event.Action,
+			Record:     event.Record,
+		})
+	}
+}
+
+func (p *PocketbaseAdmin) setSubscriptions(ctx context.Context, clientID string, collections []string) error {
+	parsedURL, err := url.Parse(p.BaseDomain)
+	if err != nil {
+		return err
+	}
+	parsedURL.Path = "/api/realtime"
+
+	type request struct {
+		ClientID      string   `json:"clientId"`
+		Subscriptions []string `json:"subscriptions"`
+	}
+	subscriptions := make([]string, len(collections))
+	for idx, collection := range collections {
+		subscriptions[idx] = collection + "/*"
+	}
+
+	_, err = p.authenticatedRequest(ctx, parsedURL, "POST", request{
+		ClientID:      clientID,
+		Subscriptions: subscriptions,
+	})
+	return err
+}
+
+// readSSEMessage reads lines until a blank line ends the current message
+func readSSEMessage(scanner *bufio.Scanner) (sseMessage, error) {
+	var message sseMessage
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if message.Event == "" && len(data) == 0 {
+				continue
+			}
+			message.Data = strings.Join(data, "\n")
+			return message, nil
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			message.Event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return sseMessage{}, err
+	}
+	return sseMessage{}, errors.New("realtime stream closed")
+}
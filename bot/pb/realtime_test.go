@@ -0,0 +1,146 @@
+package pb
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arinji2/dasa-bot/network"
+)
+
+func TestReadSSEMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  string
+		want    []sseMessage
+		wantErr bool
+	}{
+		{
+			name:   "event with data",
+			stream: "event: PB_CONNECT\ndata: {\"clientId\":\"abc\"}\n\n",
+			want:   []sseMessage{{Event: "PB_CONNECT", Data: `{"clientId":"abc"}`}},
+		},
+		{
+			name:   "multi-line data",
+			stream: "event: ranks/*\ndata: {\"action\":\"create\",\ndata: \"record\":{}}\n\n",
+			want:   []sseMessage{{Event: "ranks/*", Data: "{\"action\":\"create\",\n\"record\":{}}"}},
+		},
+		{
+			name:   "comments, ids and extra blank lines are skipped",
+			stream: "\n\n: keep alive\nid: 1\nevent: a\ndata:no space\n\n\nevent: b\n\n",
+			want:   []sseMessage{{Event: "a", Data: "no space"}, {Event: "b"}},
+		},
+		{
+			name:    "stream closed mid message",
+			stream:  "event: a\ndata: x\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanner := bufio.NewScanner(strings.NewReader(tt.stream))
+			var got []sseMessage
+			for {
+				message, err := readSSEMessage(scanner)
+				if err != nil {
+					break
+				}
+				got = append(got, message)
+			}
+			if tt.wantErr {
+				if len(got) != 0 {
+					t.Fatalf("got %v, want an error before any message", got)
+				}
+				return
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubscribeReconnects(t *testing.T) {
+	network.Configure(network.Config{BaseBackoff: 20 * time.Millisecond, MaxBackoff: 20 * time.Millisecond})
+	t.Cleanup(func() { network.Configure(network.DefaultConfig) })
+
+	var mu sync.Mutex
+	connections := 0
+	var subscribed []string
+	admin, _ := newTestAdmin(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			var body struct {
+				ClientID      string   `json:"clientId"`
+				Subscriptions []string `json:"subscriptions"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			mu.Lock()
+			subscribed = append(subscribed, body.ClientID+" "+strings.Join(body.Subscriptions, ","))
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		mu.Lock()
+		connections++
+		connection := connections
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "event: PB_CONNECT\ndata: {\"clientId\":\"client%d\"}\n\n", connection)
+		w.(http.Flusher).Flush()
+		if connection == 1 {
+			// the first stream drops right after subscribing
+			return
+		}
+		fmt.Fprint(w, "event: colleges/*\ndata: {\"action\":\"update\",\ndata: \"record\":{\"id\":\"nitc\"}}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var events []RealtimeEvent
+	var gaps []time.Duration
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		admin.Subscribe(ctx, []string{"colleges", "ranks"}, RealtimeHandler{
+			OnEvent: func(event RealtimeEvent) {
+				events = append(events, event)
+				cancel()
+			},
+			OnReconnect: func(gap time.Duration) {
+				gaps = append(gaps, gap)
+			},
+		})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event after reconnecting")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"client1 colleges/*,ranks/*", "client2 colleges/*,ranks/*"}
+	if !slices.Equal(subscribed, want) {
+		t.Fatalf("got subscriptions %q, want %q", subscribed, want)
+	}
+	// only the event sent after the reconnect arrives
+	if len(events) != 1 || events[0].Collection != "colleges" || events[0].Action != RealtimeUpdate || string(events[0].Record) != `{"id":"nitc"}` {
+		t.Fatalf("got events %+v, want the colleges update", events)
+	}
+	// the gap covers at least the jittered backoff, half of it at the least
+	if len(gaps) != 1 || gaps[0] < 10*time.Millisecond || gaps[0] > time.Since(start) {
+		t.Fatalf("got reconnect gaps %v, want one of at least 10ms", gaps)
+	}
+}
@@ -0,0 +1,180 @@
+package pb
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/arinji2/dasa-bot/env"
+)
+
+// ScheduledBackupUser is who scheduled backups are taken as, their keys start with it
+const ScheduledBackupUser = "scheduled"
+
+// IsScheduledBackup reports whether the backup of key was taken on the schedule
+func IsScheduledBackup(key string) bool {
+	return strings.HasPrefix(key, ScheduledBackupUser+"_")
+}
+
+// Retention decides which backups are old enough to delete. Backups taken by
+// hand are limited by count and age, scheduled ones are kept in daily, weekly
+// and monthly tiers. The newest backup of either kind is always kept.
+type Retention struct {
+	// Keep is how many of the newest backups are kept, zero keeps any number
+	Keep int
+	// MaxAge is how old a backup may get, zero keeps backups of any age
+	MaxAge time.Duration
+
+	// Daily keeps the newest scheduled backup of each of the last Daily days
+	Daily int
+	// Weekly keeps the newest scheduled backup of each of the last Weekly weeks
+	Weekly int
+	// Monthly keeps the newest scheduled backup of each of the last Monthly months
+	Monthly int
+}
+
+func NewRetention(backup env.Backup) Retention {
+	return Retention{
+		Keep:    backup.Keep,
+		MaxAge:  backup.MaxAge,
+		Daily:   backup.Daily,
+		Weekly:  backup.Weekly,
+		Monthly: backup.Monthly,
+	}
+}
+
+// String describes the policy for the people it deletes backups for
+func (r Retention) String() string {
+	var manual string
+	switch {
+	case r.Keep > 0 && r.MaxAge > 0:
+		manual = fmt.Sprintf("Keeping the newest %d backups that are under %s old", r.Keep, r.MaxAge)
+	case r.Keep > 0:
+		manual = fmt.Sprintf("Keeping the newest %d backups", r.Keep)
+	case r.MaxAge > 0:
+		manual = fmt.Sprintf("Keeping backups under %s old", r.MaxAge)
+	default:
+		manual = "Keeping every backup"
+	}
+	return fmt.Sprintf("%s, and scheduled backups of the last %d days, %d weeks and %d months", manual, r.Daily, r.Weekly, r.Monthly)
+}
+
+// SortBackups orders backups newest first. Backups whose modified time cannot
+// be read are put last.
+func SortBackups(backups []BackupCollection) {
+	slices.SortStableFunc(backups, func(a, b BackupCollection) int {
+		aTime, aErr := ParseTime(a.Modified)
+		bTime, bErr := ParseTime(b.Modified)
+		switch {
+		case aErr != nil && bErr != nil:
+			return 0
+		case aErr != nil:
+			return 1
+		case bErr != nil:
+			return -1
+		}
+		return bTime.Compare(aTime)
+	})
+}
+
+// Expired returns the backups the policy no longer keeps at now, oldest first
+func (r Retention) Expired(backups []BackupCollection, now time.Time) []BackupCollection {
+	var manual, scheduled []BackupCollection
+	for _, backup := range backups {
+		if IsScheduledBackup(backup.Key) {
+			scheduled = append(scheduled, backup)
+		} else {
+			manual = append(manual, backup)
+		}
+	}
+	SortBackups(manual)
+	SortBackups(scheduled)
+
+	expired := r.expiredManual(manual, now)
+	expired = append(expired, r.expiredScheduled(scheduled, now)...)
+	SortBackups(expired)
+	slices.Reverse(expired)
+	return expired
+}
+
+// expiredManual applies the count and age limits to backups sorted newest first
+func (r Retention) expiredManual(backups []BackupCollection, now time.Time) []BackupCollection {
+	var expired []BackupCollection
+	for idx, backup := range backups {
+		if idx == 0 {
+			continue
+		}
+		if r.Keep > 0 && idx >= r.Keep {
+			expired = append(expired, backup)
+			continue
+		}
+		// a backup of unknown age is only ever dropped by the count
+		modified, err := ParseTime(backup.Modified)
+		if r.MaxAge > 0 && err == nil && now.Sub(modified) > r.MaxAge {
+			expired = append(expired, backup)
+		}
+	}
+	return expired
+}
+
+// expiredScheduled keeps the newest backup of each recent day, week and month
+// from backups sorted newest first. Periods are counted in the location of now.
+func (r Retention) expiredScheduled(backups []BackupCollection, now time.Time) []BackupCollection {
+	tiers := []struct {
+		keep   int
+		period func(time.Time) string
+		seen   map[string]bool
+	}{
+		{r.Daily, func(t time.Time) string { return t.Format("2006-01-02") }, make(map[string]bool)},
+		{r.Weekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}, make(map[string]bool)},
+		{r.Monthly, func(t time.Time) string { return t.Format("2006-01") }, make(map[string]bool)},
+	}
+
+	var expired []BackupCollection
+	for idx, backup := range backups {
+		modified, err := ParseTime(backup.Modified)
+		if err != nil {
+			// unknown ages sort last, and cannot be placed in a period
+			continue
+		}
+		modified = modified.In(now.Location())
+
+		kept := idx == 0
+		for _, tier := range tiers {
+			period := tier.period(modified)
+			if tier.seen[period] || len(tier.seen) >= tier.keep {
+				continue
+			}
+			tier.seen[period] = true
+			kept = true
+		}
+		if !kept {
+			expired = append(expired, backup)
+		}
+	}
+	return expired
+}
+
+// PruneBackups deletes the backups retention no longer keeps and returns the
+// ones it deleted. It stops at the first backup it cannot delete.
+func PruneBackups(ctx context.Context, store Store, retention Retention) ([]BackupCollection, error) {
+	backups, err := store.ListBackups(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	var deleted []BackupCollection
+	for _, backup := range retention.Expired(backups, time.Now()) {
+		err := store.DeleteBackup(ctx, backup.Key)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete backup %s: %w", backup.Key, err)
+		}
+		deleted = append(deleted, backup)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,167 @@
+package pb
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// testBackup is a backup of user modified at the given time
+func testBackup(user string, modified time.Time) BackupCollection {
+	return BackupCollection{
+		Key:      user + "_" + modified.Format("02_01_2006_15_04_05") + ".zip",
+		Modified: modified.UTC().Format(pbDateLayout),
+	}
+}
+
+func backupKeys(backups []BackupCollection) []string {
+	keys := make([]string, len(backups))
+	for idx, backup := range backups {
+		keys[idx] = backup.Key
+	}
+	return keys
+}
+
+func TestRetentionExpired(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	newest := testBackup("alice", now.Add(-time.Hour))
+	day := testBackup("bob", now.Add(-24*time.Hour))
+	dayAndASecond := testBackup("carol", now.Add(-24*time.Hour-time.Second))
+	week := testBackup("dave", now.Add(-7*24*time.Hour))
+	unknown := BackupCollection{Key: "erin_upload.zip", Modified: "yesterday"}
+	scheduled := testBackup(ScheduledBackupUser, now.Add(-30*24*time.Hour))
+	all := []BackupCollection{day, unknown, newest, week, dayAndASecond}
+
+	tests := []struct {
+		name      string
+		retention Retention
+		backups   []BackupCollection
+		want      []BackupCollection
+	}{
+		{name: "unset limits keep everything", backups: all},
+		{name: "no backups", retention: Retention{Keep: 1, MaxAge: time.Hour}},
+		{name: "keep covers every backup", retention: Retention{Keep: 5}, backups: all},
+		// unknown ages sort last, so they are the first to go by count
+		{name: "keep one fewer", retention: Retention{Keep: 4}, backups: all, want: []BackupCollection{unknown}},
+		{name: "keep one", retention: Retention{Keep: 1}, backups: all, want: []BackupCollection{unknown, week, dayAndASecond, day}},
+		// exactly MaxAge old is still kept
+		{name: "max age boundary", retention: Retention{MaxAge: 24 * time.Hour}, backups: all, want: []BackupCollection{week, dayAndASecond}},
+		{name: "newest is kept past max age", retention: Retention{MaxAge: time.Minute}, backups: []BackupCollection{week, day}, want: []BackupCollection{week}},
+		{name: "count and age together", retention: Retention{Keep: 3, MaxAge: 48 * time.Hour}, backups: all, want: []BackupCollection{unknown, week}},
+		{name: "negative limits are unset", retention: Retention{Keep: -1, MaxAge: -time.Hour}, backups: all},
+		// scheduled backups only answer to the tiers, and the newest of them is always kept
+		{name: "scheduled do not count towards keep", retention: Retention{Keep: 1}, backups: []BackupCollection{scheduled, newest}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.retention.Expired(slices.Clone(tt.backups), now)
+			if !slices.Equal(backupKeys(got), backupKeys(tt.want)) {
+				t.Fatalf("got %v, want %v", backupKeys(got), backupKeys(tt.want))
+			}
+		})
+	}
+}
+
+func TestRetentionScheduledTiers(t *testing.T) {
+	utc := func(year int, month time.Month, day, hour int) BackupCollection {
+		return testBackup(ScheduledBackupUser, time.Date(year, month, day, hour, 0, 0, 0, time.UTC))
+	}
+	// 2024-06-15 is the Saturday of ISO week 24
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	var daily []BackupCollection
+	for day := range 60 {
+		daily = append(daily, utc(2024, 6, 15-day, 3))
+	}
+	ist := time.FixedZone("IST", 5*60*60+30*60)
+
+	tests := []struct {
+		name      string
+		retention Retention
+		now       time.Time
+		backups   []BackupCollection
+		wantKept  []BackupCollection
+	}{
+		{
+			name:      "every tier",
+			retention: Retention{Daily: 3, Weekly: 2, Monthly: 2},
+			backups:   daily,
+			// the last 3 days, the newest of the week before and of May
+			wantKept: []BackupCollection{utc(2024, 6, 15, 3), utc(2024, 6, 14, 3), utc(2024, 6, 13, 3), utc(2024, 6, 9, 3), utc(2024, 5, 31, 3)},
+		},
+		{
+			name:     "no tiers keep the newest",
+			backups:  daily,
+			wantKept: []BackupCollection{utc(2024, 6, 15, 3)},
+		},
+		{
+			name:      "only the newest of a day",
+			retention: Retention{Daily: 2},
+			backups:   []BackupCollection{utc(2024, 6, 15, 3), utc(2024, 6, 15, 9), utc(2024, 6, 14, 3), utc(2024, 6, 14, 9)},
+			wantKept:  []BackupCollection{utc(2024, 6, 15, 9), utc(2024, 6, 14, 9)},
+		},
+		{
+			name:      "month ends",
+			retention: Retention{Monthly: 3},
+			backups:   []BackupCollection{utc(2024, 1, 31, 3), utc(2024, 2, 1, 3), utc(2024, 2, 29, 3), utc(2024, 3, 1, 3)},
+			wantKept:  []BackupCollection{utc(2024, 3, 1, 3), utc(2024, 2, 29, 3), utc(2024, 1, 31, 3)},
+		},
+		{
+			// 2024-12-30 already belongs to week 1 of 2025
+			name:      "iso weeks across a year",
+			retention: Retention{Weekly: 2},
+			now:       time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC),
+			backups:   []BackupCollection{utc(2024, 12, 29, 3), utc(2024, 12, 30, 3), utc(2025, 1, 1, 3)},
+			wantKept:  []BackupCollection{utc(2025, 1, 1, 3), utc(2024, 12, 29, 3)},
+		},
+		{
+			name:      "days in utc",
+			retention: Retention{Daily: 2},
+			backups:   []BackupCollection{utc(2024, 6, 15, 3), utc(2024, 6, 14, 23)},
+			wantKept:  []BackupCollection{utc(2024, 6, 15, 3), utc(2024, 6, 14, 23)},
+		},
+		{
+			// 23:00 UTC is 04:30 the next day in India
+			name:      "days in the location of now",
+			retention: Retention{Daily: 2},
+			now:       now.In(ist),
+			backups:   []BackupCollection{utc(2024, 6, 15, 3), utc(2024, 6, 14, 23)},
+			wantKept:  []BackupCollection{utc(2024, 6, 15, 3)},
+		},
+		{
+			name:      "unknown ages are kept",
+			retention: Retention{Daily: 1},
+			backups:   []BackupCollection{utc(2024, 6, 15, 3), utc(2024, 6, 14, 3), {Key: ScheduledBackupUser + "_upload.zip", Modified: "yesterday"}},
+			wantKept:  []BackupCollection{utc(2024, 6, 15, 3), {Key: ScheduledBackupUser + "_upload.zip"}},
+		},
+		{
+			name:      "manual backups are left to keep and max age",
+			retention: Retention{Daily: 1},
+			backups:   []BackupCollection{utc(2024, 6, 15, 3), testBackup("alice", now.Add(-90*24*time.Hour))},
+			wantKept:  []BackupCollection{utc(2024, 6, 15, 3), testBackup("alice", now.Add(-90*24*time.Hour))},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at := tt.now
+			if at.IsZero() {
+				at = now
+			}
+			expired := make(map[string]bool)
+			for _, backup := range tt.retention.Expired(slices.Clone(tt.backups), at) {
+				expired[backup.Key] = true
+			}
+			var kept []string
+			for _, backup := range tt.backups {
+				if !expired[backup.Key] {
+					kept = append(kept, backup.Key)
+				}
+			}
+			want := backupKeys(tt.wantKept)
+			slices.Sort(kept)
+			slices.Sort(want)
+			if !slices.Equal(kept, want) {
+				t.Fatalf("kept %v, want %v", kept, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package pb
+
+import (
+	"context"
+	"io"
+)
+
+// Store is the set of Pocketbase operations the bot depends on.
+// PocketbaseAdmin talks to a live instance over HTTP, while MemoryStore keeps
+// everything in memory so command logic can run without one.
+type Store interface {
+	GetAllColleges(ctx context.Context) ([]CollegeCollection, error)
+	GetCollegeByID(ctx context.Context, id string) (CollegeCollection, error)
+	GetCollegesUpdatedSince(ctx context.Context, since string) ([]CollegeCollection, error)
+	CreateColleges(ctx context.Context, colleges []CollegeCreateRequest) ([]CollegeCollection, error)
+	CreateCollege(ctx context.Context, college CollegeCreateRequest) (CollegeCollection, error)
+	UpdateCollege(ctx context.Context, id string, college CollegeCreateRequest) (CollegeCollection, error)
+	DeleteCollege(ctx context.Context, id string) error
+
+	GetAllCollegeAliases(ctx context.Context) ([]CollegeAliasCollection, error)
+	GetCollegeAliasesUpdatedSince(ctx context.Context, since string) ([]CollegeAliasCollection, error)
+	CreateCollegeAlias(ctx context.Context, alias CollegeAliasCreateRequest) (CollegeAliasCollection, error)
+	UpdateCollegeAlias(ctx context.Context, id string, alias CollegeAliasCreateRequest) (CollegeAliasCollection, error)
+
+	GetAllBranches(ctx context.Context) ([]BranchCollection, error)
+	GetBranchByCode(ctx context.Context, code string) (BranchCollection, error)
+	GetBranchesUpdatedSince(ctx context.Context, since string) ([]BranchCollection, error)
+	CreateBranch(ctx context.Context, branch BranchCreateRequest) (BranchCollection, error)
+	CreateBranches(ctx context.Context, branches []BranchCreateRequest) ([]BranchCollection, error)
+	UpdateBranch(ctx context.Context, id string, branch BranchCreateRequest) (BranchCollection, error)
+	DeleteBranch(ctx context.Context, id string) error
+
+	GetAllBranchAliases(ctx context.Context) ([]BranchAliasCollection, error)
+	GetBranchAliasesUpdatedSince(ctx context.Context, since string) ([]BranchAliasCollection, error)
+	CreateBranchAlias(ctx context.Context, alias BranchAliasCreateRequest) (BranchAliasCollection, error)
+	UpdateBranchAlias(ctx context.Context, id string, alias BranchAliasCreateRequest) (BranchAliasCollection, error)
+
+	GetAllRanks(ctx context.Context) ([]RankCollection, error)
+	GetRanksUpdatedSince(ctx context.Context, since string) ([]RankCollection, error)
+	GetSpecificRank(ctx context.Context, college string, branch string, year int, round int, ciwg bool) (RankCollection, error)
+	GetSpecificRankWithBranchID(ctx context.Context, college string, branch string, year int, round int, ciwg bool) (RankCollection, error)
+	GetRanksByCollegeBranch(ctx context.Context, college string, branch string) ([]RankCollection, error)
+	GetRanksByYearAndRound(ctx context.Context, year int, round int) ([]RankCollection, error)
+	CreateRank(ctx context.Context, rank RankCreateRequest, ciwg bool) (RankCollection, bool, error)
+	CreateRanks(ctx context.Context, ranks []RankCreateRequest) ([]RankCollection, error)
+	UpdateRanks(ctx context.Context, ranks []RankUpdateRequest) ([]RankCollection, error)
+	MoveRanks(ctx context.Context, ranks []RankMoveRequest) ([]RankCollection, error)
+
+	CreateImport(ctx context.Context, record ImportCreateRequest) (ImportCollection, error)
+	ListImports(ctx context.Context, limit int) ([]ImportCollection, error)
+	GetImport(ctx context.Context, id string) (ImportCollection, error)
+
+	ListRecordIDs(ctx context.Context, collection string) ([]string, error)
+	DeleteRecords(ctx context.Context, collection string, ids []string) error
+
+	ListBackups(ctx context.Context) ([]BackupCollection, error)
+	CreateBackup(ctx context.Context, userName string) (string, error)
+	DeleteBackup(ctx context.Context, key string) error
+	RestoreBackup(ctx context.Context, key string) error
+	DownloadBackup(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+var (
+	_ Store = (*PocketbaseAdmin)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
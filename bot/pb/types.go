@@ -1,11 +1,19 @@
 package pb
 
+import (
+	"sync"
+	"time"
+
+	"github.com/arinji2/dasa-bot/env"
+)
+
 type PocketbaseAdmin struct {
-	Token  string `json:"token"`
-	Record struct {
-		ID string `json:"id"`
-	} `json:"record"`
 	BaseDomain string
+
+	credentials env.PB
+	mu          sync.RWMutex
+	token       string
+	expiry      time.Time
 }
 
 type PbResponse[T any] struct {
@@ -18,20 +26,23 @@ type PbResponse[T any] struct {
 
 type BackupCollection struct {
 	Key      string `json:"key"`
+	Size     int64  `json:"size"`
 	Modified string `json:"modified"`
 }
 
 type CollegeCollection struct {
-	ID    string `json:"id"`
-	Name  string `json:"name"`
-	Alias string `json:"alias"`
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Alias   string `json:"alias"`
+	Updated string `json:"updated"`
 }
 
 type BranchCollection struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
-	Code string `json:"code"`
-	Ciwg bool   `json:"ciwg"`
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Code    string `json:"code"`
+	Ciwg    bool   `json:"ciwg"`
+	Updated string `json:"updated"`
 }
 
 type RankCollection struct {
@@ -42,19 +53,63 @@ type RankCollection struct {
 	JeeClose int    `json:"jee_close"`
 	College  string `json:"college"`
 	Branch   string `json:"branch"`
+	Updated  string `json:"updated"`
 	Expand   struct {
 		College CollegeCollection `json:"college"`
 		Branch  BranchCollection  `json:"branch"`
 	} `json:"expand"`
 }
 
+// CollegeAliasCollection is another name a college is known by, e.g. its name before a rename
+type CollegeAliasCollection struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	College string `json:"college"`
+	Updated string `json:"updated"`
+}
+
+// BranchAliasCollection is another name and code a branch is known by
+type BranchAliasCollection struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Code    string `json:"code"`
+	Branch  string `json:"branch"`
+	Updated string `json:"updated"`
+}
+
+type CollegeCreateRequest struct {
+	// ID is only set to restore a record under the id it was exported with
+	ID    string `json:"id,omitempty"`
+	Name  string `json:"name"`
+	Alias string `json:"alias"`
+}
+
+type CollegeAliasCreateRequest struct {
+	// ID is only set to restore a record under the id it was exported with
+	ID      string `json:"id,omitempty"`
+	Name    string `json:"name"`
+	College string `json:"college"`
+}
+
+type BranchAliasCreateRequest struct {
+	// ID is only set to restore a record under the id it was exported with
+	ID     string `json:"id,omitempty"`
+	Name   string `json:"name"`
+	Code   string `json:"code"`
+	Branch string `json:"branch"`
+}
+
 type BranchCreateRequest struct {
+	// ID is only set to restore a record under the id it was exported with
+	ID   string `json:"id,omitempty"`
 	Name string `json:"name"`
 	Code string `json:"code"`
 	Ciwg bool   `json:"ciwg"`
 }
 
 type RankCreateRequest struct {
+	// ID is only set to recreate a deleted rank under its old id, or an exported one under its exported id
+	ID       string `json:"id,omitempty"`
 	Year     int    `json:"year"`
 	Round    int    `json:"round"`
 	JeeOpen  int    `json:"jee_open"`
@@ -62,3 +117,65 @@ type RankCreateRequest struct {
 	College  string `json:"college"`
 	Branch   string `json:"branch"`
 }
+
+type RankUpdateRequest struct {
+	ID       string `json:"-"`
+	JeeOpen  int    `json:"jee_open"`
+	JeeClose int    `json:"jee_close"`
+}
+
+// RankMoveRequest points a rank at another college or branch, empty fields are left as they are
+type RankMoveRequest struct {
+	ID      string `json:"-"`
+	College string `json:"college,omitempty"`
+	Branch  string `json:"branch,omitempty"`
+}
+
+// ImportCollection records one /insert, whether it went through or not
+type ImportCollection struct {
+	ID       string `json:"id"`
+	User     string `json:"user"`
+	UserID   string `json:"user_id"`
+	FileName string `json:"file_name"`
+	// FileHash is the hex encoded SHA-256 of the file
+	FileHash string `json:"file_hash"`
+	// Year and Round are zero when the file carried several rounds, Rounds describes them all
+	Year         int      `json:"year"`
+	Round        int      `json:"round"`
+	Rounds       string   `json:"rounds"`
+	Mode         string   `json:"mode"`
+	Status       string   `json:"status"`
+	RanksCreated int      `json:"ranks_created"`
+	RanksUpdated int      `json:"ranks_updated"`
+	RanksDeleted int      `json:"ranks_deleted"`
+	RanksSkipped int      `json:"ranks_skipped"`
+	RanksFailed  int      `json:"ranks_failed"`
+	NewBranches  []string `json:"new_branches"`
+	NewColleges  []string `json:"new_colleges"`
+	BackupKey    string   `json:"backup_key"`
+	DurationMs   int64    `json:"duration_ms"`
+	Error        string   `json:"error"`
+	Created      string   `json:"created"`
+}
+
+type ImportCreateRequest struct {
+	User         string   `json:"user"`
+	UserID       string   `json:"user_id"`
+	FileName     string   `json:"file_name"`
+	FileHash     string   `json:"file_hash"`
+	Year         int      `json:"year"`
+	Round        int      `json:"round"`
+	Rounds       string   `json:"rounds"`
+	Mode         string   `json:"mode"`
+	Status       string   `json:"status"`
+	RanksCreated int      `json:"ranks_created"`
+	RanksUpdated int      `json:"ranks_updated"`
+	RanksDeleted int      `json:"ranks_deleted"`
+	RanksSkipped int      `json:"ranks_skipped"`
+	RanksFailed  int      `json:"ranks_failed"`
+	NewBranches  []string `json:"new_branches"`
+	NewColleges  []string `json:"new_colleges"`
+	BackupKey    string   `json:"backup_key"`
+	DurationMs   int64    `json:"duration_ms"`
+	Error        string   `json:"error"`
+}